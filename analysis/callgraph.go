@@ -0,0 +1,315 @@
+package analysis
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+// CallGraph is the result of BuildCallGraph.
+type CallGraph struct {
+	// Funcs are the functions bound by let statements, in source order.
+	Funcs []*Func
+	// Calls are the calls made by the program's own statements, outside any
+	// of Funcs.
+	Calls []Call
+	// Cycles are the groups of functions which call each other, directly or
+	// through other functions, or a function which calls itself. The
+	// functions of a cycle are in source order, and the cycles are in the
+	// order of their first functions.
+	Cycles [][]*Func
+}
+
+// Func is a function bound by a let statement.
+type Func struct {
+	// ID is the function's name, followed by the position of the name if
+	// another function has the same name.
+	ID   string
+	Name *ast.Identifier
+	Lit  *ast.FunctionLiteral
+	// Calls are the calls of other functions in the body, in source order.
+	// The calls made by function literals in the body which aren't bound by
+	// let statements of their own are counted as the function's.
+	Calls []Call
+	// Recursive reports whether the function is part of a cycle.
+	Recursive bool
+	// Unused reports whether the program's statements can't reach the
+	// function: neither they nor any function they can reach use its name.
+	Unused bool
+}
+
+// Call is a call of a function by its name.
+type Call struct {
+	Site   *ast.CallExpression
+	Callee *Func
+}
+
+// Callees returns the functions f calls, in the order they're first called.
+func (f *Func) Callees() []*Func {
+	return callees(f.Calls)
+}
+
+func callees(calls []Call) []*Func {
+	var fns []*Func
+	seen := make(map[*Func]bool)
+	for _, c := range calls {
+		if !seen[c.Callee] {
+			seen[c.Callee] = true
+			fns = append(fns, c.Callee)
+		}
+	}
+	return fns
+}
+
+// BuildCallGraph finds the functions bound by let statements in prog and the
+// calls between them. Calls of anything other than such a function by name,
+// such as a parameter, aren't in the graph.
+func BuildCallGraph(prog *ast.Program) *CallGraph {
+	b := &graphBuilder{
+		g:      &CallGraph{},
+		info:   resolve.Resolve(prog),
+		byDecl: make(map[*resolve.Decl]*Func),
+		byLit:  make(map[*ast.FunctionLiteral]*Func),
+		refs:   make(map[*Func][]*Func),
+	}
+	names := make(map[string]int)
+	ast.Inspect(prog, func(n ast.Node) bool {
+		ls, ok := n.(*ast.LetStatement)
+		if !ok || ls.Name == nil || b.info.Defs[ls.Name] == nil {
+			return true
+		}
+		if fl, ok := ls.Value.(*ast.FunctionLiteral); ok {
+			f := &Func{Name: ls.Name, Lit: fl}
+			b.g.Funcs = append(b.g.Funcs, f)
+			b.byDecl[b.info.Defs[ls.Name]] = f
+			b.byLit[fl] = f
+			names[ls.Name.Value]++
+		}
+		return true
+	})
+	for _, f := range b.g.Funcs {
+		f.ID = f.Name.Value
+		if names[f.ID] > 1 {
+			f.ID += "@" + f.Name.Pos().String()
+		}
+	}
+	b.walk(prog, nil)
+	b.findUnused()
+	b.findCycles()
+	return b.g
+}
+
+type graphBuilder struct {
+	g      *CallGraph
+	info   *resolve.Info
+	byDecl map[*resolve.Decl]*Func
+	byLit  map[*ast.FunctionLiteral]*Func
+	// refs are the functions whose names each function uses. The program's
+	// statements are nil.
+	refs map[*Func][]*Func
+}
+
+// walk finds the calls in n, which is in the function cur, or in the
+// program's statements if cur is nil.
+func (b *graphBuilder) walk(n ast.Node, cur *Func) {
+	switch n := n.(type) {
+	case *ast.FunctionLiteral:
+		if f := b.byLit[n]; f != nil {
+			cur = f
+		}
+	case *ast.CallExpression:
+		if id, ok := n.Function.(*ast.Identifier); ok {
+			if callee := b.byDecl[b.info.Uses[id]]; callee != nil {
+				c := Call{Site: n, Callee: callee}
+				if cur == nil {
+					b.g.Calls = append(b.g.Calls, c)
+				} else {
+					cur.Calls = append(cur.Calls, c)
+				}
+			}
+		}
+	case *ast.Identifier:
+		if f := b.byDecl[b.info.Uses[n]]; f != nil {
+			b.refs[cur] = append(b.refs[cur], f)
+		}
+	}
+	for _, c := range ast.Children(n) {
+		b.walk(c, cur)
+	}
+}
+
+// findUnused marks the functions which the program's statements can't reach.
+func (b *graphBuilder) findUnused() {
+	reached := make(map[*Func]bool)
+	var reach func(from *Func)
+	reach = func(from *Func) {
+		for _, f := range b.refs[from] {
+			if !reached[f] {
+				reached[f] = true
+				reach(f)
+			}
+		}
+	}
+	reach(nil)
+	for _, f := range b.g.Funcs {
+		f.Unused = !reached[f]
+	}
+}
+
+// findCycles finds the strongly connected components of the graph, with
+// Tarjan's algorithm, and keeps those with a cycle.
+func (b *graphBuilder) findCycles() {
+	index := make(map[*Func]int)
+	low := make(map[*Func]int)
+	onStack := make(map[*Func]bool)
+	var stack []*Func
+	var visit func(f *Func)
+	visit = func(f *Func) {
+		index[f] = len(index)
+		low[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+		self := false
+		for _, g := range f.Callees() {
+			if g == f {
+				self = true
+			}
+			if _, seen := index[g]; !seen {
+				visit(g)
+				if low[g] < low[f] {
+					low[f] = low[g]
+				}
+			} else if onStack[g] && index[g] < low[f] {
+				low[f] = index[g]
+			}
+		}
+		if low[f] != index[f] {
+			return
+		}
+		var scc []*Func
+		for {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[g] = false
+			scc = append(scc, g)
+			if g == f {
+				break
+			}
+		}
+		if len(scc) > 1 || self {
+			b.g.Cycles = append(b.g.Cycles, scc)
+		}
+	}
+	for _, f := range b.g.Funcs {
+		if _, seen := index[f]; !seen {
+			visit(f)
+		}
+	}
+
+	order := make(map[*Func]int)
+	for i, f := range b.g.Funcs {
+		order[f] = i
+	}
+	for _, scc := range b.g.Cycles {
+		sort.Slice(scc, func(i, j int) bool { return order[scc[i]] < order[scc[j]] })
+		for _, f := range scc {
+			f.Recursive = true
+		}
+	}
+	sort.Slice(b.g.Cycles, func(i, j int) bool {
+		return order[b.g.Cycles[i][0]] < order[b.g.Cycles[j][0]]
+	})
+}
+
+// programID is the ID of the node for the program's statements in the
+// outputs. It can't be the name of a function.
+const programID = "<program>"
+
+// WriteDOT writes g to w as a Graphviz digraph named name. The program's
+// statements are a box, the functions in cycles are red, and the unused
+// functions are dashed and grey.
+func (g *CallGraph) WriteDOT(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", strconv.Quote(name))
+	fmt.Fprintf(bw, "\t%s [shape=box];\n", strconv.Quote(programID))
+	for _, f := range g.Funcs {
+		var attrs string
+		switch {
+		case f.Recursive && f.Unused:
+			attrs = " [color=red, style=dashed]"
+		case f.Recursive:
+			attrs = " [color=red]"
+		case f.Unused:
+			attrs = " [color=grey, style=dashed]"
+		}
+		fmt.Fprintf(bw, "\t%s%s;\n", strconv.Quote(f.ID), attrs)
+	}
+	for _, callee := range callees(g.Calls) {
+		fmt.Fprintf(bw, "\t%s -> %s;\n", strconv.Quote(programID), strconv.Quote(callee.ID))
+	}
+	for _, f := range g.Funcs {
+		for _, callee := range f.Callees() {
+			fmt.Fprintf(bw, "\t%s -> %s;\n", strconv.Quote(f.ID), strconv.Quote(callee.ID))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+type jsonGraph struct {
+	Calls     []string   `json:"calls"`
+	Functions []jsonFunc `json:"functions"`
+	Cycles    [][]string `json:"cycles"`
+}
+
+type jsonFunc struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Pos       string   `json:"pos"`
+	Calls     []string `json:"calls"`
+	Recursive bool     `json:"recursive"`
+	Unused    bool     `json:"unused"`
+}
+
+// WriteJSON writes g to w as a JSON object. Its "calls" are the IDs of the
+// functions the program's statements call, its "functions" describe Funcs,
+// and its "cycles" list the IDs of the functions in each cycle.
+func (g *CallGraph) WriteJSON(w io.Writer) error {
+	ids := func(fns []*Func) []string {
+		out := []string{}
+		for _, f := range fns {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+	out := jsonGraph{
+		Calls:     ids(callees(g.Calls)),
+		Functions: []jsonFunc{},
+		Cycles:    [][]string{},
+	}
+	for _, f := range g.Funcs {
+		out.Functions = append(out.Functions, jsonFunc{
+			ID:        f.ID,
+			Name:      f.Name.Value,
+			Pos:       f.Name.Pos().String(),
+			Calls:     ids(f.Callees()),
+			Recursive: f.Recursive,
+			Unused:    f.Unused,
+		})
+	}
+	for _, scc := range g.Cycles {
+		out.Cycles = append(out.Cycles, ids(scc))
+	}
+	data, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,168 @@
+package analysis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	tests := []struct {
+		input string
+		// want has the program's calls, then a line for each function: its
+		// ID, the IDs of the functions it calls, and whether it's recursive
+		// or unused.
+		want string
+		// cycles are the IDs of the functions in each cycle.
+		cycles string
+	}{
+		{
+			input: "let f = fn(x) { g(x) + 1 }; let g = fn(x) { x }; f(1)",
+			want:  "<program>: f\nf: g\ng:",
+		},
+		{
+			input:  "let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(5)",
+			want:   "<program>: fact\nfact: fact recursive",
+			cycles: "fact",
+		},
+		{
+			input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };\n" +
+				"let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };\n" +
+				"let main = fn() { even(10) };\n" +
+				"main()",
+			want:   "<program>: main\neven: odd recursive\nodd: even recursive\nmain: even",
+			cycles: "even odd",
+		},
+		{
+			// Unused functions may still call each other, or be recursive.
+			input:  "let f = fn() { g() }; let g = fn() { h() }; let h = fn() { g() }; 1",
+			want:   "<program>:\nf: g unused\ng: h recursive unused\nh: g recursive unused",
+			cycles: "g h",
+		},
+		{
+			// A function passed by name may be called.
+			input: "let apply = fn(f, x) { f(x) }; let inc = fn(x) { x + 1 }; apply(inc, 1)",
+			want:  "<program>: apply\napply:\ninc:",
+		},
+		{
+			// Calls from functions which aren't bound by let statements
+			// belong to the enclosing function.
+			input: "let f = fn() { fn() { g() } }; let g = fn() { 1 }; f()()",
+			want:  "<program>: f\nf: g\ng:",
+		},
+		{
+			input: "let f = fn() { let f = fn() { 1 }; f() }; f()",
+			want:  "<program>: f@1:5\nf@1:5: f@1:20\nf@1:20:",
+		},
+		{
+			// f returns g without calling it, so there's no cycle.
+			input: "let f = fn(n) { let g = fn() { f(n) }; g }; f(1)",
+			want:  "<program>: f\nf:\ng: f",
+		},
+	}
+
+	for i, tt := range tests {
+		g := BuildCallGraph(parser.MustParse(tt.input))
+		var lines []string
+		line := "<program>:"
+		for _, f := range callees(g.Calls) {
+			line += " " + f.ID
+		}
+		lines = append(lines, line)
+		for _, f := range g.Funcs {
+			line := f.ID + ":"
+			for _, c := range f.Callees() {
+				line += " " + c.ID
+			}
+			if f.Recursive {
+				line += " recursive"
+			}
+			if f.Unused {
+				line += " unused"
+			}
+			lines = append(lines, line)
+		}
+		if got := strings.Join(lines, "\n"); got != tt.want {
+			t.Errorf("%d. BuildCallGraph(%q) =\n%s\nwant\n%s", i, tt.input, got, tt.want)
+		}
+		var cycles []string
+		for _, scc := range g.Cycles {
+			var ids []string
+			for _, f := range scc {
+				ids = append(ids, f.ID)
+			}
+			cycles = append(cycles, strings.Join(ids, " "))
+		}
+		if got := strings.Join(cycles, ", "); got != tt.cycles {
+			t.Errorf("%d. BuildCallGraph(%q).Cycles = %q, want %q", i, tt.input, got, tt.cycles)
+		}
+	}
+}
+
+const graphInput = `let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+let unused = fn() { fact(1) };
+fact(3)`
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := BuildCallGraph(parser.MustParse(graphInput)).WriteDOT(&buf, "fact.mk"); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph "fact.mk" {
+	"<program>" [shape=box];
+	"fact" [color=red];
+	"unused" [color=grey, style=dashed];
+	"<program>" -> "fact";
+	"fact" -> "fact";
+	"unused" -> "fact";
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteDOT =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := BuildCallGraph(parser.MustParse(graphInput)).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"calls": [
+		"fact"
+	],
+	"functions": [
+		{
+			"id": "fact",
+			"name": "fact",
+			"pos": "1:5",
+			"calls": [
+				"fact"
+			],
+			"recursive": true,
+			"unused": false
+		},
+		{
+			"id": "unused",
+			"name": "unused",
+			"pos": "2:5",
+			"calls": [
+				"fact"
+			],
+			"recursive": false,
+			"unused": true
+		}
+	],
+	"cycles": [
+		[
+			"fact"
+		]
+	]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteJSON =\n%s\nwant\n%s", got, want)
+	}
+}
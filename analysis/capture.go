@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"fmt"
+
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+// FreeVars returns the names fl uses but doesn't declare, in the order they're
+// first used. They include the names used by functions nested in fl, which
+// fl needs in order to make their closures. Without the rest of the program,
+// the names declared at the top level can't be told apart from the others;
+// FindCaptures does that.
+func FreeVars(fl *ast.FunctionLiteral) []string {
+	prog := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Token: fl.Token, Expression: fl}}}
+	info := resolve.Resolve(prog)
+	var names []string
+	seen := make(map[string]bool)
+	ast.Inspect(fl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok && info.Defs[id] == nil && info.Uses[id] == nil && !seen[id.Value] {
+			seen[id.Value] = true
+			names = append(names, id.Value)
+		}
+		return true
+	})
+	return names
+}
+
+// VarKind says where the declaration a name refers to is, relative to the
+// function it's used in.
+type VarKind int
+
+const (
+	// Global is a name declared at the top level of the program, outside any
+	// block, which needn't be captured.
+	Global VarKind = iota
+	// Param is a parameter of the function the name is used in.
+	Param
+	// Local is a let statement in the function the name is used in, or,
+	// outside any function, in a block.
+	Local
+	// Captured is a parameter or let statement of an enclosing function, or
+	// of an enclosing block outside any function, which the closure holds.
+	Captured
+)
+
+func (k VarKind) String() string {
+	switch k {
+	case Global:
+		return "global"
+	case Param:
+		return "parameter"
+	case Local:
+		return "local"
+	case Captured:
+		return "captured"
+	}
+	return fmt.Sprintf("VarKind(%d)", int(k))
+}
+
+// Captures is the result of FindCaptures.
+type Captures struct {
+	Info *resolve.Info
+	// Kinds classifies each resolved use of a name. Undefined names are
+	// missing.
+	Kinds map[*ast.Identifier]VarKind
+	// Free maps each function literal to the declarations its closure holds,
+	// in the order they're first used. Like FreeVars, they include those
+	// used by the functions nested in it, but not globals.
+	Free map[*ast.FunctionLiteral][]*resolve.Decl
+}
+
+// FindCaptures resolves prog and classifies the names used in it.
+func FindCaptures(prog *ast.Program) *Captures {
+	c := &Captures{
+		Info:  resolve.Resolve(prog),
+		Kinds: make(map[*ast.Identifier]VarKind),
+		Free:  make(map[*ast.FunctionLiteral][]*resolve.Decl),
+	}
+	c.walk(prog, nil, make(map[*ast.FunctionLiteral]map[*resolve.Decl]bool))
+	return c
+}
+
+// walk classifies the uses in n, which is inside the functions in fns, from
+// the outermost.
+func (c *Captures) walk(n ast.Node, fns []*ast.FunctionLiteral, seen map[*ast.FunctionLiteral]map[*resolve.Decl]bool) {
+	switch n := n.(type) {
+	case *ast.FunctionLiteral:
+		fns = append(fns, n)
+		c.Free[n] = nil
+		seen[n] = make(map[*resolve.Decl]bool)
+	case *ast.Identifier:
+		d := c.Info.Uses[n]
+		if d == nil {
+			break
+		}
+		df := function(d)
+		switch {
+		case d.Scope == c.Info.Scope:
+			c.Kinds[n] = Global
+		case len(fns) > 0 && fns[len(fns)-1] != df:
+			c.Kinds[n] = Captured
+			// Each function between the use and the declaration holds it.
+			for i := len(fns) - 1; i >= 0 && fns[i] != df; i-- {
+				if !seen[fns[i]][d] {
+					seen[fns[i]][d] = true
+					c.Free[fns[i]] = append(c.Free[fns[i]], d)
+				}
+			}
+		case d.Kind == resolve.Param:
+			c.Kinds[n] = Param
+		default:
+			c.Kinds[n] = Local
+		}
+	}
+	for _, child := range ast.Children(n) {
+		c.walk(child, fns, seen)
+	}
+}
+
+// function returns the function literal d is declared in, or nil.
+func function(d *resolve.Decl) *ast.FunctionLiteral {
+	for s := d.Scope; s != nil; s = s.Parent {
+		if fl, ok := s.Node.(*ast.FunctionLiteral); ok {
+			return fl
+		}
+	}
+	return nil
+}
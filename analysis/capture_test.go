@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/parser"
+)
+
+func TestFreeVars(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"fn(x) { x + 1 }", ""},
+		{"fn(x) { x + y }", "y"},
+		{"fn(x) { let y = x; f(y, z, f) }", "f z"},
+		{"fn(a) { let b = a + c; fn(d) { b + d + e } }", "c e"},
+		// The parameter hides the outer x.
+		{"fn() { fn(x) { x } }", ""},
+		// Only the inner function declares x.
+		{"fn() { let f = fn(x) { x }; f(x) }", "x"},
+		// The name in the condition isn't the one the branch declares.
+		{"fn() { if (x) { let x = 1; x } }", "x"},
+		// Recursive functions refer to themselves by name.
+		{"fn() { let f = fn(n) { f(n - 1) }; f }", ""},
+	}
+
+	for i, tt := range tests {
+		prog := parser.MustParse(tt.input)
+		fl := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if got := strings.Join(FreeVars(fl), " "); got != tt.want {
+			t.Errorf("%d. FreeVars(%q) = %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFindCaptures(t *testing.T) {
+	tests := []struct {
+		input string
+		// kinds are the kinds of the names used, in source order.
+		kinds string
+		// free are the names each function literal captures, in source
+		// order.
+		free []string
+	}{
+		{
+			input: "let x = 1; let f = fn(y) { let z = y; x + y + z }; f(x)",
+			kinds: "y:parameter x:global y:parameter z:local f:global x:global",
+			free:  []string{""},
+		},
+		{
+			input: "let adder = fn(a) { fn(b) { a + b } };",
+			kinds: "a:captured b:parameter",
+			free:  []string{"", "a"},
+		},
+		{
+			// The middle function holds a for the innermost one.
+			input: "let f = fn(a) { fn(b) { fn(c) { a + b + c } } };",
+			kinds: "a:captured b:captured c:parameter",
+			free:  []string{"", "a", "a b"},
+		},
+		{
+			// The parameter hides the global x.
+			input: "let x = 1; let f = fn(x) { fn() { x } };",
+			kinds: "x:captured",
+			free:  []string{"", "x"},
+		},
+		{
+			// The inner let hides the parameter.
+			input: "let f = fn(x) { fn() { let x = 2; x } };",
+			kinds: "x:local",
+			free:  []string{"", ""},
+		},
+		{
+			input: "let f = fn(x) { let g = fn(x) { x }; g(x) };",
+			kinds: "x:parameter g:local x:parameter",
+			free:  []string{"", ""},
+		},
+		{
+			// Blocks outside any function aren't global.
+			input: "if (true) { let y = 1; let f = fn() { y }; f() }",
+			kinds: "y:captured f:local",
+			free:  []string{"y"},
+		},
+		{
+			input: "let f = fn() { let n = 0; let g = fn() { if (n == 0) { let m = n; fn() { m + n } } }; g };",
+			kinds: "n:captured n:captured m:captured n:captured g:local",
+			free:  []string{"", "n", "m n"},
+		},
+		{
+			// Undefined names have no kind.
+			input: "let f = fn() { y };",
+			free:  []string{""},
+		},
+	}
+
+	for i, tt := range tests {
+		prog := parser.MustParse(tt.input)
+		c := FindCaptures(prog)
+		var kinds, free []string
+		ast.Inspect(prog, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Identifier:
+				if k, ok := c.Kinds[n]; ok {
+					kinds = append(kinds, fmt.Sprintf("%s:%v", n.Value, k))
+				}
+			case *ast.FunctionLiteral:
+				var names []string
+				for _, d := range c.Free[n] {
+					names = append(names, d.Name.Value)
+				}
+				free = append(free, strings.Join(names, " "))
+			}
+			return true
+		})
+		if got := strings.Join(kinds, " "); got != tt.kinds {
+			t.Errorf("%d. FindCaptures(%q) kinds = %q, want %q", i, tt.input, got, tt.kinds)
+		}
+		if got, want := strings.Join(free, ", "), strings.Join(tt.free, ", "); got != want {
+			t.Errorf("%d. FindCaptures(%q) free = %q, want %q", i, tt.input, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package analysis computes facts about monkey programs for tools such as
+// linters, optimizers and backends.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+// TailCalls is the result of FindTailCalls.
+type TailCalls struct {
+	// Set holds the calls in tail position: those whose value a function
+	// returns as soon as it has it.
+	Set map[*ast.CallExpression]bool
+	// NotTailRecursive are the functions which call themselves other than
+	// in tail position, in source order.
+	NotTailRecursive []Recursion
+}
+
+// IsTail reports whether ce is in tail position.
+func (tc *TailCalls) IsTail(ce *ast.CallExpression) bool {
+	return tc.Set[ce]
+}
+
+// Calls returns the calls in tail position, in source order.
+func (tc *TailCalls) Calls() []*ast.CallExpression {
+	var calls []*ast.CallExpression
+	for ce := range tc.Set {
+		calls = append(calls, ce)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Pos().Before(calls[j].Pos())
+	})
+	return calls
+}
+
+// Recursion is a function bound by a let statement which calls itself by
+// name, other than in tail position.
+type Recursion struct {
+	Name *ast.Identifier
+	Func *ast.FunctionLiteral
+	// Calls are the calls of itself which aren't tail calls.
+	Calls []*ast.CallExpression
+}
+
+func (r Recursion) String() string {
+	return fmt.Sprintf("%v: %s isn't tail recursive: the call at %v isn't a tail call", r.Name.Pos(), r.Name.Value, r.Calls[0].Pos())
+}
+
+// FindTailCalls finds the calls in tail position in the function literals of
+// prog: the value of the last statement of the body, if it's a call, and of
+// return statements, and the last statements of the branches of an if
+// expression which is in tail position.
+//
+// A function calls itself if its body, outside any functions nested in it,
+// calls the name it's bound to. Calls made by functions nested in it are
+// calls from another function, and aren't counted.
+func FindTailCalls(prog *ast.Program) *TailCalls {
+	tc := &TailCalls{Set: make(map[*ast.CallExpression]bool)}
+	var lets []*ast.LetStatement
+	ast.Inspect(prog, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			if n.Body != nil {
+				tc.block(n.Body)
+				ast.Inspect(n.Body, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.FunctionLiteral:
+						return false
+					case *ast.ReturnStatement:
+						tc.tail(n.ReturnValue)
+					}
+					return true
+				})
+			}
+		case *ast.LetStatement:
+			if _, ok := n.Value.(*ast.FunctionLiteral); ok && n.Name != nil {
+				lets = append(lets, n)
+			}
+		}
+		return true
+	})
+
+	info := resolve.Resolve(prog)
+	for _, ls := range lets {
+		r := Recursion{Name: ls.Name, Func: ls.Value.(*ast.FunctionLiteral)}
+		self := info.Defs[ls.Name]
+		ast.Inspect(r.Func.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral:
+				return false
+			case *ast.CallExpression:
+				if id, ok := n.Function.(*ast.Identifier); ok && self != nil && info.Uses[id] == self && !tc.Set[n] {
+					r.Calls = append(r.Calls, n)
+				}
+			}
+			return true
+		})
+		if len(r.Calls) > 0 {
+			tc.NotTailRecursive = append(tc.NotTailRecursive, r)
+		}
+	}
+	return tc
+}
+
+// block marks the value of b, which is in tail position.
+func (tc *TailCalls) block(b *ast.BlockStatement) {
+	if b == nil || len(b.Statements) == 0 {
+		return
+	}
+	switch s := b.Statements[len(b.Statements)-1].(type) {
+	case *ast.ExpressionStatement:
+		tc.tail(s.Expression)
+	case *ast.BlockStatement:
+		tc.block(s)
+	}
+}
+
+// tail marks e, which is in tail position.
+func (tc *TailCalls) tail(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.CallExpression:
+		tc.Set[e] = true
+	case *ast.IfExpression:
+		tc.block(e.Consequence)
+		tc.block(e.Alternative)
+	}
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"testing"
+
+	"monkey/parser"
+)
+
+func TestFindTailCalls(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			input: "let f = fn(x) { g(x) };",
+			want:  []string{"g(x)"},
+		},
+		{
+			input: "let f = fn(x) { g(x); h(x) };",
+			want:  []string{"h(x)"},
+		},
+		{
+			// The operands of an expression are used after they're computed.
+			input: "let f = fn(x) { 1 + g(x) };",
+		},
+		{
+			input: "let f = fn(x) { g(h(x)) };",
+			want:  []string{"g(h(x))"},
+		},
+		{
+			input: "let f = fn(x) { if (g(x)) { h(x) } else { k(x) } };",
+			want:  []string{"h(x)", "k(x)"},
+		},
+		{
+			input: "let f = fn(x) { if (x) { return g(x); } let y = h(x); y };",
+			want:  []string{"g(x)"},
+		},
+		{
+			input: "let f = fn(x) { if (x) { if (x) { g(x) } } else { h(x) } };",
+			want:  []string{"g(x)", "h(x)"},
+		},
+		{
+			// The if expression isn't in tail position.
+			input: "let f = fn(x) { if (x) { g(x) }; x };",
+		},
+		{
+			input: "let f = fn(x) { let y = g(x); y };",
+		},
+		{
+			// Calls at the top level are outside any function.
+			input: "g(1); if (true) { h(2) }",
+		},
+		{
+			input: "let f = fn(x) { fn(y) { g(y) }(x) };",
+			// A call's position is its parenthesis.
+			want: []string{"g(y)", "fn(y) {\ng(y);\n}(x)"},
+		},
+		{
+			input: "let f = fn(x) { let k = fn(y) { g(y) }; k(x) + 1 };",
+			want:  []string{"g(y)"},
+		},
+	}
+
+	for i, tt := range tests {
+		tc := FindTailCalls(parser.MustParse(tt.input))
+		var got []string
+		for _, ce := range tc.Calls() {
+			got = append(got, ce.String())
+			if !tc.IsTail(ce) {
+				t.Errorf("%d. IsTail(%s) = false, want true", i, ce)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%d. FindTailCalls(%q) = %q, want %q", i, tt.input, got, tt.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.want[j] {
+				t.Errorf("%d. FindTailCalls(%q) = %q, want %q", i, tt.input, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestNotTailRecursive(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			input: "let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc * n) } };",
+		},
+		{
+			input: "let loop = fn(n) { if (n == 0) { return 0; } return loop(n - 1); };",
+		},
+		{
+			input: "let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };",
+			want:  []string{"1:5: fact isn't tail recursive: the call at 1:53 isn't a tail call"},
+		},
+		{
+			input: "let fib = fn(n) {\n  if (n < 2) { return n; }\n  fib(n - 1) + fib(n - 2)\n};",
+			want:  []string{"1:5: fib isn't tail recursive: the call at 3:6 isn't a tail call"},
+		},
+		{
+			input: "let f = fn(n) { f(f(n)) };\nlet g = fn(n) { let x = g(n); x };",
+			want: []string{
+				"1:5: f isn't tail recursive: the call at 1:20 isn't a tail call",
+				"2:5: g isn't tail recursive: the call at 2:26 isn't a tail call",
+			},
+		},
+		{
+			// The nested function calls f, not f itself.
+			input: "let f = fn(n) { let g = fn() { 1 + f(n) }; g };",
+		},
+		{
+			// The inner f is another function.
+			input: "let f = fn(n) { let f = fn(m) { m }; f(n) + 1 };",
+		},
+	}
+
+	for i, tt := range tests {
+		tc := FindTailCalls(parser.MustParse(tt.input))
+		var got []string
+		for _, r := range tc.NotTailRecursive {
+			got = append(got, r.String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%d. NotTailRecursive(%q) = %q, want %q", i, tt.input, got, tt.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.want[j] {
+				t.Errorf("%d. NotTailRecursive(%q) = %q, want %q", i, tt.input, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package anf rewrites monkey programs into A-normal form, in which every
+// intermediate value has a name.
+//
+// The operands of prefix and infix expressions, the function and arguments
+// of calls, and the conditions of if expressions are atoms: identifiers,
+// integer and boolean literals, and function literals. Any other operand is
+// computed by a let statement before the statement it's in, and bound to a
+// new name, so
+//
+//	let y = f(x + 1) * g(2);
+//
+// becomes
+//
+//	let anf_a = (x + 1);
+//	let anf_b = f(anf_a);
+//	let anf_c = g(2);
+//	let y = (anf_b * anf_c);
+//
+// The let statements are in the order the operands were evaluated in, and
+// in the same block as the statement, so the names they use mean what they
+// did. The branches of an if expression are only evaluated when they're
+// taken, so they stay in their blocks, rewritten in turn; function bodies
+// are rewritten too.
+//
+// The new names all start with a prefix which no name in the program starts
+// with: "anf_", or that with more underscores.
+package anf
+
+import (
+	"monkey/ast"
+	"monkey/names"
+	"monkey/token"
+)
+
+// Convert returns a copy of prog in A-normal form.
+func Convert(prog *ast.Program) *ast.Program {
+	c := &converter{names: names.New(prog, "anf_")}
+	return &ast.Program{Statements: c.statements(prog.Statements)}
+}
+
+type converter struct {
+	names *names.Namer
+}
+
+func (c *converter) statements(stmts []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for _, s := range stmts {
+		out = c.statement(out, s)
+	}
+	return out
+}
+
+// statement appends s, and the let statements it needs first, to out.
+func (c *converter) statement(out []ast.Statement, s ast.Statement) []ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		var value ast.Expression
+		if s.Value != nil {
+			out, value = c.expression(out, s.Value)
+		}
+		return append(out, &ast.LetStatement{Token: s.Token, Name: copyIdent(s.Name), Type: s.Type, Value: value})
+	case *ast.ReturnStatement:
+		var value ast.Expression
+		if s.ReturnValue != nil {
+			out, value = c.expression(out, s.ReturnValue)
+		}
+		return append(out, &ast.ReturnStatement{Token: s.Token, ReturnValue: value})
+	case *ast.ExpressionStatement:
+		var e ast.Expression
+		if s.Expression != nil {
+			out, e = c.expression(out, s.Expression)
+		}
+		return append(out, &ast.ExpressionStatement{Token: s.Token, Expression: e})
+	case *ast.BlockStatement:
+		return append(out, c.block(s))
+	}
+	return append(out, s)
+}
+
+func (c *converter) block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return &ast.BlockStatement{Token: b.Token, Statements: c.statements(b.Statements)}
+}
+
+// expression rewrites e so that its operands are atoms, appending the let
+// statements they need to out.
+func (c *converter) expression(out []ast.Statement, e ast.Expression) ([]ast.Statement, ast.Expression) {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		out, right := c.atom(out, e.Right)
+		return out, &ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: right}
+	case *ast.InfixExpression:
+		out, left := c.atom(out, e.Left)
+		out, right := c.atom(out, e.Right)
+		return out, &ast.InfixExpression{Token: e.Token, Left: left, Operator: e.Operator, Right: right}
+	case *ast.IfExpression:
+		out, cond := c.atom(out, e.Condition)
+		return out, &ast.IfExpression{
+			Token:       e.Token,
+			Condition:   cond,
+			Consequence: c.block(e.Consequence),
+			Alternative: c.block(e.Alternative),
+		}
+	case *ast.CallExpression:
+		out, fn := c.atom(out, e.Function)
+		var args []ast.Expression
+		for _, a := range e.Arguments {
+			var arg ast.Expression
+			out, arg = c.atom(out, a)
+			args = append(args, arg)
+		}
+		return out, &ast.CallExpression{Token: e.Token, Function: fn, Arguments: args}
+	case *ast.FunctionLiteral:
+		var params []*ast.Identifier
+		for _, p := range e.Parameters {
+			params = append(params, copyIdent(p))
+		}
+		return out, &ast.FunctionLiteral{Token: e.Token, Parameters: params, ReturnType: e.ReturnType, Body: c.block(e.Body)}
+	case *ast.Identifier:
+		return out, copyIdent(e)
+	case *ast.IntegerLiteral:
+		return out, &ast.IntegerLiteral{Token: e.Token, Value: e.Value}
+	case *ast.Boolean:
+		return out, &ast.Boolean{Token: e.Token, Value: e.Value}
+	}
+	return out, e
+}
+
+// atom rewrites e as an atom, binding it to a new name if it isn't one.
+func (c *converter) atom(out []ast.Statement, e ast.Expression) ([]ast.Statement, ast.Expression) {
+	out, e = c.expression(out, e)
+	if IsAtom(e) {
+		return out, e
+	}
+	name := c.names.Next()
+	out = append(out, &ast.LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let", Pos: e.Pos()},
+		Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: e.Pos()}, Value: name},
+		Value: e,
+	})
+	return out, &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: e.Pos()}, Value: name}
+}
+
+// IsAtom reports whether e is an identifier, a literal or a function
+// literal, which is already a value.
+func IsAtom(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	}
+	return false
+}
+
+func copyIdent(id *ast.Identifier) *ast.Identifier {
+	if id == nil {
+		return nil
+	}
+	return &ast.Identifier{Token: id.Token, Value: id.Value, Type: id.Type}
+}
@@ -0,0 +1,146 @@
+package anf
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/cgen"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+// programs are monkey programs to convert.
+var programs = []string{
+	"1 + 2 * 3",
+	"let y = f(x + 1) * g(2);",
+	"-f(1)",
+	"if (a < b) { a } else { b }",
+	"if (f(a) == g(b)) { 1 }",
+	"f(if (c) { 1 } else { 2 }, g(h(3)))",
+	"let r = 1 + if (c) { let z = g(1) * 2; z } else { 3 };",
+	"let f = fn(x) { return x * f(x - 1) + 1; }; f",
+	"fn(x) { x }(1 + 2)(3 * 4)",
+	"let f = fn(x: int) -> int { x * x + 1 };",
+	"let anf_x = 1; anf_x + f(2)",
+	"",
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2", "(1 + 2);"},
+		{"1 + 2 * 3", "let anf_a = (2 * 3);(1 + anf_a);"},
+		{
+			input: "let y = f(x + 1) * g(2);",
+			want:  "let anf_a = (x + 1);let anf_b = f(anf_a);let anf_c = g(2);let y = (anf_b * anf_c);",
+		},
+		{"-f(-1)", "let anf_a = (-1);let anf_b = f(anf_a);(-anf_b);"},
+		{
+			input: "if (f(a) == b) { g(h(1)) }",
+			want:  "let anf_a = f(a);let anf_b = (anf_a == b);if (anf_b) {\nlet anf_c = h(1);\ng(anf_c);\n};",
+		},
+		{
+			input: "f(if (c) { 1 } else { 2 })",
+			want:  "let anf_a = if (c) {\n1;\n} else {\n2;\n};f(anf_a);",
+		},
+		{
+			input: "let f = fn(x) { return g(x) + 1; };",
+			want:  "let f = fn(x) {\nlet anf_a = g(x);\nreturn (anf_a + 1);\n};",
+		},
+		{"fn(x) { x }(1)(2)", "let anf_a = fn(x) {\nx;\n}(1);anf_a(2);"},
+		{"let anf_a = 1; anf_a + f(2)", "let anf_a = 1;let anf__a = f(2);(anf_a + anf__a);"},
+	}
+	for i, tc := range tests {
+		if got := Convert(parser.MustParse(tc.input)).String(); got != tc.want {
+			t.Errorf("%d. Convert(%q) = %q, want %q", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+// TestConvertReparses checks the converted programs print as monkey which
+// parses to a program in A-normal form.
+func TestConvertReparses(t *testing.T) {
+	for i, input := range programs {
+		src := Convert(parser.MustParse(input)).String()
+		p := parser.New(lexer.New(src))
+		prog := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%d. Convert(%q) = %q, which doesn't parse: %v", i, input, src, errs)
+			continue
+		}
+		ast.Inspect(prog, func(n ast.Node) bool {
+			var operands []ast.Expression
+			switch n := n.(type) {
+			case *ast.PrefixExpression:
+				operands = append(operands, n.Right)
+			case *ast.InfixExpression:
+				operands = append(operands, n.Left, n.Right)
+			case *ast.IfExpression:
+				operands = append(operands, n.Condition)
+			case *ast.CallExpression:
+				operands = append(append(operands, n.Function), n.Arguments...)
+			}
+			for _, e := range operands {
+				if !IsAtom(e) {
+					t.Errorf("%d. Convert(%q) = %q, in which %s isn't an atom", i, input, src, e)
+				}
+			}
+			return true
+		})
+		if again := Convert(prog).String(); again != src {
+			t.Errorf("%d. Convert(Convert(%q)) = %q, want %q", i, input, again, src)
+		}
+	}
+}
+
+// TestRun checks converted programs evaluate to what the programs did, by
+// compiling both to C, if there is a C compiler.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc not found")
+	}
+	dir := t.TempDir()
+	run := func(prog *ast.Program) string {
+		src, err := cgen.Generate(prog)
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", prog, err)
+		}
+		file := filepath.Join(dir, "prog.c")
+		if err := os.WriteFile(file, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+		exe := filepath.Join(dir, "prog")
+		if out, err := exec.Command(cc, "-o", exe, file).CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", cc, err, out)
+		}
+		// The errors programs fail with are part of what they do.
+		out, _ := exec.Command(exe).CombinedOutput()
+		return strings.TrimSpace(string(out))
+	}
+	tests := []string{
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(20)",
+		"let adder = fn(x) { fn(y) { x + y } }; adder(2)(40) * -adder(1)(1)",
+		"let sign = fn(n) { if (n < 0) { return -1; }; if (n == 0) { 0 } else { 1 } }; sign(-5) + sign(0) * 10 + sign(7) * 100",
+		"let f = fn(x) { let y = if (x) { return 1; 2 } else { 3 }; y }; f(true) * 10 + f(false)",
+		"let x = 1; let x = x + if (x > 0) { let x = 10; x * 2 } else { 0 }; x",
+		"let f = fn(n) { if (n == 0) { 1 + true } else { n } }; f(1) + f(0)",
+		"let compose = fn(f, g) { fn(x) { f(g(x)) } }; compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)",
+	}
+	for i, input := range tests {
+		prog := parser.MustParse(input)
+		want := run(prog)
+		if got := run(Convert(prog)); got != want {
+			t.Errorf("%d. %q printed %s, converted to %s, which printed %s", i, input, want, Convert(prog), got)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"monkey/token"
@@ -12,6 +13,8 @@ import (
 // Node is a single node in the AST.
 type Node interface {
 	TokenLiteral() string
+	// Pos is the position of the node's token.
+	Pos() token.Pos
 	fmt.Stringer
 }
 
@@ -27,7 +30,17 @@ type Expression interface {
 	expressionNode()
 }
 
+// TypeExpression is a type annotation, such as the int in "let x: int = 5".
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
 // Program is the top-level program.
+//
+// The String methods of every node print a canonical form of the tree:
+// parsing the output of Program.String() yields a structurally identical
+// program.
 type Program struct {
 	Statements []Statement
 }
@@ -39,6 +52,13 @@ func (p *Program) TokenLiteral() string {
 	return p.Statements[0].TokenLiteral()
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) == 0 {
+		return token.Pos{}
+	}
+	return p.Statements[0].Pos()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -51,16 +71,21 @@ func (p *Program) String() string {
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
+	Type  TypeExpression // optional
 	Value Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Pos       { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(ls.TokenLiteral())
-	out.WriteString(" ")
+	out.WriteString("let ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil { // XXX
 		out.WriteString(ls.Value.String())
@@ -73,10 +98,12 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+	Type  TypeExpression // optional, for function parameters
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Pos       { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 type ReturnStatement struct {
@@ -86,10 +113,10 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Pos       { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(rs.TokenLiteral())
-	out.WriteString(" ")
+	out.WriteString("return ")
 	if rs.ReturnValue != nil { // XXX
 		out.WriteString(rs.ReturnValue.String())
 	}
@@ -104,6 +131,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Pos       { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression == nil { // XXX
 		return ""
@@ -118,7 +146,8 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
-func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Pos       { return il.Token.Pos }
+func (il *IntegerLiteral) String() string       { return strconv.FormatInt(il.Value, 10) }
 
 type PrefixExpression struct {
 	Token    token.Token // the prefix token, e.g. !
@@ -128,6 +157,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Pos       { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -146,6 +176,7 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Pos       { return ie.Token.Pos }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -165,7 +196,8 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
-func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Pos       { return b.Token.Pos }
+func (b *Boolean) String() string       { return strconv.FormatBool(b.Value) }
 
 type BlockStatement struct {
 	Token      token.Token // the "{" token
@@ -174,6 +206,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Pos       { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("{\n")
@@ -194,14 +227,23 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Pos       { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
+	out.WriteString("if ")
+	switch ie.Condition.(type) {
+	case *PrefixExpression, *InfixExpression:
+		// Already wrapped in parens.
+		out.WriteString(ie.Condition.String())
+	default:
+		out.WriteString("(")
+		out.WriteString(ie.Condition.String())
+		out.WriteString(")")
+	}
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
 	if ie.Alternative != nil {
-		out.WriteString("else ")
+		out.WriteString(" else ")
 		out.WriteString(ie.Alternative.String())
 	}
 	return out.String()
@@ -210,21 +252,31 @@ func (ie *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // the "fn" token
 	Parameters []*Identifier
+	ReturnType TypeExpression // optional
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Pos       { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	var params []string
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		if p.Type != nil {
+			params = append(params, p.String()+": "+p.Type.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
-	out.WriteString(fl.TokenLiteral())
-	out.WriteString("(")
+	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> ")
+		out.WriteString(fl.ReturnType.String())
+		out.WriteString(" ")
+	}
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
@@ -237,6 +289,7 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Pos       { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	var args []string
@@ -249,3 +302,37 @@ func (ce *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// NamedType is a type written as a name, such as int or bool.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Pos       { return nt.Token.Pos }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// FunctionType is the type of a function, such as fn(int, int) -> bool.
+type FunctionType struct {
+	Token  token.Token // the "fn" token
+	Params []TypeExpression
+	Result TypeExpression
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Pos       { return ft.Token.Pos }
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") -> ")
+	out.WriteString(ft.Result.String())
+	return out.String()
+}
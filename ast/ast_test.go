@@ -33,3 +33,40 @@ func TestString(t *testing.T) {
 		t.Errorf("prog.String() = %q, want %q", got, want)
 	}
 }
+
+func TestStringTypes(t *testing.T) {
+	intType := &NamedType{Token: tok(token.IDENT, "int"), Name: "int"}
+	param := &Identifier{Token: tok(token.IDENT, "x"), Value: "x", Type: intType}
+	fnType := &FunctionType{
+		Token:  tok(token.FUNCTION, "fn"),
+		Params: []TypeExpression{intType},
+		Result: &NamedType{Token: tok(token.IDENT, "bool"), Name: "bool"},
+	}
+	prog := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: tok(token.LET, "let"),
+				Name:  &Identifier{Token: tok(token.IDENT, "f"), Value: "f"},
+				Type:  fnType,
+				Value: &FunctionLiteral{
+					Token:      tok(token.FUNCTION, "fn"),
+					Parameters: []*Identifier{param},
+					ReturnType: fnType.Result,
+					Body: &BlockStatement{
+						Token: tok(token.LBRACE, "{"),
+						Statements: []Statement{
+							&ExpressionStatement{Token: tok(token.TRUE, "true"), Expression: &Boolean{Token: tok(token.TRUE, "true"), Value: true}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if got, want := prog.String(), "let f: fn(int) -> bool = fn(x: int) -> bool {\ntrue;\n};"; got != want {
+		t.Errorf("prog.String() = %q, want %q", got, want)
+	}
+	// The parameter's own String is just its name.
+	if got, want := param.String(), "x"; got != want {
+		t.Errorf("param.String() = %q, want %q", got, want)
+	}
+}
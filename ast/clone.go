@@ -0,0 +1,116 @@
+package ast
+
+// Clone returns a deep copy of the tree rooted at node: no node or slice of
+// the copy is shared with the original, so either may be changed without
+// affecting the other. Tokens are copied too, so the copy has the original's
+// positions.
+func Clone(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(n.Statements)}
+	case Statement:
+		return cloneStatement(n)
+	case Expression:
+		return cloneExpression(n)
+	case TypeExpression:
+		return cloneType(n)
+	}
+	return node
+}
+
+func cloneStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	out := make([]Statement, len(stmts))
+	for i, s := range stmts {
+		out[i] = cloneStatement(s)
+	}
+	return out
+}
+
+func cloneStatement(s Statement) Statement {
+	switch s := s.(type) {
+	case *LetStatement:
+		return &LetStatement{Token: s.Token, Name: cloneIdent(s.Name), Type: cloneType(s.Type), Value: cloneExpression(s.Value)}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: s.Token, ReturnValue: cloneExpression(s.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: s.Token, Expression: cloneExpression(s.Expression)}
+	case *BlockStatement:
+		return cloneBlock(s)
+	}
+	return s
+}
+
+func cloneBlock(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return &BlockStatement{Token: b.Token, Statements: cloneStatements(b.Statements)}
+}
+
+func cloneIdent(id *Identifier) *Identifier {
+	if id == nil {
+		return nil
+	}
+	return &Identifier{Token: id.Token, Value: id.Value, Type: cloneType(id.Type)}
+}
+
+func cloneExpression(e Expression) Expression {
+	switch e := e.(type) {
+	case *Identifier:
+		return cloneIdent(e)
+	case *IntegerLiteral:
+		return &IntegerLiteral{Token: e.Token, Value: e.Value}
+	case *Boolean:
+		return &Boolean{Token: e.Token, Value: e.Value}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: e.Token, Operator: e.Operator, Right: cloneExpression(e.Right)}
+	case *InfixExpression:
+		return &InfixExpression{Token: e.Token, Left: cloneExpression(e.Left), Operator: e.Operator, Right: cloneExpression(e.Right)}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       e.Token,
+			Condition:   cloneExpression(e.Condition),
+			Consequence: cloneBlock(e.Consequence),
+			Alternative: cloneBlock(e.Alternative),
+		}
+	case *FunctionLiteral:
+		var params []*Identifier
+		if e.Parameters != nil {
+			params = make([]*Identifier, len(e.Parameters))
+			for i, p := range e.Parameters {
+				params[i] = cloneIdent(p)
+			}
+		}
+		return &FunctionLiteral{Token: e.Token, Parameters: params, ReturnType: cloneType(e.ReturnType), Body: cloneBlock(e.Body)}
+	case *CallExpression:
+		var args []Expression
+		if e.Arguments != nil {
+			args = make([]Expression, len(e.Arguments))
+			for i, a := range e.Arguments {
+				args[i] = cloneExpression(a)
+			}
+		}
+		return &CallExpression{Token: e.Token, Function: cloneExpression(e.Function), Arguments: args}
+	}
+	return e
+}
+
+func cloneType(t TypeExpression) TypeExpression {
+	switch t := t.(type) {
+	case *NamedType:
+		return &NamedType{Token: t.Token, Name: t.Name}
+	case *FunctionType:
+		var params []TypeExpression
+		if t.Params != nil {
+			params = make([]TypeExpression, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = cloneType(p)
+			}
+		}
+		return &FunctionType{Token: t.Token, Params: params, Result: cloneType(t.Result)}
+	}
+	return t
+}
@@ -0,0 +1,123 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"monkey/token"
+)
+
+// sample returns a new tree with every type of node:
+//
+//	let f: fn(int) bool = fn(x: int) bool { if (!x) { return g(x, 1) < 2; } else { true } };
+func sample() *Program {
+	m := &maker{line: 1}
+	named := func(name string) *NamedType { return &NamedType{Token: m.tok(token.IDENT, name), Name: name} }
+	x := m.ident("x")
+	x.Type = named("int")
+	call := &CallExpression{Token: m.tok(token.LPAREN, "("), Function: m.ident("g"), Arguments: []Expression{m.ident("x"), m.int(1, "1")}}
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Token: m.tok(token.LET, "let"),
+			Name:  m.ident("f"),
+			Type:  &FunctionType{Token: m.tok(token.FUNCTION, "fn"), Params: []TypeExpression{named("int")}, Result: named("bool")},
+			Value: &FunctionLiteral{
+				Token:      m.tok(token.FUNCTION, "fn"),
+				Parameters: []*Identifier{x},
+				ReturnType: named("bool"),
+				Body: m.block(m.expr(&IfExpression{
+					Token:       m.tok(token.IF, "if"),
+					Condition:   &PrefixExpression{Token: m.tok(token.BANG, "!"), Operator: "!", Right: m.ident("x")},
+					Consequence: m.block(&ReturnStatement{Token: m.tok(token.RETURN, "return"), ReturnValue: m.infix("<", call, m.int(2, "2"))}),
+					Alternative: m.block(m.expr(&Boolean{Token: m.tok(token.TRUE, "true"), Value: true})),
+				})),
+			},
+		},
+	}}
+}
+
+func TestClone(t *testing.T) {
+	orig := sample()
+	clone := Clone(orig)
+	if !reflect.DeepEqual(orig, clone) {
+		t.Fatalf("Clone(%v) = %v", orig, clone)
+	}
+
+	nodes := make(map[Node]bool)
+	types := make(map[string]bool)
+	Inspect(orig, func(n Node) bool {
+		nodes[n] = true
+		types[typeName(n)] = true
+		return true
+	})
+	if len(types) != 15 {
+		t.Errorf("sample has %d types of node, want all 15", len(types))
+	}
+	Inspect(clone, func(n Node) bool {
+		if nodes[n] {
+			t.Errorf("Clone shares %T %v with the original", n, n)
+		}
+		return true
+	})
+
+	// Change every node of the clone, and every slice in it in place.
+	Inspect(clone, func(n Node) bool {
+		switch n := n.(type) {
+		case *Program:
+			n.Statements[0] = &ExpressionStatement{}
+		case *LetStatement:
+			n.Token.Literal = "var"
+			n.Name = nil
+		case *Identifier:
+			n.Value += "_"
+			n.Token.Pos.Line = 9
+		case *ReturnStatement:
+			n.ReturnValue = nil
+		case *ExpressionStatement:
+			n.Token.Pos = token.Pos{}
+		case *IntegerLiteral:
+			n.Value = -n.Value
+		case *Boolean:
+			n.Value = !n.Value
+		case *PrefixExpression:
+			n.Operator = "-"
+		case *InfixExpression:
+			n.Operator = ">"
+		case *BlockStatement:
+			n.Statements[0] = nil
+		case *IfExpression:
+			n.Alternative = nil
+		case *FunctionLiteral:
+			n.Parameters[0] = nil
+		case *CallExpression:
+			n.Arguments[1] = nil
+			n.Function = nil
+		case *NamedType:
+			n.Name = "string"
+		case *FunctionType:
+			n.Params[0] = nil
+		}
+		return true
+	})
+	if !reflect.DeepEqual(orig, sample()) {
+		t.Errorf("changing the clone changed the original to %v", orig)
+	}
+}
+
+func TestCloneNil(t *testing.T) {
+	tests := []Node{
+		nil,
+		&Program{},
+		&BlockStatement{},
+		&LetStatement{Name: ident("x")},
+		&IfExpression{Condition: ident("x")},
+		&FunctionLiteral{},
+		&CallExpression{Function: ident("f")},
+		&FunctionType{},
+	}
+	for i, n := range tests {
+		if got := Clone(n); !reflect.DeepEqual(got, n) {
+			t.Errorf("%d. Clone(%#v) = %#v", i, n, got)
+		}
+	}
+}
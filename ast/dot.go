@@ -0,0 +1,44 @@
+package ast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT writes the tree rooted at node to w as a Graphviz digraph. Each
+// node is labelled with its type and, for operators, names and literals,
+// its operator or value; each edge is labelled with the field of the parent
+// which holds the child, e.g. "Left" or "Arguments[0]".
+func WriteDOT(w io.Writer, node Node) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph ast {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	n := 0
+	var write func(node Node) string
+	write = func(node Node) string {
+		id := fmt.Sprintf("n%d", n)
+		n++
+		fmt.Fprintf(bw, "\t%s [label=%s];\n", id, strconv.Quote(dotLabel(node)))
+		for _, f := range fields(node) {
+			child := write(f.node)
+			fmt.Fprintf(bw, "\t%s -> %s [label=%s];\n", id, child, strconv.Quote(f.name))
+		}
+		return id
+	}
+	if node != nil {
+		write(node)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotLabel returns the label of node in WriteDOT's output.
+func dotLabel(node Node) string {
+	label := typeName(node)
+	if v := value(node); v != "" {
+		label += "\n" + v
+	}
+	return label
+}
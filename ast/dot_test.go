@@ -0,0 +1,143 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"monkey/token"
+)
+
+func TestWriteDOT(t *testing.T) {
+	tests := []struct {
+		node Node
+		want string
+	}{
+		{
+			// 1 + 2 * 3
+			node: &Program{Statements: []Statement{
+				&ExpressionStatement{
+					Token: tok(token.INT, "1"),
+					Expression: &InfixExpression{
+						Token:    tok(token.PLUS, "+"),
+						Left:     &IntegerLiteral{Token: tok(token.INT, "1"), Value: 1},
+						Operator: "+",
+						Right: &InfixExpression{
+							Token:    tok(token.ASTERISK, "*"),
+							Left:     &IntegerLiteral{Token: tok(token.INT, "2"), Value: 2},
+							Operator: "*",
+							Right:    &IntegerLiteral{Token: tok(token.INT, "3"), Value: 3},
+						},
+					},
+				},
+			}},
+			want: `digraph ast {
+	node [shape=box];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement"];
+	n2 [label="InfixExpression\n+"];
+	n3 [label="IntegerLiteral\n1"];
+	n2 -> n3 [label="Left"];
+	n4 [label="InfixExpression\n*"];
+	n5 [label="IntegerLiteral\n2"];
+	n4 -> n5 [label="Left"];
+	n6 [label="IntegerLiteral\n3"];
+	n4 -> n6 [label="Right"];
+	n2 -> n4 [label="Right"];
+	n1 -> n2 [label="Expression"];
+	n0 -> n1 [label="Statements[0]"];
+}
+`,
+		},
+		{
+			// if (!ok) { f(x, true) }
+			node: &IfExpression{
+				Token:     tok(token.IF, "if"),
+				Condition: &PrefixExpression{Token: tok(token.BANG, "!"), Operator: "!", Right: ident("ok")},
+				Consequence: &BlockStatement{
+					Token: tok(token.LBRACE, "{"),
+					Statements: []Statement{
+						&ExpressionStatement{
+							Token: tok(token.IDENT, "f"),
+							Expression: &CallExpression{
+								Token:     tok(token.LPAREN, "("),
+								Function:  ident("f"),
+								Arguments: []Expression{ident("x"), &Boolean{Token: tok(token.TRUE, "true"), Value: true}},
+							},
+						},
+					},
+				},
+			},
+			want: `digraph ast {
+	node [shape=box];
+	n0 [label="IfExpression"];
+	n1 [label="PrefixExpression\n!"];
+	n2 [label="Identifier\nok"];
+	n1 -> n2 [label="Right"];
+	n0 -> n1 [label="Condition"];
+	n3 [label="BlockStatement"];
+	n4 [label="ExpressionStatement"];
+	n5 [label="CallExpression"];
+	n6 [label="Identifier\nf"];
+	n5 -> n6 [label="Function"];
+	n7 [label="Identifier\nx"];
+	n5 -> n7 [label="Arguments[0]"];
+	n8 [label="Boolean\ntrue"];
+	n5 -> n8 [label="Arguments[1]"];
+	n4 -> n5 [label="Expression"];
+	n3 -> n4 [label="Statements[0]"];
+	n0 -> n3 [label="Consequence"];
+}
+`,
+		},
+		{
+			// let f: fn(int) int = fn(n: int) { n };
+			node: &LetStatement{
+				Token: tok(token.LET, "let"),
+				Name:  ident("f"),
+				Type: &FunctionType{
+					Token:  tok(token.FUNCTION, "fn"),
+					Params: []TypeExpression{&NamedType{Token: tok(token.IDENT, "int"), Name: "int"}},
+					Result: &NamedType{Token: tok(token.IDENT, "int"), Name: "int"},
+				},
+				Value: &FunctionLiteral{
+					Token: tok(token.FUNCTION, "fn"),
+					Parameters: []*Identifier{
+						{Token: tok(token.IDENT, "n"), Value: "n", Type: &NamedType{Token: tok(token.IDENT, "int"), Name: "int"}},
+					},
+					Body: &BlockStatement{Token: tok(token.LBRACE, "{")},
+				},
+			},
+			want: `digraph ast {
+	node [shape=box];
+	n0 [label="LetStatement"];
+	n1 [label="Identifier\nf"];
+	n0 -> n1 [label="Name"];
+	n2 [label="FunctionType"];
+	n3 [label="NamedType\nint"];
+	n2 -> n3 [label="Params[0]"];
+	n4 [label="NamedType\nint"];
+	n2 -> n4 [label="Result"];
+	n0 -> n2 [label="Type"];
+	n5 [label="FunctionLiteral"];
+	n6 [label="Identifier\nn"];
+	n7 [label="NamedType\nint"];
+	n6 -> n7 [label="Type"];
+	n5 -> n6 [label="Parameters[0]"];
+	n8 [label="BlockStatement"];
+	n5 -> n8 [label="Body"];
+	n0 -> n5 [label="Value"];
+}
+`,
+		},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteDOT(&buf, tt.node); err != nil {
+			t.Fatalf("%d. WriteDOT: %v", i, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%d. WriteDOT(%s) =\n%s\nwant\n%s", i, tt.node, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Equal reports whether a and b are the same tree: nodes of the same types,
+// with the same operators, names and values, and equal children in the same
+// fields. Tokens are ignored, so are positions and the spelling of literals.
+func Equal(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if typeName(a) != typeName(b) || value(a) != value(b) {
+		return false
+	}
+	fa, fb := fields(a), fields(b)
+	if len(fa) != len(fb) {
+		return false
+	}
+	for i := range fa {
+		if fa[i].name != fb[i].name || !Equal(fa[i].node, fb[i].node) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a hash of the tree rooted at node. Trees which are Equal have
+// the same hash, which doesn't change between runs.
+func Hash(node Node) uint64 {
+	h := fnv.New64a()
+	var write func(node Node)
+	write = func(node Node) {
+		if node == nil {
+			h.Write([]byte{0})
+			return
+		}
+		// Each string ends with a zero byte, and each node with an empty
+		// field name, so different trees write different bytes.
+		for _, s := range []string{typeName(node), value(node)} {
+			h.Write(append([]byte(s), 0))
+		}
+		for _, f := range fields(node) {
+			h.Write(append([]byte(f.name), 0))
+			write(f.node)
+		}
+		h.Write([]byte{0})
+	}
+	write(node)
+	return h.Sum64()
+}
+
+// typeName returns the name of node's type, e.g. "InfixExpression".
+func typeName(node Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// value returns the operator, name or value of node, or "" if it has none
+// besides its children.
+func value(node Node) string {
+	switch n := node.(type) {
+	case *Identifier:
+		return n.Value
+	case *IntegerLiteral:
+		return strconv.FormatInt(n.Value, 10)
+	case *Boolean:
+		return strconv.FormatBool(n.Value)
+	case *PrefixExpression:
+		return n.Operator
+	case *InfixExpression:
+		return n.Operator
+	case *NamedType:
+		return n.Name
+	}
+	return ""
+}
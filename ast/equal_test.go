@@ -0,0 +1,168 @@
+package ast
+
+import (
+	"testing"
+
+	"monkey/token"
+)
+
+// maker makes nodes whose tokens are at successive columns of a line, so
+// trees made by different makers differ in their positions.
+type maker struct {
+	line, col int
+}
+
+func (m *maker) tok(typ token.Type, lit string) token.Token {
+	m.col++
+	return token.Token{Type: typ, Literal: lit, Pos: token.Pos{Line: m.line, Col: m.col}}
+}
+
+func (m *maker) ident(name string) *Identifier {
+	return &Identifier{Token: m.tok(token.IDENT, name), Value: name}
+}
+
+func (m *maker) int(n int64, lit string) *IntegerLiteral {
+	return &IntegerLiteral{Token: m.tok(token.INT, lit), Value: n}
+}
+
+func (m *maker) infix(op string, l, r Expression) *InfixExpression {
+	return &InfixExpression{Token: m.tok(token.Type(op), op), Left: l, Operator: op, Right: r}
+}
+
+func (m *maker) block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Token: m.tok(token.LBRACE, "{"), Statements: stmts}
+}
+
+func (m *maker) expr(e Expression) *ExpressionStatement {
+	return &ExpressionStatement{Token: m.tok(token.IDENT, e.TokenLiteral()), Expression: e}
+}
+
+func (m *maker) fn(params []string, body ...Statement) *FunctionLiteral {
+	fl := &FunctionLiteral{Token: m.tok(token.FUNCTION, "fn")}
+	for _, p := range params {
+		fl.Parameters = append(fl.Parameters, m.ident(p))
+	}
+	fl.Body = m.block(body...)
+	return fl
+}
+
+func (m *maker) let(name string, value Expression) *LetStatement {
+	return &LetStatement{Token: m.tok(token.LET, "let"), Name: m.ident(name), Value: value}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b func(m *maker) Node
+		want bool
+	}{
+		{
+			a:    func(m *maker) Node { return m.infix("+", m.ident("x"), m.int(1, "1")) },
+			b:    func(m *maker) Node { return m.infix("+", m.ident("x"), m.int(1, "1")) },
+			want: true,
+		},
+		{
+			// The spelling of a literal is trivia.
+			a:    func(m *maker) Node { return m.int(16, "16") },
+			b:    func(m *maker) Node { return m.int(16, "0x10") },
+			want: true,
+		},
+		{
+			a: func(m *maker) Node { return m.infix("+", m.ident("x"), m.int(1, "1")) },
+			b: func(m *maker) Node { return m.infix("-", m.ident("x"), m.int(1, "1")) },
+		},
+		{
+			a: func(m *maker) Node { return m.infix("+", m.ident("x"), m.int(1, "1")) },
+			b: func(m *maker) Node { return m.infix("+", m.int(1, "1"), m.ident("x")) },
+		},
+		{
+			a: func(m *maker) Node { return m.ident("x") },
+			b: func(m *maker) Node { return m.ident("y") },
+		},
+		{
+			a: func(m *maker) Node { return m.ident("true") },
+			b: func(m *maker) Node { return &Boolean{Token: m.tok(token.TRUE, "true"), Value: true} },
+		},
+		{
+			a: func(m *maker) Node {
+				return &Program{Statements: []Statement{
+					m.let("f", m.fn([]string{"x", "y"}, m.expr(m.infix("*", m.ident("x"), m.ident("y"))))),
+				}}
+			},
+			b: func(m *maker) Node {
+				return &Program{Statements: []Statement{
+					m.let("f", m.fn([]string{"x", "y"}, m.expr(m.infix("*", m.ident("x"), m.ident("y"))))),
+				}}
+			},
+			want: true,
+		},
+		{
+			// A parameter renamed.
+			a: func(m *maker) Node { return m.fn([]string{"x"}, m.expr(m.ident("x"))) },
+			b: func(m *maker) Node { return m.fn([]string{"y"}, m.expr(m.ident("x"))) },
+		},
+		{
+			a: func(m *maker) Node { return m.fn([]string{"x"}, m.expr(m.ident("x"))) },
+			b: func(m *maker) Node { return m.fn([]string{"x", "y"}, m.expr(m.ident("x"))) },
+		},
+		{
+			a: func(m *maker) Node { return m.fn(nil, m.expr(m.ident("x"))) },
+			b: func(m *maker) Node { return m.fn(nil, m.expr(m.ident("x")), m.expr(m.ident("x"))) },
+		},
+		{
+			// A let statement isn't an expression statement.
+			a: func(m *maker) Node { return m.block(m.let("x", m.ident("y"))) },
+			b: func(m *maker) Node { return m.block(m.expr(m.ident("y"))) },
+		},
+		{
+			// Without an else and with an empty one.
+			a: func(m *maker) Node {
+				return &IfExpression{Token: m.tok(token.IF, "if"), Condition: m.ident("x"), Consequence: m.block()}
+			},
+			b: func(m *maker) Node {
+				return &IfExpression{Token: m.tok(token.IF, "if"), Condition: m.ident("x"), Consequence: m.block(), Alternative: m.block()}
+			},
+		},
+		{
+			// The same child in different fields.
+			a: func(m *maker) Node {
+				return &LetStatement{Token: m.tok(token.LET, "let"), Name: m.ident("x"), Type: &NamedType{Token: m.tok(token.IDENT, "int"), Name: "int"}}
+			},
+			b: func(m *maker) Node {
+				return &LetStatement{Token: m.tok(token.LET, "let"), Name: m.ident("x"), Value: m.ident("int")}
+			},
+		},
+		{
+			a:    func(m *maker) Node { return nil },
+			b:    func(m *maker) Node { return nil },
+			want: true,
+		},
+		{
+			a: func(m *maker) Node { return m.ident("x") },
+			b: func(m *maker) Node { return nil },
+		},
+	}
+
+	for i, tt := range tests {
+		a, b := tt.a(&maker{line: 1}), tt.b(&maker{line: 7, col: 3})
+		if got := Equal(a, b); got != tt.want {
+			t.Errorf("%d. Equal(%v, %v) = %v, want %v", i, a, b, got, tt.want)
+		}
+		if got := Equal(b, a); got != tt.want {
+			t.Errorf("%d. Equal(%v, %v) = %v, want %v", i, b, a, got, tt.want)
+		}
+		if got := Hash(a) == Hash(b); got != tt.want {
+			t.Errorf("%d. Hash(%v) == Hash(%v) is %v, want %v", i, a, b, got, tt.want)
+		}
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	m := &maker{line: 1}
+	prog := &Program{Statements: []Statement{
+		m.let("f", m.fn([]string{"x"}, m.expr(m.infix("+", m.ident("x"), m.int(1, "1"))))),
+	}}
+	// The hash mustn't change between runs, as it may be stored.
+	if got, want := Hash(prog), uint64(0x1098807eb443f300); got != want {
+		t.Errorf("Hash(%v) = %#x, want %#x", prog, got, want)
+	}
+}
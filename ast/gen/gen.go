@@ -0,0 +1,228 @@
+// Package gen generates random monkey programs, for testing tools with many
+// different inputs.
+//
+// The programs are syntactically valid: printing one and parsing the result
+// gives back the same tree. They are not meant to make sense; names may be
+// undefined, and values of the wrong type.
+package gen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"monkey/ast"
+	"monkey/build"
+)
+
+// Weights are the relative chances of generating each kind of node where
+// it may appear. A weight of zero means never.
+type Weights struct {
+	// Statements.
+	Let, Return, Expr int
+	// Expressions. Only identifiers and literals appear at the greatest
+	// depth; if their weights are all zero, identifiers do.
+	Ident, Int, Bool, Prefix, Infix, If, Func, Call int
+}
+
+// Config controls the programs a Generator makes.
+type Config struct {
+	// Depth is the greatest nesting of expressions, blocks and types.
+	Depth int
+	// Statements is the greatest number of statements in a program, which
+	// has at least one, and in a block, which may be empty.
+	Statements int
+	// Args is the greatest number of parameters of a function and of
+	// arguments of a call.
+	Args int
+	// Annotate is the chance, from 0 to 1, of a type annotation on a let
+	// statement, parameter or function result.
+	Annotate float64
+	Weights  Weights
+	// Names are the identifiers used, and TypeNames the named types. If
+	// either is empty, DefaultConfig's are used.
+	Names     []string
+	TypeNames []string
+}
+
+// DefaultConfig makes small programs with every kind of node.
+var DefaultConfig = Config{
+	Depth:      4,
+	Statements: 4,
+	Args:       2,
+	Annotate:   0.25,
+	Weights: Weights{
+		Let: 1, Return: 1, Expr: 1,
+		Ident: 1, Int: 1, Bool: 1, Prefix: 1, Infix: 1, If: 1, Func: 1, Call: 1,
+	},
+	Names:     []string{"a", "b", "x", "y", "foo", "bar_baz", "Quux"},
+	TypeNames: []string{"int", "bool"},
+}
+
+var (
+	prefixOps = []string{"!", "-"}
+	infixOps  = []string{"+", "-", "*", "/", "<", ">", "==", "!="}
+)
+
+// Generator makes random programs. The programs it makes depend only on its
+// configuration and seed.
+type Generator struct {
+	cfg Config
+	r   *rand.Rand
+}
+
+// New returns a Generator which makes programs according to cfg, from seed.
+// It panics if one of the names isn't an identifier.
+func New(cfg Config, seed int64) *Generator {
+	if len(cfg.Names) == 0 {
+		cfg.Names = DefaultConfig.Names
+	}
+	if len(cfg.TypeNames) == 0 {
+		cfg.TypeNames = DefaultConfig.TypeNames
+	}
+	for _, names := range [][]string{cfg.Names, cfg.TypeNames} {
+		for _, name := range names {
+			if !build.IsIdent(name) {
+				panic(fmt.Sprintf("gen.New: %q isn't an identifier", name))
+			}
+		}
+	}
+	return &Generator{cfg: cfg, r: rand.New(rand.NewSource(seed))}
+}
+
+// Program returns a new program.
+func (g *Generator) Program() *ast.Program {
+	prog := build.Program()
+	for n := g.upTo(g.cfg.Statements-1) + 1; n > 0; n-- {
+		prog.Statements = append(prog.Statements, g.Statement(g.cfg.Depth))
+	}
+	return prog
+}
+
+// Statement returns a new statement whose expressions are nested at most
+// depth deep.
+func (g *Generator) Statement(depth int) ast.Statement {
+	w := g.cfg.Weights
+	switch g.pick(w.Let, w.Return, w.Expr) {
+	case 0:
+		name := g.name()
+		if g.annotate() {
+			return build.TypedLet(name, g.typ(depth), g.Expression(depth))
+		}
+		return build.Let(name, g.Expression(depth))
+	case 1:
+		return build.Return(g.Expression(depth))
+	}
+	return build.Expr(g.Expression(depth))
+}
+
+// Expression returns a new expression nested at most depth deep.
+func (g *Generator) Expression(depth int) ast.Expression {
+	w := g.cfg.Weights
+	weights := []int{w.Ident, w.Int, w.Bool, w.Prefix, w.Infix, w.If, w.Func, w.Call}
+	if depth <= 0 {
+		weights = weights[:3]
+	}
+	switch g.pick(weights...) {
+	case 1:
+		v := g.r.Int63()
+		if g.r.Intn(2) == 0 {
+			v %= 100
+		}
+		return build.Int(v)
+	case 2:
+		return build.Bool(g.r.Intn(2) == 0)
+	case 3:
+		return build.Prefix(prefixOps[g.r.Intn(len(prefixOps))], g.Expression(depth-1))
+	case 4:
+		op := infixOps[g.r.Intn(len(infixOps))]
+		left := g.Expression(depth - 1)
+		return build.Infix(op, left, g.Expression(depth-1))
+	case 5:
+		cond := g.Expression(depth - 1)
+		cons := g.block(depth - 1)
+		var alt *ast.BlockStatement
+		if g.r.Intn(2) == 0 {
+			alt = g.block(depth - 1)
+		}
+		return build.If(cond, cons, alt)
+	case 6:
+		var params []*ast.Identifier
+		for n := g.upTo(g.cfg.Args); n > 0; n-- {
+			if g.annotate() {
+				params = append(params, build.Param(g.name(), g.typ(depth-1)))
+			} else {
+				params = append(params, build.Ident(g.name()))
+			}
+		}
+		fl := build.Fn(params)
+		if g.annotate() {
+			fl.ReturnType = g.typ(depth - 1)
+		}
+		fl.Body = g.block(depth - 1)
+		return fl
+	case 7:
+		fn := g.Expression(depth - 1)
+		var args []ast.Expression
+		for n := g.upTo(g.cfg.Args); n > 0; n-- {
+			args = append(args, g.Expression(depth-1))
+		}
+		return build.Call(fn, args...)
+	}
+	return build.Ident(g.name())
+}
+
+func (g *Generator) block(depth int) *ast.BlockStatement {
+	b := build.Block()
+	for n := g.upTo(g.cfg.Statements - 1); n > 0; n-- {
+		b.Statements = append(b.Statements, g.Statement(depth))
+	}
+	return b
+}
+
+// typ returns a type nested at most depth deep.
+func (g *Generator) typ(depth int) ast.TypeExpression {
+	if depth <= 0 || g.r.Intn(3) != 0 {
+		return build.Type(g.cfg.TypeNames[g.r.Intn(len(g.cfg.TypeNames))])
+	}
+	var params []ast.TypeExpression
+	for n := g.upTo(g.cfg.Args); n > 0; n-- {
+		params = append(params, g.typ(depth-1))
+	}
+	return build.FuncType(params, g.typ(depth-1))
+}
+
+func (g *Generator) name() string {
+	return g.cfg.Names[g.r.Intn(len(g.cfg.Names))]
+}
+
+func (g *Generator) annotate() bool {
+	return g.cfg.Annotate > 0 && g.r.Float64() < g.cfg.Annotate
+}
+
+// upTo returns a number from 0 to n.
+func (g *Generator) upTo(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return g.r.Intn(n + 1)
+}
+
+// pick returns the index of one of weights, with their relative chances, or
+// 0 if they're all zero.
+func (g *Generator) pick(weights ...int) int {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := g.r.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package gen
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+// TestRoundTrip checks that the programs are valid: printing one and
+// parsing the result gives back the same tree.
+func TestRoundTrip(t *testing.T) {
+	deep := DefaultConfig
+	deep.Depth = 8
+	deep.Weights.Func = 3
+	calls := DefaultConfig
+	calls.Weights = Weights{Expr: 1, Ident: 1, Int: 1, Call: 4, Prefix: 1, Infix: 1}
+	configs := []Config{DefaultConfig, deep, calls}
+
+	for i, cfg := range configs {
+		for seed := int64(0); seed < 200; seed++ {
+			want := New(cfg, seed).Program()
+			src := want.String()
+			p := parser.New(lexer.New(src))
+			got := p.Parse()
+			if errs := p.Errors(); len(errs) > 0 {
+				t.Errorf("%d. seed %d: Parse(%q): %v", i, seed, src, errs)
+				continue
+			}
+			if !ast.Equal(got, want) {
+				t.Errorf("%d. seed %d: Parse(%q) = %v", i, seed, src, got)
+			}
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		a, b := New(DefaultConfig, seed).Program(), New(DefaultConfig, seed).Program()
+		if a.String() != b.String() {
+			t.Errorf("seed %d: made %q, then %q", seed, a, b)
+		}
+	}
+	g := New(DefaultConfig, 1)
+	if a, b := g.Program(), g.Program(); a.String() == b.String() {
+		t.Errorf("made %q twice", a)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		cfg Config
+		// ok reports whether n is allowed.
+		ok func(n ast.Node, depth int) bool
+	}{
+		{
+			// Only integer arithmetic, with expressions from depth 2, under
+			// the program and a statement, nested 3 deep.
+			cfg: Config{Depth: 3, Statements: 2, Weights: Weights{Expr: 1, Int: 1, Infix: 1}},
+			ok: func(n ast.Node, depth int) bool {
+				switch n.(type) {
+				case *ast.Program, *ast.ExpressionStatement, *ast.IntegerLiteral, *ast.InfixExpression:
+					return depth <= 2+3
+				}
+				return false
+			},
+		},
+		{
+			// Functions of up to one parameter, without annotations.
+			cfg: Config{
+				Depth: 4, Statements: 3, Args: 1,
+				Weights: Weights{Let: 1, Func: 1, Ident: 1},
+				Names:   []string{"f"},
+			},
+			ok: func(n ast.Node, depth int) bool {
+				switch n := n.(type) {
+				case *ast.FunctionLiteral:
+					return len(n.Parameters) <= 1 && n.ReturnType == nil
+				case *ast.LetStatement:
+					return n.Type == nil
+				case *ast.Identifier:
+					return n.Value == "f" && n.Type == nil
+				case *ast.Program, *ast.BlockStatement:
+					return true
+				}
+				return false
+			},
+		},
+		{
+			// With no weights for identifiers and literals, the deepest
+			// expressions are identifiers.
+			cfg: Config{Depth: 2, Statements: 1, Weights: Weights{Return: 1, Prefix: 1}, Names: []string{"x"}},
+			ok: func(n ast.Node, depth int) bool {
+				switch n.(type) {
+				case *ast.Program, *ast.ReturnStatement, *ast.PrefixExpression:
+					return true
+				case *ast.Identifier:
+					return depth == 4
+				}
+				return false
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		for seed := int64(0); seed < 50; seed++ {
+			prog := New(tt.cfg, seed).Program()
+			var walk func(n ast.Node, depth int)
+			walk = func(n ast.Node, depth int) {
+				if !tt.ok(n, depth) {
+					t.Errorf("%d. seed %d: %T %v at depth %d in %v", i, seed, n, n, depth, prog)
+				}
+				if _, ok := n.(*ast.Program); ok && len(ast.Children(n)) > tt.cfg.Statements {
+					t.Errorf("%d. seed %d: %d statements in %v", i, seed, len(ast.Children(n)), prog)
+				}
+				for _, c := range ast.Children(n) {
+					walk(c, depth+1)
+				}
+			}
+			walk(prog, 0)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	// Without names, DefaultConfig's are used.
+	cfg := Config{Depth: 2, Statements: 3, Annotate: 1, Weights: Weights{Let: 1, Ident: 1}}
+	known := make(map[string]bool)
+	for _, name := range append(DefaultConfig.Names, DefaultConfig.TypeNames...) {
+		known[name] = true
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		prog := New(cfg, seed).Program()
+		ast.Inspect(prog, func(n ast.Node) bool {
+			var name string
+			switch n := n.(type) {
+			case *ast.Identifier:
+				name = n.Value
+			case *ast.NamedType:
+				name = n.Name
+			}
+			if name != "" && !known[name] {
+				t.Errorf("seed %d: unexpected name %q in %v", seed, name, prog)
+			}
+			return true
+		})
+	}
+
+	for i, cfg := range []Config{
+		{Names: []string{"x", "x1"}},
+		{Names: []string{"let"}},
+		{TypeNames: []string{""}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d. New(%q, %q) didn't panic", i, cfg.Names, cfg.TypeNames)
+				}
+			}()
+			New(cfg, 0)
+		}()
+	}
+}
@@ -0,0 +1,115 @@
+package ast
+
+import "fmt"
+
+// Inspect traverses the tree rooted at node in depth-first, source order,
+// calling f for each node. If f returns false, the children of that node are
+// skipped. Missing children, such as an if expression without an else, are
+// not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+	for _, c := range Children(node) {
+		Inspect(c, f)
+	}
+}
+
+// Children returns the direct children of node, in source order.
+func Children(node Node) []Node {
+	var out []Node
+	for _, f := range fields(node) {
+		out = append(out, f.node)
+	}
+	return out
+}
+
+// HasReturn reports whether node has a return statement outside any function
+// literal, which would return from the function node is in.
+func HasReturn(node Node) bool {
+	found := false
+	Inspect(node, func(n Node) bool {
+		switch n.(type) {
+		case *ReturnStatement:
+			found = true
+		case *FunctionLiteral:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// field is a child of a node, and the name of the struct field which holds
+// it, with its index if the field is a slice, e.g. "Arguments[1]".
+type field struct {
+	name string
+	node Node
+}
+
+// fields returns the direct children of node, in source order.
+func fields(node Node) []field {
+	var out []field
+	add := func(name string, n Node) {
+		if n != nil {
+			out = append(out, field{name, n})
+		}
+	}
+	index := func(name string, i int) string {
+		return fmt.Sprintf("%s[%d]", name, i)
+	}
+	switch n := node.(type) {
+	case *Program:
+		for i, s := range n.Statements {
+			add(index("Statements", i), s)
+		}
+	case *LetStatement:
+		if n.Name != nil {
+			add("Name", n.Name)
+		}
+		add("Type", n.Type)
+		add("Value", n.Value)
+	case *Identifier:
+		add("Type", n.Type)
+	case *ReturnStatement:
+		add("ReturnValue", n.ReturnValue)
+	case *ExpressionStatement:
+		add("Expression", n.Expression)
+	case *BlockStatement:
+		for i, s := range n.Statements {
+			add(index("Statements", i), s)
+		}
+	case *PrefixExpression:
+		add("Right", n.Right)
+	case *InfixExpression:
+		add("Left", n.Left)
+		add("Right", n.Right)
+	case *IfExpression:
+		add("Condition", n.Condition)
+		if n.Consequence != nil {
+			add("Consequence", n.Consequence)
+		}
+		if n.Alternative != nil {
+			add("Alternative", n.Alternative)
+		}
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			add(index("Parameters", i), p)
+		}
+		add("ReturnType", n.ReturnType)
+		if n.Body != nil {
+			add("Body", n.Body)
+		}
+	case *CallExpression:
+		add("Function", n.Function)
+		for i, a := range n.Arguments {
+			add(index("Arguments", i), a)
+		}
+	case *FunctionType:
+		for i, p := range n.Params {
+			add(index("Params", i), p)
+		}
+		add("Result", n.Result)
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"monkey/token"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: tok(token.IDENT, name), Value: name}
+}
+
+func TestInspect(t *testing.T) {
+	// let f = fn(x) { if (!x) { g(x, 1) } }
+	call := &CallExpression{
+		Token:    tok(token.LPAREN, "("),
+		Function: ident("g"),
+		Arguments: []Expression{
+			ident("x"),
+			&IntegerLiteral{Token: tok(token.INT, "1"), Value: 1},
+		},
+	}
+	prog := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: tok(token.LET, "let"),
+				Name:  ident("f"),
+				Value: &FunctionLiteral{
+					Token:      tok(token.FUNCTION, "fn"),
+					Parameters: []*Identifier{ident("x")},
+					Body: &BlockStatement{
+						Token: tok(token.LBRACE, "{"),
+						Statements: []Statement{
+							&ExpressionStatement{
+								Token: tok(token.IF, "if"),
+								Expression: &IfExpression{
+									Token: tok(token.IF, "if"),
+									Condition: &PrefixExpression{
+										Token:    tok(token.BANG, "!"),
+										Operator: "!",
+										Right:    ident("x"),
+									},
+									Consequence: &BlockStatement{
+										Token: tok(token.LBRACE, "{"),
+										Statements: []Statement{
+											&ExpressionStatement{Token: tok(token.IDENT, "g"), Expression: call},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var got []string
+	Inspect(prog, func(n Node) bool {
+		got = append(got, fmt.Sprintf("%T", n))
+		return true
+	})
+	want := []string{
+		"*ast.Program",
+		"*ast.LetStatement",
+		"*ast.Identifier",
+		"*ast.FunctionLiteral",
+		"*ast.Identifier",
+		"*ast.BlockStatement",
+		"*ast.ExpressionStatement",
+		"*ast.IfExpression",
+		"*ast.PrefixExpression",
+		"*ast.Identifier",
+		"*ast.BlockStatement",
+		"*ast.ExpressionStatement",
+		"*ast.CallExpression",
+		"*ast.Identifier",
+		"*ast.Identifier",
+		"*ast.IntegerLiteral",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Inspect visited\n%v\nwant\n%v", got, want)
+	}
+
+	// Skip the children of the function.
+	got = nil
+	Inspect(prog, func(n Node) bool {
+		got = append(got, fmt.Sprintf("%T", n))
+		_, ok := n.(*FunctionLiteral)
+		return !ok
+	})
+	want = want[:4]
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Inspect visited\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestInspectTypes(t *testing.T) {
+	// let f: fn(int) -> bool = fn(x: int) -> bool { x }
+	named := func(name string) *NamedType { return &NamedType{Token: tok(token.IDENT, name), Name: name} }
+	x := ident("x")
+	x.Type = named("int")
+	prog := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: tok(token.LET, "let"),
+				Name:  ident("f"),
+				Type: &FunctionType{
+					Token:  tok(token.FUNCTION, "fn"),
+					Params: []TypeExpression{named("int")},
+					Result: named("bool"),
+				},
+				Value: &FunctionLiteral{
+					Token:      tok(token.FUNCTION, "fn"),
+					Parameters: []*Identifier{x},
+					ReturnType: named("bool"),
+					Body: &BlockStatement{
+						Token:      tok(token.LBRACE, "{"),
+						Statements: []Statement{&ExpressionStatement{Token: tok(token.IDENT, "x"), Expression: ident("x")}},
+					},
+				},
+			},
+		},
+	}
+
+	var got []string
+	Inspect(prog, func(n Node) bool {
+		got = append(got, fmt.Sprintf("%T", n))
+		return true
+	})
+	want := []string{
+		"*ast.Program",
+		"*ast.LetStatement",
+		"*ast.Identifier",
+		"*ast.FunctionType",
+		"*ast.NamedType",
+		"*ast.NamedType",
+		"*ast.FunctionLiteral",
+		"*ast.Identifier",
+		"*ast.NamedType",
+		"*ast.NamedType",
+		"*ast.BlockStatement",
+		"*ast.ExpressionStatement",
+		"*ast.Identifier",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Inspect visited\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestHasReturn(t *testing.T) {
+	ret := func() Statement {
+		return &ReturnStatement{Token: tok(token.RETURN, "return"), ReturnValue: ident("x")}
+	}
+	block := func(stmts ...Statement) *BlockStatement {
+		return &BlockStatement{Token: tok(token.LBRACE, "{"), Statements: stmts}
+	}
+	expr := func(e Expression) Statement {
+		return &ExpressionStatement{Token: tok(token.IDENT, "x"), Expression: e}
+	}
+	tests := []struct {
+		node Node
+		want bool
+	}{
+		// { x }
+		{block(expr(ident("x"))), false},
+		// { return x; }
+		{block(ret()), true},
+		// { if (x) { return x; } }
+		{block(expr(&IfExpression{Token: tok(token.IF, "if"), Condition: ident("x"), Consequence: block(ret())})), true},
+		// { fn() { return x; } }
+		{block(expr(&FunctionLiteral{Token: tok(token.FUNCTION, "fn"), Body: block(ret())})), false},
+	}
+	for i, tt := range tests {
+		if got := HasReturn(tt.node); got != tt.want {
+			t.Errorf("%d. HasReturn(%s): got %v, want %v", i, tt.node, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package build makes monkey syntax trees from Go, e.g.
+//
+//	build.Let("x", build.Add(build.Int(1), build.Ident("y")))
+//
+// The nodes have the tokens the parser would give them, without positions, so
+// that printing a program and parsing the result gives back the same tree.
+//
+// The functions panic if they're given something which can't be written in
+// monkey, such as a name which isn't an identifier.
+package build
+
+import (
+	"fmt"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Program returns a program of stmts.
+func Program(stmts ...ast.Statement) *ast.Program {
+	return &ast.Program{Statements: stmts}
+}
+
+// Let returns "let name = value;".
+func Let(name string, value ast.Expression) *ast.LetStatement {
+	return &ast.LetStatement{Token: tok(token.LET, "let"), Name: Ident(name), Value: value}
+}
+
+// TypedLet returns "let name: typ = value;".
+func TypedLet(name string, typ ast.TypeExpression, value ast.Expression) *ast.LetStatement {
+	ls := Let(name, value)
+	ls.Type = typ
+	return ls
+}
+
+// Return returns "return value;".
+func Return(value ast.Expression) *ast.ReturnStatement {
+	return &ast.ReturnStatement{Token: tok(token.RETURN, "return"), ReturnValue: value}
+}
+
+// Expr returns a statement of e. Its token is the first token of e as printed.
+func Expr(e ast.Expression) *ast.ExpressionStatement {
+	return &ast.ExpressionStatement{Token: first(e), Expression: e}
+}
+
+// first returns the first token of e's String.
+func first(e ast.Expression) token.Token {
+	switch e := e.(type) {
+	case *ast.PrefixExpression, *ast.InfixExpression:
+		return tok(token.LPAREN, "(")
+	case *ast.CallExpression:
+		return first(e.Function)
+	case *ast.Identifier:
+		return e.Token
+	case *ast.IntegerLiteral:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	case *ast.IfExpression:
+		return e.Token
+	case *ast.FunctionLiteral:
+		return e.Token
+	}
+	panic(fmt.Sprintf("build.Expr: unknown expression %T", e))
+}
+
+// Block returns "{ stmts }".
+func Block(stmts ...ast.Statement) *ast.BlockStatement {
+	return &ast.BlockStatement{Token: tok(token.LBRACE, "{"), Statements: stmts}
+}
+
+// Ident returns the identifier name.
+func Ident(name string) *ast.Identifier {
+	if !IsIdent(name) {
+		panic(fmt.Sprintf("build.Ident: %q isn't an identifier", name))
+	}
+	return &ast.Identifier{Token: tok(token.IDENT, name), Value: name}
+}
+
+// IsIdent reports whether name may be an identifier or type name: it's made
+// of letters and underscores, and isn't a keyword.
+func IsIdent(name string) bool {
+	if name == "" || token.Lookup(name) != token.IDENT {
+		return false
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Param returns the parameter "name: typ".
+func Param(name string, typ ast.TypeExpression) *ast.Identifier {
+	id := Ident(name)
+	id.Type = typ
+	return id
+}
+
+// Params returns parameters without types.
+func Params(names ...string) []*ast.Identifier {
+	var ids []*ast.Identifier
+	for _, name := range names {
+		ids = append(ids, Ident(name))
+	}
+	return ids
+}
+
+// Int returns the integer literal v. Monkey has no negative literals; write
+// Neg(Int(n)) instead.
+func Int(v int64) *ast.IntegerLiteral {
+	if v < 0 {
+		panic(fmt.Sprintf("build.Int: negative literal %d", v))
+	}
+	return &ast.IntegerLiteral{Token: tok(token.INT, strconv.FormatInt(v, 10)), Value: v}
+}
+
+// Bool returns true or false.
+func Bool(v bool) *ast.Boolean {
+	lit := strconv.FormatBool(v)
+	return &ast.Boolean{Token: tok(token.Lookup(lit), lit), Value: v}
+}
+
+var prefixOps = map[string]bool{"!": true, "-": true}
+
+// Prefix returns "(op right)", for the operators ! and -.
+func Prefix(op string, right ast.Expression) *ast.PrefixExpression {
+	if !prefixOps[op] {
+		panic(fmt.Sprintf("build.Prefix: unknown operator %q", op))
+	}
+	return &ast.PrefixExpression{Token: tok(token.Type(op), op), Operator: op, Right: right}
+}
+
+// Not returns "(!right)".
+func Not(right ast.Expression) *ast.PrefixExpression { return Prefix("!", right) }
+
+// Neg returns "(-right)".
+func Neg(right ast.Expression) *ast.PrefixExpression { return Prefix("-", right) }
+
+var infixOps = map[string]bool{"+": true, "-": true, "*": true, "/": true, "<": true, ">": true, "==": true, "!=": true}
+
+// Infix returns "(left op right)", for the operators + - * / < > == and !=.
+func Infix(op string, left, right ast.Expression) *ast.InfixExpression {
+	if !infixOps[op] {
+		panic(fmt.Sprintf("build.Infix: unknown operator %q", op))
+	}
+	return &ast.InfixExpression{Token: tok(token.Type(op), op), Left: left, Operator: op, Right: right}
+}
+
+// Add returns "(left + right)".
+func Add(left, right ast.Expression) *ast.InfixExpression { return Infix("+", left, right) }
+
+// Sub returns "(left - right)".
+func Sub(left, right ast.Expression) *ast.InfixExpression { return Infix("-", left, right) }
+
+// Mul returns "(left * right)".
+func Mul(left, right ast.Expression) *ast.InfixExpression { return Infix("*", left, right) }
+
+// Div returns "(left / right)".
+func Div(left, right ast.Expression) *ast.InfixExpression { return Infix("/", left, right) }
+
+// Lt returns "(left < right)".
+func Lt(left, right ast.Expression) *ast.InfixExpression { return Infix("<", left, right) }
+
+// Gt returns "(left > right)".
+func Gt(left, right ast.Expression) *ast.InfixExpression { return Infix(">", left, right) }
+
+// Eq returns "(left == right)".
+func Eq(left, right ast.Expression) *ast.InfixExpression { return Infix("==", left, right) }
+
+// NotEq returns "(left != right)".
+func NotEq(left, right ast.Expression) *ast.InfixExpression { return Infix("!=", left, right) }
+
+// If returns "if (cond) cons else alt", or without the else if alt is nil.
+func If(cond ast.Expression, cons, alt *ast.BlockStatement) *ast.IfExpression {
+	return &ast.IfExpression{Token: tok(token.IF, "if"), Condition: cond, Consequence: cons, Alternative: alt}
+}
+
+// Fn returns "fn(params) { body }".
+func Fn(params []*ast.Identifier, body ...ast.Statement) *ast.FunctionLiteral {
+	return &ast.FunctionLiteral{Token: tok(token.FUNCTION, "fn"), Parameters: params, Body: Block(body...)}
+}
+
+// TypedFn returns "fn(params) -> result { body }".
+func TypedFn(params []*ast.Identifier, result ast.TypeExpression, body ...ast.Statement) *ast.FunctionLiteral {
+	fl := Fn(params, body...)
+	fl.ReturnType = result
+	return fl
+}
+
+// Call returns "fn(args)".
+func Call(fn ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	return &ast.CallExpression{Token: tok(token.LPAREN, "("), Function: fn, Arguments: args}
+}
+
+// Type returns the named type name, such as int.
+func Type(name string) *ast.NamedType {
+	if !IsIdent(name) {
+		panic(fmt.Sprintf("build.Type: %q isn't a type name", name))
+	}
+	return &ast.NamedType{Token: tok(token.IDENT, name), Name: name}
+}
+
+// FuncType returns "fn(params) -> result".
+func FuncType(params []ast.TypeExpression, result ast.TypeExpression) *ast.FunctionType {
+	return &ast.FunctionType{Token: tok(token.FUNCTION, "fn"), Params: params, Result: result}
+}
+
+func tok(typ token.Type, lit string) token.Token {
+	return token.Token{Type: typ, Literal: lit}
+}
@@ -0,0 +1,114 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+)
+
+func TestReparse(t *testing.T) {
+	tests := []struct {
+		prog *ast.Program
+		want string
+	}{
+		{
+			prog: Program(Let("x", Add(Int(1), Ident("y")))),
+			want: "let x = (1 + y);",
+		},
+		{
+			prog: Program(
+				Let("fact", Fn(Params("n"),
+					Expr(If(Eq(Ident("n"), Int(0)),
+						Block(Expr(Int(1))),
+						Block(Expr(Mul(Ident("n"), Call(Ident("fact"), Sub(Ident("n"), Int(1)))))))))),
+				Expr(Call(Ident("fact"), Int(5))),
+			),
+			want: "let fact = fn(n) {\nif (n == 0) {\n1;\n} else {\n(n * fact((n - 1)));\n};\n};fact(5);",
+		},
+		{
+			prog: Program(
+				Expr(Not(Bool(true))),
+				Expr(Neg(Int(3))),
+				Expr(Lt(Gt(Ident("a"), Ident("b")), NotEq(Bool(false), Div(Int(4), Int(2))))),
+				Return(Ident("a")),
+			),
+			want: "(!true);(-3);((a > b) < (false != (4 / 2)));return a;",
+		},
+		{
+			// The statements start with the tokens of their functions.
+			prog: Program(
+				Expr(Call(Fn(Params("x"), Expr(Ident("x"))), Int(1))),
+				Expr(Call(Call(Ident("f"), Int(1)), Int(2))),
+				Expr(If(Ident("ok"), Block(), nil)),
+			),
+			want: "fn(x) {\nx;\n}(1);f(1)(2);if (ok) {\n};",
+		},
+		{
+			prog: Program(
+				TypedLet("twice", FuncType([]ast.TypeExpression{Type("int")}, Type("int")),
+					TypedFn([]*ast.Identifier{Param("n", Type("int"))}, Type("int"), Expr(Add(Ident("n"), Ident("n"))))),
+				Let("apply", Fn([]*ast.Identifier{Param("f", FuncType(nil, Type("bool")))}, Return(Call(Ident("f"))))),
+			),
+			want: "let twice: fn(int) -> int = fn(n: int) -> int {\n(n + n);\n};" +
+				"let apply = fn(f: fn() -> bool) {\nreturn f();\n};",
+		},
+	}
+
+	for i, tt := range tests {
+		src := tt.prog.String()
+		if src != tt.want {
+			t.Errorf("%d. String() = %q, want %q", i, src, tt.want)
+		}
+		p := parser.New(lexer.New(src))
+		got := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%d. Parse(%q): %v", i, src, errs)
+			continue
+		}
+		if !ast.Equal(got, tt.prog) {
+			t.Errorf("%d. Parse(%q) = %v", i, src, got)
+			continue
+		}
+		// The tokens are the same too, but for their positions.
+		var gotNodes, wantNodes []ast.Node
+		ast.Inspect(got, func(n ast.Node) bool { gotNodes = append(gotNodes, n); return true })
+		ast.Inspect(tt.prog, func(n ast.Node) bool { wantNodes = append(wantNodes, n); return true })
+		for j, n := range wantNodes[1:] {
+			gotTok := reflect.ValueOf(gotNodes[j+1]).Elem().FieldByName("Token").Interface().(token.Token)
+			wantTok := reflect.ValueOf(n).Elem().FieldByName("Token").Interface().(token.Token)
+			gotTok.Pos = token.Pos{}
+			if gotTok != wantTok {
+				t.Errorf("%d. %T %v has token %+v, the parser gives %+v", i, n, n, wantTok, gotTok)
+			}
+		}
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"Ident(let)", func() { Ident("let") }},
+		{"Ident(x1)", func() { Ident("x1") }},
+		{"Ident()", func() { Ident("") }},
+		{"Type(fn)", func() { Type("fn") }},
+		{"Int(-1)", func() { Int(-1) }},
+		{"Prefix(+)", func() { Prefix("+", Int(1)) }},
+		{"Infix(%)", func() { Infix("%", Int(1), Int(2)) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s didn't panic", tt.name)
+				}
+			}()
+			tt.f()
+		}()
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/cgen"
+)
+
+func runC(args []string) error {
+	fs := flag.NewFlagSet("c", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey c [file ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		prog, err := parseFile(name)
+		if err != nil {
+			return err
+		}
+		src, err := cgen.Generate(prog)
+		if err != nil {
+			return fmt.Errorf("%s:%v", name, err)
+		}
+		if _, err := os.Stdout.Write(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
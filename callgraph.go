@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/analysis"
+)
+
+func runCallgraph(args []string) error {
+	fs := flag.NewFlagSet("callgraph", flag.ExitOnError)
+	format := fs.String("format", "dot", "output `format`: dot or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey callgraph [-format dot|json] [file ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != "dot" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		prog, err := parseFile(name)
+		if err != nil {
+			return err
+		}
+		g := analysis.BuildCallGraph(prog)
+		if *format == "json" {
+			err = g.WriteJSON(os.Stdout)
+		} else {
+			err = g.WriteDOT(os.Stdout, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+// Package cgen translates monkey programs into C99 source files.
+//
+// The generated file has a small runtime, in which a Value is a tagged union
+// of an integer, a boolean, a closure or null, followed by a C function for
+// each monkey function and one, run, for the program itself. Its main
+// function prints the value of the program. Operators are calls to runtime
+// functions, which report the evaluator's errors and exit if their operands
+// have the wrong types.
+//
+// A closure is a C function paired with an environment: a reference-counted
+// struct holding the variables of the enclosing call which closures use,
+// and a pointer to the environment of the call enclosing that. Other
+// variables are C locals. Monkey names are prefixed with m_ so they can't
+// clash with C's.
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+var operators = map[string]string{
+	"+":  "rt_add",
+	"-":  "rt_sub",
+	"*":  "rt_mul",
+	"/":  "rt_div",
+	"<":  "rt_lt",
+	">":  "rt_gt",
+	"==": "rt_eq",
+	"!=": "rt_ne",
+}
+
+// Generate returns a C source file which runs prog and prints its value.
+func Generate(prog *ast.Program) ([]byte, error) {
+	g := &generator{
+		info:  resolve.Resolve(prog),
+		vars:  make(map[*resolve.Decl]*variable),
+		funcs: make(map[ast.Node]*function),
+		useFn: make(map[*ast.Identifier]*function),
+	}
+	// The C code would read variables which aren't set.
+	if err := g.info.Err(); err != nil {
+		return nil, err
+	}
+	g.findFunctions(prog, g.newFunction(prog, nil))
+	g.groupScope(g.info.Scope)
+	for id, fn := range g.useFn {
+		if v := g.vars[g.info.Uses[id]]; v != nil && v.fn != fn {
+			v.captured = true
+		}
+	}
+	for _, fn := range g.list {
+		g.function(fn)
+	}
+
+	var out strings.Builder
+	out.WriteString("/* Code generated by monkey. DO NOT EDIT. */\n\n")
+	out.WriteString(runtime)
+	for _, fn := range g.list {
+		if fn.hasEnv {
+			out.WriteString("\n" + g.envStruct(fn))
+		}
+	}
+	out.WriteString("\n")
+	for _, fn := range g.list[1:] {
+		fmt.Fprintf(&out, "static Value fn%d(Closure *self, Value *args);\n", fn.id)
+	}
+	out.WriteString("static Value run(void);\n")
+	for _, fn := range g.list[1:] {
+		out.WriteString("\n" + fn.code)
+	}
+	out.WriteString("\n" + g.list[0].code)
+	out.WriteString("\nint main(void) {\n\tValue v = run();\n\trt_print(v);\n\trt_release(v);\n\treturn 0;\n}\n")
+	return []byte(out.String()), nil
+}
+
+// Fprint writes a C source file which runs prog to w.
+func Fprint(w io.Writer, prog *ast.Program) error {
+	src, err := Generate(prog)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// function is a monkey function, or the program itself, and the C function
+// it becomes.
+type function struct {
+	id     int // 0 for the program
+	node   ast.Node
+	parent *function
+	// hasEnv is set if the function contains function literals, so its calls
+	// need an environment.
+	hasEnv bool
+	vars   []*variable
+	names  map[string]int // number of variables with each monkey name
+	code   string
+}
+
+// variable is a C variable, for the declarations of a monkey variable (see
+// resolve.Scope.Variables).
+type variable struct {
+	name string
+	fn   *function
+	// captured is set if the variable is used by a nested function, so it
+	// lives in the function's environment.
+	captured bool
+}
+
+type generator struct {
+	info  *resolve.Info
+	vars  map[*resolve.Decl]*variable
+	funcs map[ast.Node]*function
+	list  []*function
+	// useFn maps each use of a name to the function it's in.
+	useFn map[*ast.Identifier]*function
+
+	// The function being generated.
+	fn       *function
+	out      strings.Builder
+	depth    int // indentation of the current line
+	temps    int // number of temporaries
+	returned bool
+}
+
+func (g *generator) newFunction(n ast.Node, parent *function) *function {
+	fn := &function{id: len(g.list), node: n, parent: parent, names: make(map[string]int)}
+	g.funcs[n] = fn
+	g.list = append(g.list, fn)
+	return fn
+}
+
+// findFunctions makes the functions nested in n, which is in fn, and records
+// which function each name is used in.
+func (g *generator) findFunctions(n ast.Node, fn *function) {
+	for _, c := range ast.Children(n) {
+		ast.Inspect(c, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral:
+				fn.hasEnv = true
+				g.findFunctions(n, g.newFunction(n, fn))
+				return false
+			case *ast.Identifier:
+				g.useFn[n] = fn
+			}
+			return true
+		})
+	}
+}
+
+// groupScope makes the variables for the declarations in s and its children.
+func (g *generator) groupScope(s *resolve.Scope) {
+	fn := g.funcOf(s)
+	for _, decls := range s.Variables() {
+		v := &variable{name: fn.varName(decls[0].Name.Value), fn: fn}
+		fn.vars = append(fn.vars, v)
+		for _, d := range decls {
+			g.vars[d] = v
+		}
+	}
+	for _, c := range s.Children {
+		g.groupScope(c)
+	}
+}
+
+// funcOf returns the function whose body contains scope s.
+func (g *generator) funcOf(s *resolve.Scope) *function {
+	return g.funcs[s.Function().Node]
+}
+
+// varName returns a C name for a new variable called name in fn. Monkey names
+// can't contain digits, so the variables are numbered.
+func (fn *function) varName(name string) string {
+	n := fn.names[name]
+	fn.names[name]++
+	if n == 0 {
+		return "m_" + name
+	}
+	return "m_" + name + strconv.Itoa(n)
+}
+
+// envStruct returns the declaration of fn's environment and the function
+// which releases it.
+func (g *generator) envStruct(fn *function) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "struct env%d {\n\tEnv h;\n", fn.id)
+	if fn.parent != nil {
+		fmt.Fprintf(&out, "\tstruct env%d *up;\n", fn.parent.id)
+	}
+	for _, v := range fn.vars {
+		if v.captured {
+			fmt.Fprintf(&out, "\tValue %s;\n", v.name)
+		}
+	}
+	out.WriteString("};\n\n")
+	fmt.Fprintf(&out, "static void release_env%d(Env *e) {\n", fn.id)
+	var body strings.Builder
+	for _, v := range fn.vars {
+		if v.captured {
+			fmt.Fprintf(&body, "\trt_release(env->%s);\n", v.name)
+		}
+	}
+	if fn.parent != nil {
+		body.WriteString("\trt_env_release((Env *)env->up);\n")
+	}
+	if body.Len() == 0 {
+		out.WriteString("\t(void)e;\n")
+	} else {
+		fmt.Fprintf(&out, "\tstruct env%d *env = (struct env%d *)e;\n", fn.id, fn.id)
+		out.WriteString(body.String())
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// access returns a C expression for the variable v, from within the function
+// being generated.
+func (g *generator) access(v *variable) string {
+	if !v.captured {
+		return v.name
+	}
+	fn := g.fn
+	var env string
+	if fn.hasEnv {
+		env = "env"
+	} else {
+		// The environment of the function's closure.
+		fn = fn.parent
+		env = fmt.Sprintf("((struct env%d *)self->env)", fn.id)
+	}
+	for ; fn != v.fn; fn = fn.parent {
+		env += "->up"
+	}
+	return env + "->" + v.name
+}
+
+func (g *generator) indent() string {
+	return strings.Repeat("\t", g.depth)
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	g.out.WriteString(g.indent())
+	fmt.Fprintf(&g.out, format, args...)
+	g.out.WriteString("\n")
+}
+
+// temp declares a new temporary holding the value of the C expression x, and
+// returns its name.
+func (g *generator) temp(x string) string {
+	g.temps++
+	t := "t" + strconv.Itoa(g.temps)
+	g.line("Value %s = %s;", t, x)
+	return t
+}
+
+// function generates the C function for fn.
+func (g *generator) function(fn *function) {
+	g.fn = fn
+	g.out.Reset()
+	g.depth = 1
+	g.temps = 0
+	g.returned = false
+
+	var stmts []ast.Statement
+	if fl, ok := fn.node.(*ast.FunctionLiteral); ok {
+		fmt.Fprintf(&g.out, "static Value fn%d(Closure *self, Value *args) {\n", fn.id)
+		stmts = fl.Body.Statements
+	} else {
+		g.out.WriteString("static Value run(void) {\n")
+		stmts = fn.node.(*ast.Program).Statements
+	}
+	if fn.hasEnv {
+		g.line("struct env%d *env = (struct env%d *)rt_env_new(sizeof *env, release_env%d);", fn.id, fn.id, fn.id)
+		if fn.parent != nil {
+			g.line("env->up = (struct env%d *)rt_env_retain(self->env);", fn.parent.id)
+		}
+	}
+	g.line("Value result = rt_null;")
+	for _, v := range fn.vars {
+		if !v.captured {
+			g.line("Value %s = rt_null;", v.name)
+		}
+	}
+	if fl, ok := fn.node.(*ast.FunctionLiteral); ok {
+		for i, p := range fl.Parameters {
+			g.line("rt_set(&%s, args[%d]);", g.access(g.vars[g.info.Defs[p]]), i)
+		}
+	}
+	g.statements(stmts, "result")
+
+	if g.returned {
+		g.out.WriteString("out:\n")
+	}
+	for _, v := range fn.vars {
+		if !v.captured {
+			g.line("rt_release(%s);", v.name)
+		}
+	}
+	if fn.hasEnv {
+		g.line("rt_env_release((Env *)env);")
+	}
+	g.line("return result;")
+	g.out.WriteString("}\n")
+	fn.code = g.out.String()
+}
+
+// statements generates stmts. If target isn't empty, the value of the last
+// statement is stored in it.
+func (g *generator) statements(stmts []ast.Statement, target string) {
+	for i, s := range stmts {
+		if i == len(stmts)-1 {
+			g.statement(s, target)
+		} else {
+			g.statement(s, "")
+		}
+	}
+}
+
+func (g *generator) statement(s ast.Statement, target string) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		if s.Value == nil {
+			return
+		}
+		x := g.expression(s.Value)
+		g.line("rt_set(&%s, %s);", g.access(g.vars[g.info.Defs[s.Name]]), x)
+	case *ast.ReturnStatement:
+		x := "rt_null"
+		if s.ReturnValue != nil {
+			x = g.expression(s.ReturnValue)
+		}
+		g.line("result = %s;", x)
+		g.line("goto out;")
+		g.returned = true
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case nil:
+		case *ast.IfExpression:
+			g.ifExpression(e, target)
+		case *ast.Identifier, *ast.IntegerLiteral, *ast.Boolean:
+			if target != "" {
+				g.line("%s = %s;", target, g.expression(e))
+			}
+		default:
+			x := g.expression(e)
+			if target != "" {
+				g.line("%s = %s;", target, x)
+			} else {
+				g.line("rt_release(%s);", x)
+			}
+		}
+	case *ast.BlockStatement:
+		g.statements(s.Statements, target)
+	}
+}
+
+// ifExpression generates ie. If target isn't empty, the value of the branch
+// which is taken is stored in it.
+func (g *generator) ifExpression(ie *ast.IfExpression, target string) {
+	g.line("if (rt_truthy(%s)) {", g.expression(ie.Condition))
+	g.depth++
+	g.statements(ie.Consequence.Statements, target)
+	g.depth--
+	if ie.Alternative != nil {
+		g.line("} else {")
+		g.depth++
+		g.statements(ie.Alternative.Statements, target)
+		g.depth--
+	}
+	g.line("}")
+}
+
+// expression generates the statements which evaluate e, and returns a C
+// expression for a new reference to its value. The C expression has no side
+// effects which matter, so it can be evaluated in any order.
+func (g *generator) expression(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("rt_int(%d)", e.Value)
+	case *ast.Boolean:
+		if e.Value {
+			return "rt_bool(1)"
+		}
+		return "rt_bool(0)"
+	case *ast.Identifier:
+		return fmt.Sprintf("rt_retain(%s)", g.access(g.vars[g.info.Uses[e]]))
+	case *ast.PrefixExpression:
+		right := g.expression(e.Right)
+		if e.Operator == "-" {
+			return g.temp("rt_neg(" + right + ")")
+		}
+		return g.temp("rt_not(" + right + ")")
+	case *ast.InfixExpression:
+		left := g.expression(e.Left)
+		right := g.expression(e.Right)
+		return g.temp(fmt.Sprintf("%s(%s, %s)", operators[e.Operator], left, right))
+	case *ast.IfExpression:
+		t := g.temp("rt_null")
+		g.ifExpression(e, t)
+		return t
+	case *ast.FunctionLiteral:
+		fn := g.funcs[e]
+		return fmt.Sprintf("rt_closure(%d, fn%d, (Env *)env)", len(e.Parameters), fn.id)
+	case *ast.CallExpression:
+		f := g.expression(e.Function)
+		if len(e.Arguments) == 0 {
+			return g.temp(fmt.Sprintf("rt_call(%s, 0, NULL)", f))
+		}
+		var args []string
+		for _, a := range e.Arguments {
+			args = append(args, g.expression(a))
+		}
+		return g.temp(fmt.Sprintf("rt_call(%s, %d, (Value[]){%s})", f, len(args), strings.Join(args, ", ")))
+	}
+	return "rt_null"
+}
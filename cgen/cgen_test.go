@@ -0,0 +1,161 @@
+package cgen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/internal/runtest"
+	"monkey/parser"
+)
+
+// programs are monkey programs, besides runtest.Programs, and what they
+// print when run.
+var programs = []runtest.Program{
+	{
+		Input: "let f = fn(x) { let y = if (x) { return 1; 2 } else { 3 }; y }; f(true) * 10 + f(false)",
+		Want:  "13",
+	},
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(parser.MustParse("let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`struct env0 {
+	Env h;
+	Value m_fact;
+};
+`,
+		`static Value fn1(Closure *self, Value *args) {
+	Value result = rt_null;
+	Value m_n = rt_null;
+	rt_set(&m_n, args[0]);
+	Value t1 = rt_lt(rt_retain(m_n), rt_int(2));
+	if (rt_truthy(t1)) {
+		result = rt_int(1);
+	} else {
+		Value t2 = rt_sub(rt_retain(m_n), rt_int(1));
+		Value t3 = rt_call(rt_retain(((struct env0 *)self->env)->m_fact), 1, (Value[]){t2});
+		Value t4 = rt_mul(rt_retain(m_n), t3);
+		result = t4;
+	}
+	rt_release(m_n);
+	return result;
+}
+`,
+		`static Value run(void) {
+	struct env0 *env = (struct env0 *)rt_env_new(sizeof *env, release_env0);
+	Value result = rt_null;
+	rt_set(&env->m_fact, rt_closure(1, fn1, (Env *)env));
+	Value t1 = rt_call(rt_retain(env->m_fact), 1, (Value[]){rt_int(5)});
+	result = t1;
+	rt_env_release((Env *)env);
+	return result;
+}
+`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Generate() =\n%s\nwant it to contain:\n%s", src, want)
+		}
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+	src, err := Generate(parser.MustParse("let x = 1; let x = 2; let f = fn(int) { let x = int; if (x) { let x = 3; x } }; f(x)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Value m_x = rt_null;\n\tValue m_f = rt_null;\n",
+		"Value m_int = rt_null;\n\tValue m_x = rt_null;\n\tValue m_x1 = rt_null;\n",
+		"rt_set(&m_x1, rt_int(3));",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Generate() =\n%s\nwant it to contain:\n%s", src, want)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x + 1", "1:1: undefined: x"},
+		{"let a = b; let b = 1;", "1:9: b used before its declaration at 1:16"},
+	}
+	for i, tc := range tests {
+		_, err := Generate(parser.MustParse(tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. Generate(%q) error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// compile compiles the C program for input with the C compiler cc, returning
+// the path of the executable.
+func compile(t *testing.T, cc, dir, input string) (string, bool) {
+	t.Helper()
+	src, err := Generate(parser.MustParse(input))
+	if err != nil {
+		t.Errorf("Generate(%q): %v", input, err)
+		return "", false
+	}
+	file := filepath.Join(dir, "prog.c")
+	if err := os.WriteFile(file, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "prog")
+	out, err := exec.Command(cc, "-std=c99", "-pedantic", "-Wall", "-Werror", "-o", exe, file).CombinedOutput()
+	if err != nil {
+		t.Errorf("%q: %s: %v\n%s\n%s", input, cc, err, out, src)
+		return "", false
+	}
+	return exe, true
+}
+
+// TestRun compiles and runs the generated programs, if there is a C
+// compiler.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc not found")
+	}
+	dir := t.TempDir()
+	for i, tc := range append(runtest.Programs, programs...) {
+		exe, ok := compile(t, cc, dir, tc.Input)
+		if !ok {
+			continue
+		}
+		out, err := exec.Command(exe).CombinedOutput()
+		if err != nil {
+			t.Errorf("%d. %q: %v\n%s", i, tc.Input, err, out)
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.Want {
+			t.Errorf("%d. %q printed %s, want %s", i, tc.Input, got, tc.Want)
+		}
+	}
+	for i, tc := range runtest.Failures {
+		exe, ok := compile(t, cc, dir, tc.Input)
+		if !ok {
+			continue
+		}
+		out, err := exec.Command(exe).CombinedOutput()
+		if err == nil {
+			t.Errorf("%d. %q succeeded, want error %q", i, tc.Input, tc.Want)
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.Want {
+			t.Errorf("%d. %q failed with %q, want %q", i, tc.Input, got, tc.Want)
+		}
+	}
+}
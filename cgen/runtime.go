@@ -0,0 +1,262 @@
+package cgen
+
+// runtime is the code every generated file starts with.
+//
+// A Value is a tagged union. Closures are the only values on the heap, and
+// they and the environments they point to are reference counted: every
+// Value a generated function holds is a reference it owns, runtime functions
+// which take Values consume them, and rt_retain makes a new reference. A
+// recursive function's closure refers to the environment which holds it, so
+// the scheme is conservative: such cycles are never freed, but nothing is
+// freed while it's still in use.
+//
+// The functions are static inline so that compilers don't warn about the ones
+// a program doesn't use.
+const runtime = `#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+typedef struct Env Env;
+typedef struct Closure Closure;
+
+typedef enum { RT_NULL, RT_INTEGER, RT_BOOLEAN, RT_FUNCTION } Tag;
+
+/* Value is a monkey value. */
+typedef struct {
+	Tag tag;
+	union {
+		int64_t i;
+		int b;
+		Closure *fn;
+	} as;
+} Value;
+
+/* Env is the start of an environment: the variables of a function call
+ * which are used by closures. */
+struct Env {
+	long refs;
+	/* release releases the environment's variables. */
+	void (*release)(Env *env);
+};
+
+struct Closure {
+	long refs;
+	int params;
+	Value (*code)(Closure *self, Value *args);
+	Env *env;
+};
+
+static const Value rt_null = {RT_NULL, {0}};
+
+static inline Value rt_int(int64_t i) {
+	Value v = {RT_INTEGER, {0}};
+	v.as.i = i;
+	return v;
+}
+
+static inline Value rt_bool(int b) {
+	Value v = {RT_BOOLEAN, {0}};
+	v.as.b = b;
+	return v;
+}
+
+static inline const char *rt_type(Value v) {
+	switch (v.tag) {
+	case RT_INTEGER:
+		return "INTEGER";
+	case RT_BOOLEAN:
+		return "BOOLEAN";
+	case RT_FUNCTION:
+		return "FUNCTION";
+	default:
+		return "NULL";
+	}
+}
+
+static inline Env *rt_env_new(size_t size, void (*release)(Env *env)) {
+	Env *env = calloc(1, size);
+	if (env == NULL) {
+		fputs("out of memory\n", stderr);
+		exit(1);
+	}
+	env->refs = 1;
+	env->release = release;
+	return env;
+}
+
+static inline Env *rt_env_retain(Env *env) {
+	if (env != NULL) {
+		env->refs++;
+	}
+	return env;
+}
+
+static inline void rt_env_release(Env *env) {
+	if (env != NULL && --env->refs == 0) {
+		env->release(env);
+		free(env);
+	}
+}
+
+static inline Value rt_retain(Value v) {
+	if (v.tag == RT_FUNCTION) {
+		v.as.fn->refs++;
+	}
+	return v;
+}
+
+static inline void rt_release(Value v) {
+	if (v.tag == RT_FUNCTION && --v.as.fn->refs == 0) {
+		rt_env_release(v.as.fn->env);
+		free(v.as.fn);
+	}
+}
+
+/* rt_set stores v in *slot, releasing what was there. */
+static inline void rt_set(Value *slot, Value v) {
+	Value old = *slot;
+	*slot = v;
+	rt_release(old);
+}
+
+static inline Value rt_closure(int params, Value (*code)(Closure *self, Value *args), Env *env) {
+	Value v = {RT_FUNCTION, {0}};
+	Closure *fn = malloc(sizeof *fn);
+	if (fn == NULL) {
+		fputs("out of memory\n", stderr);
+		exit(1);
+	}
+	fn->refs = 1;
+	fn->params = params;
+	fn->code = code;
+	fn->env = rt_env_retain(env);
+	v.as.fn = fn;
+	return v;
+}
+
+static inline int rt_truthy(Value v) {
+	int truthy = v.tag != RT_NULL && !(v.tag == RT_BOOLEAN && !v.as.b);
+	rt_release(v);
+	return truthy;
+}
+
+static inline Value rt_neg(Value v) {
+	if (v.tag != RT_INTEGER) {
+		fprintf(stderr, "unknown operator: -%s\n", rt_type(v));
+		exit(1);
+	}
+	return rt_int((int64_t)(0 - (uint64_t)v.as.i));
+}
+
+static inline Value rt_not(Value v) {
+	return rt_bool(!rt_truthy(v));
+}
+
+static inline void rt_ints(Value a, const char *op, Value b) {
+	if (a.tag != RT_INTEGER || b.tag != RT_INTEGER) {
+		fprintf(stderr, "type mismatch: %s %s %s\n", rt_type(a), op, rt_type(b));
+		exit(1);
+	}
+}
+
+/* Arithmetic wraps around, as it does in two's complement. */
+static inline Value rt_add(Value a, Value b) {
+	rt_ints(a, "+", b);
+	return rt_int((int64_t)((uint64_t)a.as.i + (uint64_t)b.as.i));
+}
+
+static inline Value rt_sub(Value a, Value b) {
+	rt_ints(a, "-", b);
+	return rt_int((int64_t)((uint64_t)a.as.i - (uint64_t)b.as.i));
+}
+
+static inline Value rt_mul(Value a, Value b) {
+	rt_ints(a, "*", b);
+	return rt_int((int64_t)((uint64_t)a.as.i * (uint64_t)b.as.i));
+}
+
+static inline Value rt_div(Value a, Value b) {
+	rt_ints(a, "/", b);
+	if (b.as.i == 0) {
+		fputs("division by zero\n", stderr);
+		exit(1);
+	}
+	if (b.as.i == -1) {
+		return rt_neg(a);
+	}
+	return rt_int(a.as.i / b.as.i);
+}
+
+static inline Value rt_lt(Value a, Value b) {
+	rt_ints(a, "<", b);
+	return rt_bool(a.as.i < b.as.i);
+}
+
+static inline Value rt_gt(Value a, Value b) {
+	rt_ints(a, ">", b);
+	return rt_bool(a.as.i > b.as.i);
+}
+
+static inline int rt_equal(Value a, Value b) {
+	int equal = a.tag == b.tag;
+	if (equal) {
+		switch (a.tag) {
+		case RT_INTEGER:
+			equal = a.as.i == b.as.i;
+			break;
+		case RT_BOOLEAN:
+			equal = !a.as.b == !b.as.b;
+			break;
+		case RT_FUNCTION:
+			equal = a.as.fn == b.as.fn;
+			break;
+		default:
+			break;
+		}
+	}
+	rt_release(a);
+	rt_release(b);
+	return equal;
+}
+
+static inline Value rt_eq(Value a, Value b) {
+	return rt_bool(rt_equal(a, b));
+}
+
+static inline Value rt_ne(Value a, Value b) {
+	return rt_bool(!rt_equal(a, b));
+}
+
+/* rt_call calls f, which consumes the n arguments. */
+static inline Value rt_call(Value f, int n, Value *args) {
+	Value result;
+	if (f.tag != RT_FUNCTION) {
+		fprintf(stderr, "not a function: %s\n", rt_type(f));
+		exit(1);
+	}
+	if (n != f.as.fn->params) {
+		fprintf(stderr, "wrong number of arguments: want=%d, got=%d\n", f.as.fn->params, n);
+		exit(1);
+	}
+	result = f.as.fn->code(f.as.fn, args);
+	rt_release(f);
+	return result;
+}
+
+static inline void rt_print(Value v) {
+	switch (v.tag) {
+	case RT_INTEGER:
+		printf("%lld\n", (long long)v.as.i);
+		break;
+	case RT_BOOLEAN:
+		puts(v.as.b ? "true" : "false");
+		break;
+	case RT_FUNCTION:
+		puts("fn");
+		break;
+	default:
+		puts("null");
+		break;
+	}
+}
+`
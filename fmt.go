@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/pretty"
+)
+
+func runFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	width := fs.Int("width", pretty.DefaultWidth, "maximum line `width`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey fmt [-width n] [file ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		prog, err := parseFile(name)
+		if err != nil {
+			return err
+		}
+		if err := pretty.Fprint(os.Stdout, prog, *width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
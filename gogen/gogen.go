@@ -0,0 +1,413 @@
+// Package gogen translates monkey programs into Go source files.
+//
+// The program becomes a function, Run, which returns the value of its last
+// statement. Monkey values are represented by a small runtime, written into
+// the same file, in which a Value is an int64, a bool, a *Function or nil for
+// null. Operators are calls to runtime functions, which panic with the
+// evaluator's error messages if their operands have the wrong types.
+// Functions become Go func literals, which close over monkey's variables just
+// as monkey's functions do, and if expressions whose value is used become
+// immediately invoked func literals.
+//
+// A file generated for package main also has a main function, which prints
+// the value of the program.
+package gogen
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+var operators = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"<":  "lt",
+	">":  "gt",
+	"==": "eq",
+	"!=": "ne",
+}
+
+// reserved are the names which monkey allows but Go doesn't, or which the
+// generated code relies on.
+var reserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true,
+	"continue": true, "default": true, "defer": true, "else": true,
+	"fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true,
+	"map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true,
+	"var": true, "_": true,
+	"bool": true, "int64": true, "nil": true, "panic": true, "string": true,
+}
+
+func init() {
+	for _, name := range runtimeNames {
+		reserved[name] = true
+	}
+}
+
+// Generate returns a Go source file in package pkg which runs prog.
+func Generate(prog *ast.Program, pkg string) ([]byte, error) {
+	g := &generator{
+		info: resolve.Resolve(prog),
+		vars: make(map[*resolve.Decl]*variable),
+	}
+	// Go won't compile these.
+	if err := g.info.Err(); err != nil {
+		return nil, err
+	}
+	g.groupScope(g.info.Scope)
+	g.findForward(prog, make(map[*resolve.Decl]bool))
+
+	g.depth = 1
+	body := g.scopeStart(prog) + g.statements(prog.Statements, ret)
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	var out strings.Builder
+	out.WriteString("// Code generated by monkey. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if pkg == "main" {
+		out.WriteString("import (\n\t\"fmt\"\n\t\"strconv\"\n)\n\n")
+	} else {
+		out.WriteString("import \"strconv\"\n\n")
+	}
+	out.WriteString(runtime)
+	out.WriteString("\n// Run runs the program and returns its value.\n")
+	out.WriteString("func Run() Value {\n" + body + "}\n")
+	if pkg == "main" {
+		out.WriteString("\nfunc main() {\n\tfmt.Println(inspect(Run()))\n}\n")
+	}
+	return []byte(out.String()), nil
+}
+
+// Fprint writes a Go source file in package pkg which runs prog to w.
+func Fprint(w io.Writer, prog *ast.Program, pkg string) error {
+	src, err := Generate(prog, pkg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// mode says what happens to the value of a list of statements.
+type mode int
+
+const (
+	// discard throws the value away.
+	discard mode = iota
+	// ret returns the value from the enclosing Go function.
+	ret
+)
+
+// variable is a Go variable, for the declarations of a monkey variable (see
+// resolve.Scope.Variables).
+type variable struct {
+	name string
+	// used is set if any of the declarations is used; Go doesn't allow
+	// variables which aren't.
+	used bool
+	// param is set if the variable is a function parameter.
+	param bool
+	// hoisted is set if the variable is used before it's declared, as by a
+	// recursive function, so must be declared at the start of its scope.
+	hoisted  bool
+	declared bool
+}
+
+type generator struct {
+	info *resolve.Info
+	vars map[*resolve.Decl]*variable
+
+	depth int // indentation of the current line
+	// iife is set while generating the body of an immediately invoked
+	// function, where a monkey return statement can't be translated.
+	iife bool
+	err  error
+}
+
+func (g *generator) errorf(pos token.Pos, format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf("%v: %s", pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// groupScope makes the variables for the declarations in s and its children.
+func (g *generator) groupScope(s *resolve.Scope) {
+	for _, decls := range s.Variables() {
+		v := &variable{name: escape(decls[0].Name.Value)}
+		for _, d := range decls {
+			v.used = v.used || len(d.Uses) > 0
+			v.param = v.param || d.Kind == resolve.Param
+			g.vars[d] = v
+		}
+	}
+	for _, c := range s.Children {
+		g.groupScope(c)
+	}
+}
+
+// findForward marks the variables which are used before their let statement
+// has been evaluated.
+func (g *generator) findForward(n ast.Node, done map[*resolve.Decl]bool) {
+	switch n := n.(type) {
+	case *ast.LetStatement:
+		if n.Value != nil {
+			g.findForward(n.Value, done)
+		}
+		if decl := g.info.Defs[n.Name]; decl != nil {
+			done[decl] = true
+		}
+		return
+	case *ast.FunctionLiteral:
+		for _, p := range n.Parameters {
+			if decl := g.info.Defs[p]; decl != nil {
+				done[decl] = true
+			}
+		}
+	case *ast.Identifier:
+		if decl := g.info.Uses[n]; decl != nil && !done[decl] {
+			g.vars[decl].hoisted = true
+		}
+		return
+	}
+	for _, c := range ast.Children(n) {
+		g.findForward(c, done)
+	}
+}
+
+// escape returns a Go name for a monkey name. Monkey names can't contain
+// digits, so the result can't clash with another monkey name.
+func escape(name string) string {
+	if reserved[name] {
+		return name + "0"
+	}
+	return name
+}
+
+func (g *generator) indent() string {
+	return strings.Repeat("\t", g.depth)
+}
+
+// scopeStart declares the hoisted variables of the scope introduced by n.
+func (g *generator) scopeStart(n ast.Node) string {
+	s := g.info.Scopes[n]
+	if s == nil {
+		return ""
+	}
+	var names []string
+	seen := make(map[*variable]bool)
+	for _, d := range s.Decls {
+		v := g.vars[d]
+		if v.hoisted && !v.param && v.used && !seen[v] {
+			seen[v] = true
+			v.declared = true
+			names = append(names, v.name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return g.indent() + "var " + strings.Join(names, ", ") + " Value\n"
+}
+
+// statements returns the lines for stmts, indented to the current depth.
+func (g *generator) statements(stmts []ast.Statement, m mode) string {
+	var out strings.Builder
+	for i, s := range stmts {
+		sm := discard
+		if i == len(stmts)-1 {
+			sm = m
+		}
+		out.WriteString(g.statement(s, sm))
+	}
+	if m == ret && !endsWithReturn(stmts) {
+		out.WriteString(g.indent() + "return nil\n")
+	}
+	return out.String()
+}
+
+// endsWithReturn reports whether statements in ret mode return a value
+// themselves.
+func endsWithReturn(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch s := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			return ie.Alternative != nil
+		}
+		return true
+	}
+	return false
+}
+
+// block returns b as a braced block, opening on the current line.
+func (g *generator) block(b *ast.BlockStatement, m mode) string {
+	if len(b.Statements) == 0 && m == discard {
+		return "{\n" + g.indent() + "}"
+	}
+	g.depth++
+	body := g.scopeStart(b) + g.statements(b.Statements, m)
+	g.depth--
+	return "{\n" + body + g.indent() + "}"
+}
+
+func (g *generator) statement(s ast.Statement, m mode) string {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		return g.let(s)
+	case *ast.ReturnStatement:
+		if g.iife {
+			g.errorf(s.Pos(), "can't translate return inside an if expression whose value is used")
+		}
+		return g.indent() + "return " + g.expression(s.ReturnValue) + "\n"
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			return g.indent() + g.ifStatement(ie, m) + "\n"
+		}
+		if m == ret {
+			return g.indent() + "return " + g.expression(s.Expression) + "\n"
+		}
+		if isCall(s.Expression) {
+			return g.indent() + g.expression(s.Expression) + "\n"
+		}
+		return g.indent() + "_ = " + g.expression(s.Expression) + "\n"
+	case *ast.BlockStatement:
+		return g.indent() + g.block(s, m) + "\n"
+	}
+	return ""
+}
+
+func (g *generator) let(s *ast.LetStatement) string {
+	value := g.expression(s.Value)
+	decl := g.info.Defs[s.Name]
+	if decl == nil {
+		return g.indent() + "_ = " + value + "\n"
+	}
+	v := g.vars[decl]
+	switch {
+	case !v.used:
+		return g.indent() + "_ = " + value + "\n"
+	case v.declared:
+		return g.indent() + v.name + " = " + value + "\n"
+	}
+	v.declared = true
+	return g.indent() + "var " + v.name + " Value = " + value + "\n"
+}
+
+func (g *generator) ifStatement(ie *ast.IfExpression, m mode) string {
+	out := "if truthy(" + g.expression(ie.Condition) + ") " + g.block(ie.Consequence, m)
+	if ie.Alternative != nil {
+		out += " else " + g.block(ie.Alternative, m)
+	}
+	return out
+}
+
+// isCall reports whether the Go for e is a function call, which can be used
+// as a statement.
+func isCall(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.PrefixExpression, *ast.InfixExpression, *ast.IfExpression, *ast.CallExpression:
+		return true
+	}
+	return false
+}
+
+func (g *generator) expression(e ast.Expression) string {
+	switch e := e.(type) {
+	case nil:
+		return "nil"
+	case *ast.Identifier:
+		if decl := g.info.Uses[e]; decl != nil {
+			return g.vars[decl].name
+		}
+		return escape(e.Value)
+	case *ast.IntegerLiteral:
+		return "int64(" + strconv.FormatInt(e.Value, 10) + ")"
+	case *ast.Boolean:
+		return strconv.FormatBool(e.Value)
+	case *ast.PrefixExpression:
+		switch e.Operator {
+		case "-":
+			return "neg(" + g.expression(e.Right) + ")"
+		case "!":
+			return "not(" + g.expression(e.Right) + ")"
+		}
+	case *ast.InfixExpression:
+		if fn, ok := operators[e.Operator]; ok {
+			return fn + "(" + g.expression(e.Left) + ", " + g.expression(e.Right) + ")"
+		}
+	case *ast.IfExpression:
+		iife := g.iife
+		g.iife = true
+		g.depth++
+		body := g.ifStatement(e, ret)
+		if e.Alternative == nil {
+			body += "\n" + g.indent() + "return nil"
+		}
+		g.depth--
+		g.iife = iife
+		return "func() Value {\n" + g.indent() + "\t" + body + "\n" + g.indent() + "}()"
+	case *ast.FunctionLiteral:
+		return g.function(e)
+	case *ast.CallExpression:
+		args := []string{g.expression(e.Function)}
+		for _, a := range e.Arguments {
+			args = append(args, g.expression(a))
+		}
+		return "call(" + strings.Join(args, ", ") + ")"
+	}
+	g.errorf(e.Pos(), "can't translate %v", e)
+	return "nil"
+}
+
+func (g *generator) function(fl *ast.FunctionLiteral) string {
+	iife := g.iife
+	g.iife = false
+	defer func() { g.iife = iife }()
+
+	g.depth++
+	var body strings.Builder
+	// Unused parameters are left out, as Go doesn't allow them. When a name
+	// is repeated, the last parameter wins.
+	var names, values []string
+	index := make(map[*variable]int)
+	for i, p := range fl.Parameters {
+		decl := g.info.Defs[p]
+		if decl == nil || !g.vars[decl].used {
+			continue
+		}
+		v := g.vars[decl]
+		if j, ok := index[v]; ok {
+			values[j] = "args[" + strconv.Itoa(i) + "]"
+			continue
+		}
+		index[v] = len(names)
+		v.declared = true
+		names = append(names, v.name)
+		values = append(values, "args["+strconv.Itoa(i)+"]")
+	}
+	if len(names) > 0 {
+		body.WriteString(g.indent() + strings.Join(names, ", ") + " := " + strings.Join(values, ", ") + "\n")
+	}
+	body.WriteString(g.scopeStart(fl))
+	body.WriteString(g.statements(fl.Body.Statements, ret))
+	g.depth--
+
+	return "&Function{Params: " + strconv.Itoa(len(fl.Parameters)) + ", Call: func(args []Value) Value {\n" +
+		body.String() + g.indent() + "}}"
+}
@@ -0,0 +1,138 @@
+package gogen
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/internal/runtest"
+	monkeyparser "monkey/parser"
+)
+
+func TestGenerateIsValidGo(t *testing.T) {
+	for i, tc := range runtest.Programs {
+		for _, pkg := range []string{"main", "scripts"} {
+			src, err := Generate(monkeyparser.MustParse(tc.Input), pkg)
+			if err != nil {
+				t.Errorf("%d. Generate(%q): %v", i, tc.Input, err)
+				continue
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), "out.go", src, 0)
+			if err != nil {
+				t.Errorf("%d. Generate(%q) doesn't parse: %v\n%s", i, tc.Input, err, src)
+				continue
+			}
+			if got := f.Name.Name; got != pkg {
+				t.Errorf("%d. Generate(%q) is in package %s, want %s", i, tc.Input, got, pkg)
+			}
+			formatted, err := format.Source(src)
+			if err != nil {
+				t.Errorf("%d. format.Source: %v", i, err)
+				continue
+			}
+			if !bytes.Equal(formatted, src) {
+				t.Errorf("%d. Generate(%q) isn't formatted:\n%s\nwant:\n%s", i, tc.Input, src, formatted)
+			}
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(monkeyparser.MustParse("let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)"), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `func Run() Value {
+	var fact Value
+	fact = &Function{Params: 1, Call: func(args []Value) Value {
+		n := args[0]
+		if truthy(lt(n, int64(2))) {
+			return int64(1)
+		} else {
+			return mul(n, call(fact, sub(n, int64(1))))
+		}
+	}}
+	return call(fact, int64(5))
+}
+`
+	if !strings.Contains(string(src), want) {
+		t.Errorf("Generate() =\n%s\nwant it to contain:\n%s", src, want)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x + 1", "1:1: undefined: x"},
+		{"let a = b; let b = 1;", "1:9: b used before its declaration at 1:16"},
+		{
+			input: "let f = fn(x) { let y = if (x) { return 1; 2 } else { 3 }; y }",
+			want:  "1:34: can't translate return inside an if expression whose value is used",
+		},
+	}
+	for i, tc := range tests {
+		_, err := Generate(monkeyparser.MustParse(tc.input), "main")
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. Generate(%q) error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// run generates a Go program for input and runs it with the go command
+// goTool, returning its output.
+func run(t *testing.T, goTool, dir, input string) ([]byte, error) {
+	t.Helper()
+	src, err := Generate(monkeyparser.MustParse(input), "main")
+	if err != nil {
+		t.Fatalf("Generate(%q): %v", input, err)
+	}
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "run", file)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	return cmd.CombinedOutput()
+}
+
+// TestRun compiles and runs the generated programs, if the go command is
+// installed.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found")
+	}
+	dir := t.TempDir()
+	for i, tc := range runtest.Programs {
+		out, err := run(t, goTool, dir, tc.Input)
+		if err != nil {
+			t.Errorf("%d. %q: go run: %v\n%s", i, tc.Input, err, out)
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.Want {
+			t.Errorf("%d. %q printed %s, want %s", i, tc.Input, got, tc.Want)
+		}
+	}
+	for i, tc := range runtest.Failures {
+		out, err := run(t, goTool, dir, tc.Input)
+		if err == nil {
+			t.Errorf("%d. %q succeeded, want error %q", i, tc.Input, tc.Want)
+			continue
+		}
+		if !strings.HasPrefix(string(out), "panic: "+tc.Want+"\n") {
+			t.Errorf("%d. %q failed with\n%s\nwant panic %q", i, tc.Input, out, tc.Want)
+		}
+	}
+}
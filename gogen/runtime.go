@@ -0,0 +1,97 @@
+package gogen
+
+// runtime is the code every generated file starts with. It represents monkey
+// values as Go values: an int64, a bool, a *Function, or nil for null. Go's
+// == on these values is the same as monkey's.
+const runtime = `// Value is a monkey value: an int64, a bool, a *Function, or nil for null.
+type Value interface{}
+
+// Function is a monkey function.
+type Function struct {
+	Params int
+	Call   func(args []Value) Value
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case int64:
+		return "INTEGER"
+	case bool:
+		return "BOOLEAN"
+	case *Function:
+		return "FUNCTION"
+	case nil:
+		return "NULL"
+	}
+	return "UNKNOWN"
+}
+
+func truthy(v Value) bool { return v != nil && v != false }
+
+func neg(v Value) Value {
+	i, ok := v.(int64)
+	if !ok {
+		panic("unknown operator: -" + typeName(v))
+	}
+	return -i
+}
+
+func not(v Value) Value { return !truthy(v) }
+
+func ints(a Value, op string, b Value) (int64, int64) {
+	x, ok := a.(int64)
+	y, ok2 := b.(int64)
+	if !ok || !ok2 {
+		panic("type mismatch: " + typeName(a) + " " + op + " " + typeName(b))
+	}
+	return x, y
+}
+
+func add(a, b Value) Value { x, y := ints(a, "+", b); return x + y }
+func sub(a, b Value) Value { x, y := ints(a, "-", b); return x - y }
+func mul(a, b Value) Value { x, y := ints(a, "*", b); return x * y }
+func lt(a, b Value) Value  { x, y := ints(a, "<", b); return x < y }
+func gt(a, b Value) Value  { x, y := ints(a, ">", b); return x > y }
+func eq(a, b Value) Value  { return a == b }
+func ne(a, b Value) Value  { return a != b }
+
+func div(a, b Value) Value {
+	x, y := ints(a, "/", b)
+	if y == 0 {
+		panic("division by zero")
+	}
+	return x / y
+}
+
+func call(f Value, args ...Value) Value {
+	fn, ok := f.(*Function)
+	if !ok {
+		panic("not a function: " + typeName(f))
+	}
+	if len(args) != fn.Params {
+		panic("wrong number of arguments: want=" + strconv.Itoa(fn.Params) + ", got=" + strconv.Itoa(len(args)))
+	}
+	return fn.Call(args)
+}
+
+func inspect(v Value) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case *Function:
+		return "fn"
+	}
+	return "null"
+}
+`
+
+// runtimeNames are the names the runtime and generated code declare, which
+// monkey names mustn't hide.
+var runtimeNames = []string{
+	"Value", "Function", "Run", "main", "args",
+	"typeName", "truthy", "neg", "not", "ints",
+	"add", "sub", "mul", "div", "lt", "gt", "eq", "ne",
+	"call", "inspect", "strconv", "fmt",
+}
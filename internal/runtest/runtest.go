@@ -0,0 +1,82 @@
+// Package runtest holds monkey programs, and what running them does, for
+// testing the code generators which compile them to other languages.
+package runtest
+
+// A Program is a monkey program and what it prints when run, or for a program
+// which fails, the error it reports.
+type Program struct {
+	Input string
+	Want  string
+}
+
+// Programs run without errors.
+var Programs = []Program{
+	{"1 + 2 * 3", "7"},
+	{"10 / 3 - -2", "5"},
+	{"!5; !!false", "false"},
+	{"1 == 1 != false", "true"},
+	{"1 == true", "false"},
+	{"let f = fn(x) { x }; f == f", "true"},
+	{"", "null"},
+	{"let x = 5;", "null"},
+	{"if (0) { 1 }", "1"},
+	{"if (false) { 1 }", "null"},
+	{"if (1 > 2) { 1 } else { 2 }", "2"},
+	{"fn() {}()", "null"},
+	{
+		Input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(20)",
+		Want:  "2432902008176640000",
+	},
+	{
+		Input: "let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(40)",
+		Want:  "42",
+	},
+	{
+		Input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7)",
+		Want:  "true",
+	},
+	{
+		Input: "let sign = fn(n) { if (n < 0) { return -1; }; if (n == 0) { 0 } else { 1 } }; sign(-5) + sign(0) * 10 + sign(7) * 100",
+		Want:  "99",
+	},
+	{
+		Input: "let x = 1; let x = x + 1; let f = fn(x) { let x = x * 10; let g = fn() { let x = x + 1; x }; g() }; f(x)",
+		Want:  "21",
+	},
+	{
+		Input: "let a = 1; let f = fn() { let g = fn() { a }; let a = 2; g() }; f() + a",
+		Want:  "3",
+	},
+	{
+		Input: "let type = 3; let add = fn(x, x) { x + type }; add(1, 2)",
+		Want:  "5",
+	},
+	{
+		Input: "let f = fn(unused) { 1 + if (unused) { let y = 2; y * y } }; f(true) + f(1)",
+		Want:  "10",
+	},
+	{
+		Input: "let r = 5; if (r > 2) { return r * 2; }; r",
+		Want:  "10",
+	},
+	{"9223372036854775807 + 1", "-9223372036854775808"},
+	{"-7 / 2", "-3"},
+	{"let f = fn() { 1 }; f", "fn"},
+	{
+		Input: "let compose = fn(f, g) { fn(x) { f(g(x)) } }; let inc = fn(x) { x + 1 }; let dbl = fn(x) { x * 2 }; compose(inc, dbl)(5)",
+		Want:  "11",
+	},
+	{
+		Input: "let counter = fn(n) { let next = fn() { counter(n + 1) }; if (n == 3) { n } else { next() } }; counter(0)",
+		Want:  "3",
+	},
+}
+
+// Failures fail at run time.
+var Failures = []Program{
+	{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+	{"-true", "unknown operator: -BOOLEAN"},
+	{"let x = 1; x(2)", "not a function: INTEGER"},
+	{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+	{"1 / 0", "division by zero"},
+}
@@ -0,0 +1,411 @@
+package ir
+
+import (
+	"strconv"
+
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+// Build lowers prog to a Program. Names must be resolvable: a program which
+// uses undefined names, or names before they're declared, is an error.
+func Build(prog *ast.Program) (*Program, error) {
+	b := &builder{
+		info:  resolve.Resolve(prog),
+		prog:  &Program{},
+		vars:  make(map[*resolve.Decl]*variable),
+		fns:   make(map[ast.Node]*fnInfo),
+		names: map[string]int{"main": 1},
+	}
+	if err := b.info.Err(); err != nil {
+		return nil, err
+	}
+	b.groupScope(b.info.Scope)
+	b.findFree(prog, []ast.Node{prog})
+	b.findCells(prog, make(map[*variable]bool))
+
+	main := &Func{Name: "main", Syntax: prog}
+	b.prog.Funcs = append(b.prog.Funcs, main)
+	b.function(main, prog, prog.Statements)
+	return b.prog, nil
+}
+
+// variable is a monkey variable: the declarations of a name in a single
+// scope (see resolve.Scope.Variables).
+type variable struct {
+	name  string
+	fn    ast.Node // the function literal or program it's declared in
+	decls int
+	// cell is set if the variable is captured by a closure which may see it
+	// change: it's declared more than once, or the closure is made before the
+	// variable is declared.
+	cell bool
+}
+
+// fnInfo is what's known about a function literal before it's built.
+type fnInfo struct {
+	// free are the variables of enclosing functions the function uses,
+	// including those used by the functions nested in it.
+	free []*variable
+	name string // the name of the variable it's bound to, if any
+}
+
+type builder struct {
+	info  *resolve.Info
+	prog  *Program
+	vars  map[*resolve.Decl]*variable
+	fns   map[ast.Node]*fnInfo
+	names map[string]int // number of functions with each name
+
+	// The function being built.
+	fn     *Func
+	block  *Block // nil if the code being built is unreachable
+	values map[*variable]Value
+	nextID int
+}
+
+// groupScope makes the variables for the declarations in s and its children.
+func (b *builder) groupScope(s *resolve.Scope) {
+	for _, decls := range s.Variables() {
+		v := &variable{name: decls[0].Name.Value, fn: s.Function().Node, decls: len(decls)}
+		for _, d := range decls {
+			b.vars[d] = v
+		}
+	}
+	for _, c := range s.Children {
+		b.groupScope(c)
+	}
+}
+
+// findFree finds the free variables of the function literals in n. stack is
+// the functions enclosing n, outermost first.
+func (b *builder) findFree(n ast.Node, stack []ast.Node) {
+	switch n := n.(type) {
+	case *ast.LetStatement:
+		if fl, ok := n.Value.(*ast.FunctionLiteral); ok {
+			b.literal(fl).name = n.Name.Value
+		}
+	case *ast.FunctionLiteral:
+		b.literal(n)
+		stack = append(stack, n)
+	case *ast.Identifier:
+		v := b.vars[b.info.Uses[n]]
+		if v == nil {
+			return
+		}
+		// The variable is free in every function between its own and the use.
+		for i := len(stack) - 1; i >= 0 && stack[i] != v.fn; i-- {
+			fi := b.fns[stack[i]]
+			if !contains(fi.free, v) {
+				fi.free = append(fi.free, v)
+			}
+		}
+		return
+	}
+	for _, c := range ast.Children(n) {
+		b.findFree(c, stack)
+	}
+}
+
+func (b *builder) literal(fl *ast.FunctionLiteral) *fnInfo {
+	fi := b.fns[fl]
+	if fi == nil {
+		fi = &fnInfo{}
+		b.fns[fl] = fi
+	}
+	return fi
+}
+
+func contains(vs []*variable, v *variable) bool {
+	for _, w := range vs {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// findCells decides which variables need cells, by walking n in evaluation
+// order; done holds the variables which have been declared.
+func (b *builder) findCells(n ast.Node, done map[*variable]bool) {
+	switch n := n.(type) {
+	case *ast.LetStatement:
+		if n.Value != nil {
+			b.findCells(n.Value, done)
+		}
+		if v := b.vars[b.info.Defs[n.Name]]; v != nil {
+			done[v] = true
+		}
+		return
+	case *ast.FunctionLiteral:
+		for _, v := range b.fns[n].free {
+			if v.decls > 1 || !done[v] {
+				v.cell = true
+			}
+		}
+		for _, p := range n.Parameters {
+			if v := b.vars[b.info.Defs[p]]; v != nil {
+				done[v] = true
+			}
+		}
+	}
+	for _, c := range ast.Children(n) {
+		b.findCells(c, done)
+	}
+}
+
+// funcName returns a unique name for a function bound to name. Monkey names
+// can't contain digits, so the functions are numbered.
+func (b *builder) funcName(name string) string {
+	if name == "" {
+		// fn is a keyword, so it's not a monkey name.
+		name = "fn"
+	}
+	n := b.names[name]
+	b.names[name]++
+	if n == 0 && name != "fn" {
+		return name
+	}
+	return name + strconv.Itoa(n+1)
+}
+
+// function builds the body of f, a function literal or the program.
+func (b *builder) function(f *Func, syntax ast.Node, body []ast.Statement) {
+	outer, outerBlock, outerValues, outerID := b.fn, b.block, b.values, b.nextID
+	defer func() {
+		b.fn, b.block, b.values, b.nextID = outer, outerBlock, outerValues, outerID
+	}()
+	b.fn = f
+	b.values = make(map[*variable]Value)
+	b.nextID = 0
+	b.block = b.newBlock()
+
+	paramNames := make(map[string]int)
+	unique := func(name string) string {
+		n := paramNames[name]
+		paramNames[name]++
+		if n == 0 {
+			return name
+		}
+		return name + strconv.Itoa(n)
+	}
+	// A function's cells are made on entry, so they exist before the
+	// closures which capture them.
+	for _, v := range b.cells(syntax) {
+		b.values[v] = b.emit(&NewCell{})
+	}
+	if fl, ok := syntax.(*ast.FunctionLiteral); ok {
+		for _, v := range b.fns[fl].free {
+			fv := &FreeVar{name: unique(v.name), Func: f}
+			f.FreeVars = append(f.FreeVars, fv)
+			b.values[v] = fv
+		}
+		for _, p := range fl.Parameters {
+			param := &Param{name: unique(p.Value), Func: f}
+			f.Params = append(f.Params, param)
+			// The last of several parameters with the same name wins.
+			b.bind(b.vars[b.info.Defs[p]], param)
+		}
+	}
+
+	result := b.statements(body)
+	if b.block != nil {
+		b.emit(&Return{Result: result})
+	}
+}
+
+// cells returns the variables declared in fn which need cells, in the order
+// they're declared.
+func (b *builder) cells(fn ast.Node) []*variable {
+	var cells []*variable
+	var walk func(s *resolve.Scope)
+	walk = func(s *resolve.Scope) {
+		for _, d := range s.Decls {
+			if v := b.vars[d]; v.cell && v.fn == fn && !contains(cells, v) {
+				cells = append(cells, v)
+			}
+		}
+		for _, c := range s.Children {
+			if !c.IsFunction() {
+				walk(c)
+			}
+		}
+	}
+	walk(b.info.Scopes[fn])
+	return cells
+}
+
+func (b *builder) newBlock() *Block {
+	blk := &Block{Index: len(b.fn.Blocks), Func: b.fn}
+	b.fn.Blocks = append(b.fn.Blocks, blk)
+	return blk
+}
+
+func addEdge(from, to *Block) {
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+// emit adds in to the current block, unless it's unreachable, and returns
+// it as a Value if it has one.
+func (b *builder) emit(in Instr) Value {
+	if b.block == nil {
+		return &Const{}
+	}
+	if r, ok := in.(interface{ reg() *register }); ok {
+		r.reg().id = b.nextID
+		b.nextID++
+	}
+	in.setBlock(b.block)
+	b.block.Instrs = append(b.block.Instrs, in)
+	v, _ := in.(Value)
+	return v
+}
+
+// bind sets v, which is being declared, to x.
+func (b *builder) bind(v *variable, x Value) {
+	if v == nil {
+		return
+	}
+	if v.cell {
+		b.emit(&Store{Cell: b.values[v], Val: x})
+		return
+	}
+	b.values[v] = x
+}
+
+// statements builds stmts and returns their value.
+func (b *builder) statements(stmts []ast.Statement) Value {
+	var result Value = &Const{}
+	for _, s := range stmts {
+		if b.block == nil {
+			// The rest is unreachable.
+			break
+		}
+		result = &Const{}
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			if s.Value != nil {
+				b.bind(b.vars[b.info.Defs[s.Name]], b.expression(s.Value))
+			}
+		case *ast.ReturnStatement:
+			var x Value = &Const{}
+			if s.ReturnValue != nil {
+				x = b.expression(s.ReturnValue)
+			}
+			b.emit(&Return{Result: x})
+			b.block = nil
+		case *ast.ExpressionStatement:
+			if s.Expression != nil {
+				result = b.expression(s.Expression)
+			}
+		case *ast.BlockStatement:
+			result = b.statements(s.Statements)
+		}
+	}
+	return result
+}
+
+func (b *builder) expression(e ast.Expression) Value {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return &Const{Value: e.Value}
+	case *ast.Boolean:
+		return &Const{Value: e.Value}
+	case *ast.Identifier:
+		v := b.vars[b.info.Uses[e]]
+		x := b.values[v]
+		if v.cell {
+			return b.emit(&Load{Cell: x})
+		}
+		return x
+	case *ast.PrefixExpression:
+		return b.emit(&UnOp{Op: e.Operator, X: b.expression(e.Right)})
+	case *ast.InfixExpression:
+		x := b.expression(e.Left)
+		y := b.expression(e.Right)
+		return b.emit(&BinOp{Op: e.Operator, X: x, Y: y})
+	case *ast.IfExpression:
+		return b.ifExpression(e)
+	case *ast.FunctionLiteral:
+		fi := b.fns[e]
+		f := &Func{Name: b.funcName(fi.name), Parent: b.fn, Syntax: e}
+		b.prog.Funcs = append(b.prog.Funcs, f)
+		var bindings []Value
+		for _, v := range fi.free {
+			bindings = append(bindings, b.values[v])
+		}
+		b.function(f, e, e.Body.Statements)
+		return b.emit(&MakeClosure{Fn: f, Bindings: bindings})
+	case *ast.CallExpression:
+		fn := b.expression(e.Function)
+		var args []Value
+		for _, a := range e.Arguments {
+			args = append(args, b.expression(a))
+		}
+		return b.emit(&Call{Fn: fn, Args: args})
+	}
+	return &Const{}
+}
+
+func (b *builder) ifExpression(ie *ast.IfExpression) Value {
+	cond := b.expression(ie.Condition)
+	if b.block == nil {
+		return &Const{}
+	}
+	start := b.block
+	b.emit(&If{Cond: cond})
+
+	then := b.newBlock()
+	addEdge(start, then)
+	b.block = then
+	thenValue := b.statements(ie.Consequence.Statements)
+	thenEnd := b.block
+
+	// Without an else branch, control goes straight from the start to where
+	// the branches meet.
+	var elseValue Value = &Const{}
+	elseEnd := start
+	if ie.Alternative != nil {
+		els := b.newBlock()
+		addEdge(start, els)
+		b.block = els
+		elseValue = b.statements(ie.Alternative.Statements)
+		elseEnd = b.block
+	}
+
+	// The branches which don't return.
+	var ends []*Block
+	var values []Value
+	if thenEnd != nil {
+		ends = append(ends, thenEnd)
+		values = append(values, thenValue)
+	}
+	if elseEnd != nil {
+		ends = append(ends, elseEnd)
+		values = append(values, elseValue)
+	}
+	if ie.Alternative != nil && len(ends) < 2 {
+		if len(ends) == 0 {
+			b.block = nil
+			return &Const{}
+		}
+		// Carry on in the only branch which doesn't return.
+		b.block = ends[0]
+		return values[0]
+	}
+
+	merge := b.newBlock()
+	for _, end := range ends {
+		if end != start {
+			b.block = end
+			b.emit(&Jump{})
+		}
+		addEdge(end, merge)
+	}
+	b.block = merge
+	if len(ends) == 1 {
+		return values[0]
+	}
+	return b.emit(&Phi{Edges: values})
+}
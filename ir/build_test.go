@@ -0,0 +1,430 @@
+package ir
+
+import (
+	"fmt"
+	"testing"
+
+	"monkey/parser"
+)
+
+func build(t *testing.T, input string) *Program {
+	t.Helper()
+	p, err := Build(parser.MustParse(input))
+	if err != nil {
+		t.Fatalf("Build(%q): %v", input, err)
+	}
+	return p
+}
+
+// programs are monkey programs and what they evaluate to.
+var programs = []struct {
+	input string
+	want  string
+}{
+	{"1 + 2 * 3", "7"},
+	{"10 / 3 - -2", "5"},
+	{"!5; !!false", "false"},
+	{"1 == 1 != false", "true"},
+	{"1 == true", "false"},
+	{"let f = fn(x) { x }; f == f", "true"},
+	{"", "null"},
+	{"let x = 5;", "null"},
+	{"if (0) { 1 }", "1"},
+	{"if (false) { 1 }", "null"},
+	{"if (1 > 2) { 1 } else { 2 }", "2"},
+	{"fn() {}()", "null"},
+	{
+		input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(20)",
+		want:  "2432902008176640000",
+	},
+	{
+		input: "let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(40)",
+		want:  "42",
+	},
+	{
+		input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7)",
+		want:  "true",
+	},
+	{
+		input: "let sign = fn(n) { if (n < 0) { return -1; }; if (n == 0) { 0 } else { 1 } }; sign(-5) + sign(0) * 10 + sign(7) * 100",
+		want:  "99",
+	},
+	{
+		input: "let x = 1; let x = x + 1; let f = fn(x) { let x = x * 10; let g = fn() { let x = x + 1; x }; g() }; f(x)",
+		want:  "21",
+	},
+	{
+		input: "let a = 1; let f = fn() { let g = fn() { a }; let a = 2; g() }; f() + a",
+		want:  "3",
+	},
+	{
+		input: "let x = 1; let f = fn() { x }; let x = 2; f()",
+		want:  "2",
+	},
+	{
+		input: "let add = fn(x, x) { x }; add(1, 2)",
+		want:  "2",
+	},
+	{
+		input: "let f = fn(unused) { 1 + if (unused) { let y = 2; y * y } }; f(true) + f(1)",
+		want:  "10",
+	},
+	{
+		input: "let r = 5; if (r > 2) { return r * 2; }; r",
+		want:  "10",
+	},
+	{"9223372036854775807 + 1", "-9223372036854775808"},
+	{"-7 / 2", "-3"},
+	{"let f = fn() { 1 }; f", "fn"},
+	{
+		input: "let compose = fn(f, g) { fn(x) { f(g(x)) } }; let inc = fn(x) { x + 1 }; let dbl = fn(x) { x * 2 }; compose(inc, dbl)(5)",
+		want:  "11",
+	},
+	{
+		input: "let counter = fn(n) { let next = fn() { counter(n + 1) }; if (n == 3) { n } else { next() } }; counter(0)",
+		want:  "3",
+	},
+	{
+		input: "let f = fn(x) { let y = if (x) { return 1; 2 } else { 3 }; y }; f(true) * 10 + f(false)",
+		want:  "13",
+	},
+	{
+		input: "let f = fn(x) { if (x) { return 1; } else { return 2; }; 3 }; f(true) * 10 + f(false)",
+		want:  "12",
+	},
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)",
+			want: `func main()
+b0:
+	%0 = cell
+	%1 = closure fact [%0]
+	store %0, %1
+	%2 = load %0
+	%3 = call %2(5)
+	return %3
+
+func fact(n) free(fact)
+b0:
+	%0 = n < 2
+	if %0 b1 b2
+b1: ; preds b0
+	jump b3
+b2: ; preds b0
+	%1 = load fact
+	%2 = n - 1
+	%3 = call %1(%2)
+	%4 = n * %3
+	jump b3
+b3: ; preds b1 b2
+	%5 = phi [b1: 1, b2: %4]
+	return %5
+`,
+		},
+		{
+			input: "let adder = fn(x) { fn(y) { x + y } }; adder(2)(40)",
+			want: `func main()
+b0:
+	%0 = closure adder []
+	%1 = call %0(2)
+	%2 = call %1(40)
+	return %2
+
+func adder(x)
+b0:
+	%0 = closure fn1 [x]
+	return %0
+
+func fn1(y) free(x)
+b0:
+	%0 = x + y
+	return %0
+`,
+		},
+		{
+			input: "let x = 1; let f = fn() { x }; let x = 2; if (x) { return f(); }; -x",
+			want: `func main()
+b0:
+	%0 = cell
+	store %0, 1
+	%1 = closure f [%0]
+	store %0, 2
+	%2 = load %0
+	if %2 b1 b2
+b1: ; preds b0
+	%3 = call %1()
+	return %3
+b2: ; preds b0
+	%4 = load %0
+	%5 = -%4
+	return %5
+
+func f() free(x)
+b0:
+	%0 = load x
+	return %0
+`,
+		},
+		{
+			input: "let f = fn(a, a) { let g = fn(a) { a }; g }; let f = fn() { fn() {} }",
+			want: `func main()
+b0:
+	%0 = closure f []
+	%1 = closure f2 []
+	return null
+
+func f(a, a1)
+b0:
+	%0 = closure g []
+	return %0
+
+func g(a)
+b0:
+	return a
+
+func f2()
+b0:
+	%0 = closure fn1 []
+	return %0
+
+func fn1()
+b0:
+	return null
+`,
+		},
+	}
+	for i, tc := range tests {
+		if got := build(t, tc.input).String(); got != tc.want {
+			t.Errorf("%d. Build(%q) =\n%s\nwant:\n%s", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x + 1", "1:1: undefined: x"},
+		{"let a = b; let b = 1;", "1:9: b used before its declaration at 1:16"},
+	}
+	for i, tc := range tests {
+		_, err := Build(parser.MustParse(tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. Build(%q) error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// TestRun checks the programs evaluate to what they should, by interpreting
+// their IR.
+func TestRun(t *testing.T) {
+	for i, tc := range programs {
+		p := build(t, tc.input)
+		if err := Verify(p); err != nil {
+			t.Errorf("%d. Verify(Build(%q)): %v\n%s", i, tc.input, err, p)
+			continue
+		}
+		got, err := run(p)
+		if err != nil {
+			t.Errorf("%d. %q: %v", i, tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%d. %q = %s, want %s", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"let x = 1; x(2)", "not a function: INTEGER"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"1 / 0", "division by zero"},
+	}
+	for i, tc := range tests {
+		_, err := run(build(t, tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. %q: error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// closure and cell are the interpreter's functions and cells.
+type closure struct {
+	fn  *Func
+	env []interface{}
+}
+
+type cell struct {
+	value interface{}
+}
+
+type runError struct {
+	msg string
+}
+
+// run interprets p, returning the printed value of the program.
+func run(p *Program) (result string, err error) {
+	defer func() {
+		if r, ok := recover().(runError); ok {
+			err = fmt.Errorf("%s", r.msg)
+		}
+	}()
+	switch v := call(&closure{fn: p.Funcs[0]}, nil).(type) {
+	case nil:
+		return "null", nil
+	case *closure:
+		return "fn", nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func fail(format string, args ...interface{}) {
+	panic(runError{fmt.Sprintf(format, args...)})
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "INTEGER"
+	case bool:
+		return "BOOLEAN"
+	case *closure:
+		return "FUNCTION"
+	}
+	return "NULL"
+}
+
+func call(c *closure, args []interface{}) interface{} {
+	f := c.fn
+	if len(args) != len(f.Params) {
+		fail("wrong number of arguments: want=%d, got=%d", len(f.Params), len(args))
+	}
+	values := make(map[Value]interface{})
+	for i, p := range f.Params {
+		values[p] = args[i]
+	}
+	for i, v := range f.FreeVars {
+		values[v] = c.env[i]
+	}
+	get := func(v Value) interface{} {
+		if c, ok := v.(*Const); ok {
+			return c.Value
+		}
+		return values[v]
+	}
+
+	var pred *Block
+	for b := f.Blocks[0]; ; {
+		// Phis are evaluated together, on the edge into the block.
+		phis := make(map[Value]interface{})
+		for _, in := range b.Instrs {
+			if phi, ok := in.(*Phi); ok {
+				for i, p := range b.Preds {
+					if p == pred {
+						phis[phi] = get(phi.Edges[i])
+					}
+				}
+			}
+		}
+		for v, x := range phis {
+			values[v] = x
+		}
+
+		var next *Block
+		for _, in := range b.Instrs {
+			switch in := in.(type) {
+			case *UnOp:
+				x := get(in.X)
+				if in.Op == "!" {
+					values[in] = !truthy(x)
+				} else if i, ok := x.(int64); ok {
+					values[in] = -i
+				} else {
+					fail("unknown operator: -%s", typeName(x))
+				}
+			case *BinOp:
+				values[in] = binOp(in.Op, get(in.X), get(in.Y))
+			case *Call:
+				fn, ok := get(in.Fn).(*closure)
+				if !ok {
+					fail("not a function: %s", typeName(get(in.Fn)))
+				}
+				var args []interface{}
+				for _, a := range in.Args {
+					args = append(args, get(a))
+				}
+				values[in] = call(fn, args)
+			case *MakeClosure:
+				var env []interface{}
+				for _, v := range in.Bindings {
+					env = append(env, get(v))
+				}
+				values[in] = &closure{fn: in.Fn, env: env}
+			case *NewCell:
+				values[in] = &cell{}
+			case *Load:
+				values[in] = get(in.Cell).(*cell).value
+			case *Store:
+				get(in.Cell).(*cell).value = get(in.Val)
+			case *Jump:
+				next = b.Succs[0]
+			case *If:
+				next = b.Succs[1]
+				if truthy(get(in.Cond)) {
+					next = b.Succs[0]
+				}
+			case *Return:
+				return get(in.Result)
+			}
+		}
+		pred, b = b, next
+	}
+}
+
+func truthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+func binOp(op string, x, y interface{}) interface{} {
+	switch op {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	}
+	a, aok := x.(int64)
+	b, bok := y.(int64)
+	if !aok || !bok {
+		fail("type mismatch: %s %s %s", typeName(x), op, typeName(y))
+	}
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			fail("division by zero")
+		}
+		return a / b
+	case "<":
+		return a < b
+	}
+	return a > b
+}
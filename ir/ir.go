@@ -0,0 +1,301 @@
+// Package ir is an intermediate representation of monkey programs, in static
+// single assignment form, for optimizers and backends.
+//
+// A Program is a list of functions. Each Func is a control flow graph of
+// basic blocks, each a list of instructions ending with a jump, a branch or a
+// return. Every instruction which produces a value does so exactly once, and
+// the value of an if expression is merged from its branches by a Phi.
+//
+// Functions don't refer to the variables of the functions enclosing them;
+// what a function captures is explicit. Its FreeVars are bound, when a
+// MakeClosure instruction makes a closure of it, to values of the enclosing
+// function. Monkey's functions may use variables which are declared after
+// them, such as a recursive function using the variable it's bound to, or
+// may see a variable redeclared after they're made. Such variables are held
+// in cells, which are captured instead, and read and written with Load and
+// Store.
+package ir
+
+import (
+	"fmt"
+	"strings"
+
+	"monkey/ast"
+)
+
+// Program is a monkey program's functions. The first is the program itself.
+type Program struct {
+	Funcs []*Func
+}
+
+// Func is a function: a monkey function literal, or the top level of the
+// program.
+type Func struct {
+	// Name is unique in the program.
+	Name     string
+	Params   []*Param
+	FreeVars []*FreeVar
+	// Blocks are the function's basic blocks; the first is its entry.
+	Blocks []*Block
+	// Parent is the function in which the function literal appears, or nil.
+	Parent *Func
+	// Syntax is the *ast.FunctionLiteral or *ast.Program.
+	Syntax ast.Node
+}
+
+// Block is a basic block.
+type Block struct {
+	// Index is the position of the block in its function's Blocks.
+	Index  int
+	Func   *Func
+	Instrs []Instr
+	// Preds and Succs are the blocks which jump to and are jumped to from
+	// the block. The successors of an If are its then block and its else
+	// block, in that order.
+	Preds, Succs []*Block
+}
+
+func (b *Block) String() string { return fmt.Sprintf("b%d", b.Index) }
+
+// Value is an operand of an instruction.
+type Value interface {
+	// Name returns the value as it's written in an operand.
+	Name() string
+}
+
+// Instr is an instruction.
+type Instr interface {
+	Block() *Block
+	// Operands returns the values the instruction uses.
+	Operands() []Value
+	// String returns the instruction as it's written in a dump.
+	String() string
+	setBlock(b *Block)
+}
+
+// Const is a constant: an int64, a bool, or nil for null.
+type Const struct {
+	Value interface{}
+}
+
+// Param is a parameter of a function.
+type Param struct {
+	name string
+	Func *Func
+}
+
+// FreeVar is a value captured by a closure.
+type FreeVar struct {
+	name string
+	Func *Func
+}
+
+func (c *Const) Name() string {
+	if c.Value == nil {
+		return "null"
+	}
+	return fmt.Sprint(c.Value)
+}
+
+func (p *Param) Name() string   { return p.name }
+func (v *FreeVar) Name() string { return v.name }
+
+// anInstr is embedded in every instruction.
+type anInstr struct {
+	block *Block
+}
+
+func (i *anInstr) Block() *Block     { return i.block }
+func (i *anInstr) setBlock(b *Block) { i.block = b }
+
+// register is embedded in instructions which produce a value.
+type register struct {
+	anInstr
+	id int // unique in the function
+}
+
+func (r *register) Name() string   { return fmt.Sprintf("%%%d", r.id) }
+func (r *register) reg() *register { return r }
+
+// UnOp applies the prefix operator Op, - or !, to X.
+type UnOp struct {
+	register
+	Op string
+	X  Value
+}
+
+// BinOp applies the infix operator Op to X and Y.
+type BinOp struct {
+	register
+	Op   string
+	X, Y Value
+}
+
+// Call calls Fn with Args.
+type Call struct {
+	register
+	Fn   Value
+	Args []Value
+}
+
+// MakeClosure makes a closure of Fn. Bindings are the values of Fn's
+// FreeVars.
+type MakeClosure struct {
+	register
+	Fn       *Func
+	Bindings []Value
+}
+
+// Phi is the value of Edges[i] when control comes from the block's Preds[i].
+type Phi struct {
+	register
+	Edges []Value
+}
+
+// NewCell makes a cell holding null.
+type NewCell struct {
+	register
+}
+
+// Load reads the value in Cell.
+type Load struct {
+	register
+	Cell Value
+}
+
+// Store writes Val to Cell.
+type Store struct {
+	anInstr
+	Cell, Val Value
+}
+
+// Jump jumps to the block's only successor.
+type Jump struct {
+	anInstr
+}
+
+// If jumps to the block's first successor if Cond is truthy, and to its
+// second otherwise.
+type If struct {
+	anInstr
+	Cond Value
+}
+
+// Return returns Result from the function.
+type Return struct {
+	anInstr
+	Result Value
+}
+
+func (v *UnOp) Operands() []Value        { return []Value{v.X} }
+func (v *BinOp) Operands() []Value       { return []Value{v.X, v.Y} }
+func (v *Call) Operands() []Value        { return append([]Value{v.Fn}, v.Args...) }
+func (v *MakeClosure) Operands() []Value { return v.Bindings }
+func (v *Phi) Operands() []Value         { return v.Edges }
+func (v *NewCell) Operands() []Value     { return nil }
+func (v *Load) Operands() []Value        { return []Value{v.Cell} }
+func (s *Store) Operands() []Value       { return []Value{s.Cell, s.Val} }
+func (*Jump) Operands() []Value          { return nil }
+func (s *If) Operands() []Value          { return []Value{s.Cond} }
+func (s *Return) Operands() []Value      { return []Value{s.Result} }
+
+func (v *UnOp) String() string {
+	return fmt.Sprintf("%s = %s%s", v.Name(), v.Op, v.X.Name())
+}
+
+func (v *BinOp) String() string {
+	return fmt.Sprintf("%s = %s %s %s", v.Name(), v.X.Name(), v.Op, v.Y.Name())
+}
+
+func (v *Call) String() string {
+	return fmt.Sprintf("%s = call %s(%s)", v.Name(), v.Fn.Name(), names(v.Args))
+}
+
+func (v *MakeClosure) String() string {
+	return fmt.Sprintf("%s = closure %s [%s]", v.Name(), v.Fn.Name, names(v.Bindings))
+}
+
+func (v *Phi) String() string {
+	var edges []string
+	for i, e := range v.Edges {
+		pred := "?"
+		if v.block != nil && i < len(v.block.Preds) {
+			pred = v.block.Preds[i].String()
+		}
+		edges = append(edges, pred+": "+e.Name())
+	}
+	return fmt.Sprintf("%s = phi [%s]", v.Name(), strings.Join(edges, ", "))
+}
+
+func (v *NewCell) String() string { return v.Name() + " = cell" }
+func (v *Load) String() string    { return fmt.Sprintf("%s = load %s", v.Name(), v.Cell.Name()) }
+func (s *Store) String() string   { return fmt.Sprintf("store %s, %s", s.Cell.Name(), s.Val.Name()) }
+func (s *Return) String() string  { return "return " + s.Result.Name() }
+
+func (s *Jump) String() string {
+	if s.block == nil || len(s.block.Succs) != 1 {
+		return "jump ?"
+	}
+	return "jump " + s.block.Succs[0].String()
+}
+
+func (s *If) String() string {
+	if s.block == nil || len(s.block.Succs) != 2 {
+		return "if " + s.Cond.Name() + " ? ?"
+	}
+	return fmt.Sprintf("if %s %s %s", s.Cond.Name(), s.block.Succs[0], s.block.Succs[1])
+}
+
+func names(vs []Value) string {
+	var s []string
+	for _, v := range vs {
+		s = append(s, v.Name())
+	}
+	return strings.Join(s, ", ")
+}
+
+// String returns the function's dump, such as
+//
+//	func fact(n) free(fact)
+//	b0:
+//		%0 = n < 2
+//		if %0 b1 b2
+//	...
+func (f *Func) String() string {
+	var out strings.Builder
+	var params, free []string
+	for _, p := range f.Params {
+		params = append(params, p.name)
+	}
+	for _, v := range f.FreeVars {
+		free = append(free, v.name)
+	}
+	fmt.Fprintf(&out, "func %s(%s)", f.Name, strings.Join(params, ", "))
+	if len(free) > 0 {
+		fmt.Fprintf(&out, " free(%s)", strings.Join(free, ", "))
+	}
+	out.WriteString("\n")
+	for _, b := range f.Blocks {
+		out.WriteString(b.String() + ":")
+		if len(b.Preds) > 0 {
+			out.WriteString(" ; preds")
+			for _, p := range b.Preds {
+				out.WriteString(" " + p.String())
+			}
+		}
+		out.WriteString("\n")
+		for _, in := range b.Instrs {
+			out.WriteString("\t" + in.String() + "\n")
+		}
+	}
+	return out.String()
+}
+
+// String returns the dumps of the program's functions.
+func (p *Program) String() string {
+	var out []string
+	for _, f := range p.Funcs {
+		out = append(out, f.String())
+	}
+	return strings.Join(out, "\n")
+}
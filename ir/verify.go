@@ -0,0 +1,297 @@
+package ir
+
+import "fmt"
+
+// Verify checks that p is well formed: that every block ends with exactly
+// one jump, branch or return, that the edges between blocks agree with them,
+// that every value is defined in the function which uses it before it's
+// used, and that closures bind every free variable. It returns the first
+// problem found.
+func Verify(p *Program) error {
+	if len(p.Funcs) == 0 {
+		return fmt.Errorf("program has no functions")
+	}
+	funcs := make(map[*Func]bool)
+	for _, f := range p.Funcs {
+		if funcs[f] {
+			return fmt.Errorf("%s: listed more than once", f.Name)
+		}
+		funcs[f] = true
+	}
+	names := make(map[string]bool)
+	for i, f := range p.Funcs {
+		if names[f.Name] {
+			return fmt.Errorf("%s: name isn't unique", f.Name)
+		}
+		names[f.Name] = true
+		if (i == 0) != (f.Parent == nil) {
+			return fmt.Errorf("%s: only the first function has no parent", f.Name)
+		}
+		if err := verifyFunc(f, funcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type verifier struct {
+	f *Func
+	// doms are the blocks which dominate each block.
+	doms []map[*Block]bool
+	// defs are the instructions which produce values, and where they are.
+	defs map[Value]int // index in its block
+}
+
+func (v *verifier) errorf(b *Block, format string, args ...interface{}) error {
+	if b == nil {
+		return fmt.Errorf("%s: %s", v.f.Name, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("%s: %s: %s", v.f.Name, b, fmt.Sprintf(format, args...))
+}
+
+func verifyFunc(f *Func, funcs map[*Func]bool) error {
+	v := &verifier{f: f, defs: make(map[Value]int)}
+	if len(f.Blocks) == 0 {
+		return v.errorf(nil, "no blocks")
+	}
+	if len(f.Blocks[0].Preds) > 0 {
+		return v.errorf(f.Blocks[0], "entry block has predecessors")
+	}
+	for _, p := range f.Params {
+		if p.Func != f {
+			return v.errorf(nil, "parameter %s belongs to another function", p.Name())
+		}
+	}
+	for _, fv := range f.FreeVars {
+		if fv.Func != f {
+			return v.errorf(nil, "free variable %s belongs to another function", fv.Name())
+		}
+	}
+
+	ids := make(map[int]bool)
+	for i, b := range f.Blocks {
+		if b.Index != i || b.Func != f {
+			return v.errorf(b, "block is at index %d of %s", i, f.Name)
+		}
+		if err := v.edges(b); err != nil {
+			return err
+		}
+		if len(b.Instrs) == 0 {
+			return v.errorf(b, "empty block")
+		}
+		for j, in := range b.Instrs {
+			if in.Block() != b {
+				return v.errorf(b, "%s: instruction belongs to another block", in)
+			}
+			if err := v.instr(b, j, in); err != nil {
+				return err
+			}
+			if r, ok := in.(interface{ reg() *register }); ok {
+				if ids[r.reg().id] {
+					return v.errorf(b, "%s: %s is defined more than once", in, r.reg().Name())
+				}
+				ids[r.reg().id] = true
+				if _, dup := v.defs[in.(Value)]; dup {
+					return v.errorf(b, "%s: instruction appears more than once", in)
+				}
+				v.defs[in.(Value)] = j
+			}
+		}
+	}
+
+	if err := v.dominators(); err != nil {
+		return err
+	}
+	for _, b := range f.Blocks {
+		for j, in := range b.Instrs {
+			if err := v.operands(b, j, in, funcs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// edges checks that b's edges agree with its successors' and predecessors'.
+func (v *verifier) edges(b *Block) error {
+	count := func(bs []*Block, x *Block) int {
+		n := 0
+		for _, y := range bs {
+			if y == x {
+				n++
+			}
+		}
+		return n
+	}
+	for _, s := range b.Succs {
+		if s.Func != v.f {
+			return v.errorf(b, "successor %s is in another function", s)
+		}
+		if count(b.Succs, s) != count(s.Preds, b) {
+			return v.errorf(b, "edge to %s isn't in its predecessors", s)
+		}
+	}
+	for _, p := range b.Preds {
+		if p.Func != v.f {
+			return v.errorf(b, "predecessor %s is in another function", p)
+		}
+		if count(b.Preds, p) != count(p.Succs, b) {
+			return v.errorf(b, "edge from %s isn't in its successors", p)
+		}
+	}
+	return nil
+}
+
+// instr checks in, the jth instruction of b, by itself.
+func (v *verifier) instr(b *Block, j int, in Instr) error {
+	last := j == len(b.Instrs)-1
+	succs := -1
+	switch in := in.(type) {
+	case *Jump:
+		succs = 1
+	case *If:
+		succs = 2
+	case *Return:
+		succs = 0
+	case *Phi:
+		if j > 0 {
+			if _, ok := b.Instrs[j-1].(*Phi); !ok {
+				return v.errorf(b, "%s: phi after other instructions", in)
+			}
+		}
+		if len(in.Edges) != len(b.Preds) {
+			return v.errorf(b, "%s: %d edges for %d predecessors", in, len(in.Edges), len(b.Preds))
+		}
+	case *UnOp:
+		if in.Op != "-" && in.Op != "!" {
+			return v.errorf(b, "%s: unknown operator %q", in, in.Op)
+		}
+	case *BinOp:
+		switch in.Op {
+		case "+", "-", "*", "/", "<", ">", "==", "!=":
+		default:
+			return v.errorf(b, "%s: unknown operator %q", in, in.Op)
+		}
+	}
+	if succs < 0 {
+		if last {
+			return v.errorf(b, "block doesn't end with a jump, branch or return")
+		}
+		return nil
+	}
+	if !last {
+		return v.errorf(b, "%s: control instruction isn't at the end of the block", in)
+	}
+	if len(b.Succs) != succs {
+		return v.errorf(b, "%s: block has %d successors, want %d", in, len(b.Succs), succs)
+	}
+	return nil
+}
+
+// dominators works out which blocks dominate each block, and checks every
+// block is reachable.
+func (v *verifier) dominators() error {
+	blocks := v.f.Blocks
+	reachable := make(map[*Block]bool)
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		if !reachable[b] {
+			reachable[b] = true
+			for _, s := range b.Succs {
+				visit(s)
+			}
+		}
+	}
+	visit(blocks[0])
+	for _, b := range blocks {
+		if !reachable[b] {
+			return v.errorf(b, "unreachable block")
+		}
+	}
+
+	v.doms = make([]map[*Block]bool, len(blocks))
+	v.doms[0] = map[*Block]bool{blocks[0]: true}
+	for i := 1; i < len(blocks); i++ {
+		all := make(map[*Block]bool)
+		for _, b := range blocks {
+			all[b] = true
+		}
+		v.doms[i] = all
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, b := range blocks[1:] {
+			doms := make(map[*Block]bool)
+			for d := range v.doms[b.Preds[0].Index] {
+				doms[d] = true
+			}
+			for _, p := range b.Preds[1:] {
+				for d := range doms {
+					if !v.doms[p.Index][d] {
+						delete(doms, d)
+					}
+				}
+			}
+			doms[b] = true
+			if len(doms) != len(v.doms[i+1]) {
+				v.doms[i+1] = doms
+				changed = true
+			}
+		}
+	}
+	return nil
+}
+
+// operands checks that the operands of in, the jth instruction of b, are
+// available there.
+func (v *verifier) operands(b *Block, j int, in Instr, funcs map[*Func]bool) error {
+	if mc, ok := in.(*MakeClosure); ok {
+		if !funcs[mc.Fn] {
+			return v.errorf(b, "%s: function isn't in the program", in)
+		}
+		if mc.Fn.Parent != v.f {
+			return v.errorf(b, "%s: function isn't nested in %s", in, v.f.Name)
+		}
+		if len(mc.Bindings) != len(mc.Fn.FreeVars) {
+			return v.errorf(b, "%s: %d bindings for %d free variables", in, len(mc.Bindings), len(mc.Fn.FreeVars))
+		}
+	}
+	for k, x := range in.Operands() {
+		// Where x must be available: at the end of the predecessor for a
+		// phi, and before the instruction otherwise.
+		at, before := b, j
+		if _, ok := in.(*Phi); ok {
+			at, before = b.Preds[k], len(b.Preds[k].Instrs)
+		}
+		switch x := x.(type) {
+		case nil:
+			return v.errorf(b, "%s: missing operand", in)
+		case *Const:
+			switch x.Value.(type) {
+			case nil, int64, bool:
+			default:
+				return v.errorf(b, "%s: constant of type %T", in, x.Value)
+			}
+		case *Param:
+			if x.Func != v.f {
+				return v.errorf(b, "%s: %s is a parameter of another function", in, x.Name())
+			}
+		case *FreeVar:
+			if x.Func != v.f {
+				return v.errorf(b, "%s: %s is a free variable of another function", in, x.Name())
+			}
+		case Instr:
+			idx, ok := v.defs[x.(Value)]
+			if !ok {
+				return v.errorf(b, "%s: %s isn't defined in %s", in, x.(Value).Name(), v.f.Name)
+			}
+			def := x.Block()
+			if def == at && idx >= before || def != at && !v.doms[at.Index][def] {
+				return v.errorf(b, "%s: %s isn't defined before it's used", in, x.(Value).Name())
+			}
+		default:
+			return v.errorf(b, "%s: unknown operand %T", in, x)
+		}
+	}
+	return nil
+}
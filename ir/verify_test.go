@@ -0,0 +1,124 @@
+package ir
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	for i, tc := range programs {
+		if err := Verify(build(t, tc.input)); err != nil {
+			t.Errorf("%d. Verify(Build(%q)): %v", i, tc.input, err)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	const fact = "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)"
+	tests := []struct {
+		name  string
+		input string
+		// breakIt breaks the program built from input.
+		breakIt func(p *Program)
+		want    string
+	}{
+		{
+			name:  "no return",
+			input: "1",
+			breakIt: func(p *Program) {
+				b := p.Funcs[0].Blocks[0]
+				b.Instrs = b.Instrs[:len(b.Instrs)-1]
+			},
+			want: "main: b0: empty block",
+		},
+		{
+			name:  "instruction after return",
+			input: "1 + 2",
+			breakIt: func(p *Program) {
+				b := p.Funcs[0].Blocks[0]
+				b.Instrs[0], b.Instrs[1] = b.Instrs[1], b.Instrs[0]
+			},
+			want: "main: b0: return %0: control instruction isn't at the end of the block",
+		},
+		{
+			name:  "use before definition",
+			input: "let x = 1 + 2; x * x",
+			breakIt: func(p *Program) {
+				b := p.Funcs[0].Blocks[0]
+				b.Instrs[0], b.Instrs[1] = b.Instrs[1], b.Instrs[0]
+			},
+			want: "main: b0: %1 = %0 * %0: %0 isn't defined before it's used",
+		},
+		{
+			name:  "use in a block it doesn't dominate",
+			input: fact,
+			breakIt: func(p *Program) {
+				f := p.Funcs[1]
+				f.Blocks[3].Instrs[1].(*Return).Result = f.Blocks[2].Instrs[3].(Value)
+			},
+			want: "fact: b3: return %4: %4 isn't defined before it's used",
+		},
+		{
+			name:  "phi without an edge",
+			input: fact,
+			breakIt: func(p *Program) {
+				phi := p.Funcs[1].Blocks[3].Instrs[0].(*Phi)
+				phi.Edges = phi.Edges[:1]
+			},
+			want: "fact: b3: %5 = phi [b1: 1]: 1 edges for 2 predecessors",
+		},
+		{
+			name:  "missing edge",
+			input: fact,
+			breakIt: func(p *Program) {
+				b := p.Funcs[1].Blocks[3]
+				b.Preds = b.Preds[:1]
+			},
+			want: "fact: b2: edge to b3 isn't in its predecessors",
+		},
+		{
+			name:  "unreachable block",
+			input: fact,
+			breakIt: func(p *Program) {
+				f := p.Funcs[1]
+				f.Blocks = append(f.Blocks, &Block{Index: 4, Func: f})
+				b := f.Blocks[4]
+				ret := &Return{Result: &Const{}}
+				ret.setBlock(b)
+				b.Instrs = []Instr{ret}
+			},
+			want: "fact: b4: unreachable block",
+		},
+		{
+			name:  "value from another function",
+			input: "let adder = fn(x) { fn(y) { x + y } }",
+			breakIt: func(p *Program) {
+				add := p.Funcs[2].Blocks[0].Instrs[0].(*BinOp)
+				add.X = p.Funcs[1].Params[0]
+			},
+			want: "fn1: b0: %0 = x + y: x is a parameter of another function",
+		},
+		{
+			name:  "unbound free variable",
+			input: fact,
+			breakIt: func(p *Program) {
+				mc := p.Funcs[0].Blocks[0].Instrs[1].(*MakeClosure)
+				mc.Bindings = nil
+			},
+			want: "main: b0: %1 = closure fact []: 0 bindings for 1 free variables",
+		},
+		{
+			name:  "duplicate name",
+			input: fact,
+			breakIt: func(p *Program) {
+				p.Funcs[1].Name = "main"
+			},
+			want: "main: name isn't unique",
+		},
+	}
+	for _, tc := range tests {
+		p := build(t, tc.input)
+		tc.breakIt(p)
+		err := Verify(p)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: Verify() = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/jsgen"
+)
+
+func runJS(args []string) error {
+	fs := flag.NewFlagSet("js", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey js [file ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		prog, err := parseFile(name)
+		if err != nil {
+			return err
+		}
+		js, err := jsgen.Generate(prog)
+		if err != nil {
+			return fmt.Errorf("%s:%v", name, err)
+		}
+		if _, err := os.Stdout.WriteString(js); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+// Package jsgen translates monkey programs into JavaScript (ES2015).
+//
+// The output is meant to be read as well as run. Functions become arrow
+// functions, let statements become let declarations, and the implicit result
+// of a block becomes an explicit return statement. An if expression becomes
+// a ternary when each branch is a single expression, an if statement when its
+// value is returned or thrown away, and otherwise an immediately invoked
+// arrow function.
+//
+// Monkey's semantics are kept where JavaScript's differ: only false and null
+// are falsy, division truncates and fails on zero, and == never converts
+// between types.
+// Integers are JavaScript numbers, so they are only exact up to 2^53.
+package jsgen
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// Binding strengths of JavaScript expressions.
+const (
+	precLowest = iota
+	precArrow  // arrow functions and ?:
+	precEquals
+	precLessGreater
+	precSum
+	precProduct
+	precPrefix
+	precCall
+	precAtom
+)
+
+var infixPrecs = map[string]int{
+	"==": precEquals,
+	"!=": precEquals,
+	"<":  precLessGreater,
+	">":  precLessGreater,
+	"+":  precSum,
+	"-":  precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+}
+
+// jsOperators are the JavaScript spellings of monkey operators which differ.
+var jsOperators = map[string]string{
+	"==": "===",
+	"!=": "!==",
+}
+
+// reserved are names which monkey allows but JavaScript doesn't, or which
+// the generated code relies on.
+var reserved = map[string]bool{
+	"arguments": true, "await": true, "break": true, "case": true,
+	"catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true,
+	"enum": true, "eval": true, "export": true, "extends": true,
+	"finally": true, "for": true, "function": true, "implements": true,
+	"import": true, "in": true, "instanceof": true, "interface": true,
+	"new": true, "null": true, "package": true, "private": true,
+	"protected": true, "public": true, "static": true, "super": true,
+	"switch": true, "this": true, "throw": true, "try": true,
+	"typeof": true, "undefined": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true,
+	"Infinity": true, "Math": true, "NaN": true,
+}
+
+// truthyHelper implements monkey's truthiness.
+const truthyHelper = "const $truthy = (v) => v !== false && v != null;"
+
+// divHelper implements monkey's division, which JavaScript would give
+// Infinity or NaN for when the divisor is 0.
+const divHelper = `const $div = (a, b) => {
+  if (b === 0) {
+    throw new Error("division by zero");
+  }
+  return Math.trunc(a / b);
+};`
+
+// Generate returns the JavaScript for prog.
+func Generate(prog *ast.Program) (string, error) {
+	g := &generator{
+		info:   resolve.Resolve(prog),
+		names:  make(map[*resolve.Decl]string),
+		assign: make(map[*resolve.Decl]bool),
+		counts: make(map[string]int),
+	}
+	g.nameScope(g.info.Scope)
+
+	var body string
+	if ast.HasReturn(prog) {
+		// Only functions can return, so wrap the program in one.
+		g.depth++
+		body = "(() => {\n" + g.statements(prog.Statements, discard) + "})();\n"
+	} else {
+		body = g.statements(prog.Statements, discard)
+	}
+	if g.err != nil {
+		return "", g.err
+	}
+	if g.usesDiv {
+		body = divHelper + "\n\n" + body
+	}
+	if g.usesTruthy {
+		body = truthyHelper + "\n\n" + body
+	}
+	return body, nil
+}
+
+// Fprint writes the JavaScript for prog to w.
+func Fprint(w io.Writer, prog *ast.Program) error {
+	js, err := Generate(prog)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, js)
+	return err
+}
+
+// mode says what happens to the value of a list of statements.
+type mode int
+
+const (
+	// discard throws the value away.
+	discard mode = iota
+	// ret returns the value from the enclosing JavaScript function.
+	ret
+)
+
+type generator struct {
+	info *resolve.Info
+	// names are the JavaScript names of declarations.
+	names map[*resolve.Decl]string
+	// assign are let statements which assign to a name already declared in
+	// the same scope, rather than declaring it.
+	assign map[*resolve.Decl]bool
+	counts map[string]int
+
+	depth int // indentation of the current line
+	// iife is set while generating the body of an immediately invoked
+	// function, where a monkey return statement can't be translated.
+	iife       bool
+	usesTruthy bool
+	usesDiv    bool
+	err        error
+}
+
+func (g *generator) errorf(pos token.Pos, format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf("%v: %s", pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// nameScope chooses the JavaScript names of the declarations in s and its
+// children. A let statement which shadows a name from an enclosing scope is
+// renamed, as JavaScript wouldn't let its value refer to the outer name.
+func (g *generator) nameScope(s *resolve.Scope) {
+	for i, d := range s.Decls {
+		var earlier *resolve.Decl
+		for _, e := range s.Decls[:i] {
+			if e.Name.Value == d.Name.Value {
+				earlier = e
+				break
+			}
+		}
+		switch {
+		case earlier != nil && d.Kind == resolve.Let:
+			g.names[d] = g.names[earlier]
+			g.assign[d] = true
+		case earlier != nil || d.Kind == resolve.Let && s.Parent != nil && s.Parent.Lookup(d.Name.Value) != nil:
+			g.names[d] = g.rename(d.Name.Value)
+		default:
+			g.names[d] = escape(d.Name.Value)
+		}
+	}
+	for _, c := range s.Children {
+		g.nameScope(c)
+	}
+}
+
+// rename returns a new name based on name. Monkey names can't contain $, so
+// it can't clash with any of them.
+func (g *generator) rename(name string) string {
+	g.counts[name]++
+	return name + "$" + strconv.Itoa(g.counts[name])
+}
+
+func escape(name string) string {
+	if reserved[name] {
+		return name + "$"
+	}
+	return name
+}
+
+func (g *generator) indent() string {
+	return strings.Repeat("  ", g.depth)
+}
+
+// statements returns the lines for stmts, indented to the current depth.
+func (g *generator) statements(stmts []ast.Statement, m mode) string {
+	var out strings.Builder
+	for i, s := range stmts {
+		sm := discard
+		if i == len(stmts)-1 {
+			sm = m
+		}
+		out.WriteString(g.statement(s, sm))
+	}
+	if m == ret && !endsWithReturn(stmts) {
+		out.WriteString(g.indent() + "return null;\n")
+	}
+	return out.String()
+}
+
+// endsWithReturn reports whether statements in ret mode return a value
+// themselves.
+func endsWithReturn(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch s := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok && !ternary(ie) {
+			return ie.Alternative != nil
+		}
+		return true
+	}
+	return false
+}
+
+// block returns stmts as a braced block, opening on the current line.
+func (g *generator) block(stmts []ast.Statement, m mode) string {
+	if len(stmts) == 0 && m == discard {
+		return "{}"
+	}
+	g.depth++
+	body := g.statements(stmts, m)
+	g.depth--
+	return "{\n" + body + g.indent() + "}"
+}
+
+func (g *generator) statement(s ast.Statement, m mode) string {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		decl := g.info.Defs[s.Name]
+		name := escape(s.Name.Value)
+		if decl != nil {
+			name = g.names[decl]
+		}
+		if decl != nil && g.assign[decl] {
+			return g.indent() + name + " = " + g.expression(s.Value, precArrow) + ";\n"
+		}
+		return g.indent() + "let " + name + " = " + g.expression(s.Value, precArrow) + ";\n"
+	case *ast.ReturnStatement:
+		if g.iife {
+			g.errorf(s.Pos(), "can't translate return inside an if expression whose value is used")
+		}
+		return g.indent() + "return " + g.expression(s.ReturnValue, precLowest) + ";\n"
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok && (m == discard || !ternary(ie)) {
+			return g.indent() + g.ifStatement(ie, m) + "\n"
+		}
+		if m == ret {
+			return g.indent() + "return " + g.expression(s.Expression, precLowest) + ";\n"
+		}
+		return g.indent() + g.expression(s.Expression, precLowest) + ";\n"
+	case *ast.BlockStatement:
+		return g.indent() + g.block(s.Statements, m) + "\n"
+	}
+	return ""
+}
+
+func (g *generator) ifStatement(ie *ast.IfExpression, m mode) string {
+	out := "if (" + g.condition(ie.Condition, precLowest) + ") " + g.block(ie.Consequence.Statements, m)
+	if ie.Alternative != nil {
+		out += " else " + g.block(ie.Alternative.Statements, m)
+	}
+	return out
+}
+
+// ternary reports whether each branch of ie is at most a single expression,
+// other than an if expression which isn't itself a ternary.
+func ternary(ie *ast.IfExpression) bool {
+	return simple(ie.Consequence) && simple(ie.Alternative)
+}
+
+func simple(b *ast.BlockStatement) bool {
+	if b == nil || len(b.Statements) == 0 {
+		return true
+	}
+	if len(b.Statements) > 1 {
+		return false
+	}
+	es, ok := b.Statements[0].(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil {
+		return false
+	}
+	if ie, ok := es.Expression.(*ast.IfExpression); ok {
+		return ternary(ie)
+	}
+	return true
+}
+
+// branch returns the value of a simple block.
+func (g *generator) branch(b *ast.BlockStatement) string {
+	if b == nil || len(b.Statements) == 0 {
+		return "null"
+	}
+	// Nested ternaries are parenthesised.
+	return g.expression(b.Statements[0].(*ast.ExpressionStatement).Expression, precEquals)
+}
+
+// isBool reports whether e always evaluates to a boolean.
+func isBool(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "!"
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "==", "!=", "<", ">":
+			return true
+		}
+	}
+	return false
+}
+
+// condition returns e tested for truthiness.
+func (g *generator) condition(e ast.Expression, min int) string {
+	if isBool(e) {
+		return g.expression(e, min)
+	}
+	g.usesTruthy = true
+	return "$truthy(" + g.expression(e, precArrow) + ")"
+}
+
+// expression returns e, wrapped in parentheses if it binds less tightly than
+// min.
+func (g *generator) expression(e ast.Expression, min int) string {
+	s, prec := g.expr(e)
+	if prec < min {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (g *generator) expr(e ast.Expression) (string, int) {
+	switch e := e.(type) {
+	case nil:
+		return "null", precAtom
+	case *ast.Identifier:
+		if decl := g.info.Uses[e]; decl != nil {
+			return g.names[decl], precAtom
+		}
+		return escape(e.Value), precAtom
+	case *ast.IntegerLiteral:
+		if e.Value < 0 {
+			return strconv.FormatInt(e.Value, 10), precPrefix
+		}
+		return strconv.FormatInt(e.Value, 10), precAtom
+	case *ast.Boolean:
+		return strconv.FormatBool(e.Value), precAtom
+	case *ast.PrefixExpression:
+		if e.Operator == "!" {
+			return "!" + g.condition(e.Right, precPrefix), precPrefix
+		}
+		right := g.expression(e.Right, precPrefix)
+		if strings.HasPrefix(right, "-") {
+			// Not --.
+			right = "(" + right + ")"
+		}
+		return e.Operator + right, precPrefix
+	case *ast.InfixExpression:
+		prec := infixPrecs[e.Operator]
+		left := g.expression(e.Left, prec)
+		right := g.expression(e.Right, prec+1)
+		if e.Operator == "/" {
+			if lit, ok := e.Right.(*ast.IntegerLiteral); ok && lit.Value != 0 {
+				return "Math.trunc(" + left + " / " + right + ")", precCall
+			}
+			g.usesDiv = true
+			return "$div(" + g.expression(e.Left, precArrow) + ", " + g.expression(e.Right, precArrow) + ")", precCall
+		}
+		op := e.Operator
+		if js, ok := jsOperators[op]; ok {
+			op = js
+		}
+		return left + " " + op + " " + right, prec
+	case *ast.IfExpression:
+		if ternary(e) {
+			return g.condition(e.Condition, precEquals) + " ? " + g.branch(e.Consequence) + " : " + g.branch(e.Alternative), precArrow
+		}
+		iife := g.iife
+		g.iife = true
+		body := g.block([]ast.Statement{&ast.ExpressionStatement{Token: e.Token, Expression: e}}, ret)
+		g.iife = iife
+		return "(() => " + body + ")()", precCall
+	case *ast.FunctionLiteral:
+		return g.function(e), precArrow
+	case *ast.CallExpression:
+		var args []string
+		for _, a := range e.Arguments {
+			args = append(args, g.expression(a, precArrow))
+		}
+		return g.expression(e.Function, precCall) + "(" + strings.Join(args, ", ") + ")", precCall
+	}
+	g.errorf(e.Pos(), "can't translate %T", e)
+	return "null", precAtom
+}
+
+func (g *generator) function(fl *ast.FunctionLiteral) string {
+	var params []string
+	for _, p := range fl.Parameters {
+		name := escape(p.Value)
+		if decl := g.info.Defs[p]; decl != nil {
+			name = g.names[decl]
+		}
+		params = append(params, name)
+	}
+	out := "(" + strings.Join(params, ", ") + ") => "
+
+	iife := g.iife
+	g.iife = false
+	defer func() { g.iife = iife }()
+
+	stmts := fl.Body.Statements
+	if len(stmts) == 0 {
+		return out + "null"
+	}
+	if len(stmts) == 1 {
+		if es, ok := stmts[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+			if ie, ok := es.Expression.(*ast.IfExpression); !ok || ternary(ie) {
+				return out + g.expression(es.Expression, precArrow)
+			}
+		}
+	}
+	return out + g.block(stmts, ret)
+}
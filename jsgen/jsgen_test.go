@@ -0,0 +1,168 @@
+package jsgen
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGolden compares the JavaScript for each testdata/*.mk file with the
+// .js file beside it. Run with -update to rewrite them.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Generate(parser.MustParse(string(src)))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		golden := strings.TrimSuffix(file, ".mk") + ".js"
+		if *update {
+			if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: got\n%s\nwant:\n%s", file, got, want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2 * 3", "1 + 2 * 3;\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"a == b != c", "a === b !== c;\n"},
+		{"a / 2 / 3", "Math.trunc(Math.trunc(a / 2) / 3);\n"},
+		{"a / (b + c)", "const $div = (a, b) => {\n  if (b === 0) {\n    throw new Error(\"division by zero\");\n  }\n  return Math.trunc(a / b);\n};\n\n$div(a, b + c);\n"},
+		{"--a", "-(-a);\n"},
+		{"!(a < b)", "!(a < b);\n"},
+		{"!a", "const $truthy = (v) => v !== false && v != null;\n\n!$truthy(a);\n"},
+		{"fn() {}", "() => null;\n"},
+		{"fn(x) { x }(5)", "((x) => x)(5);\n"},
+		{"f(fn(x) { x }, if (a < b) { 1 })", "f((x) => x, a < b ? 1 : null);\n"},
+		{"if (a < b) { c }", "if (a < b) {\n  c;\n}\n"},
+		{"let f = fn(x, x) { x };", "let f = (x, x$1) => x$1;\n"},
+		{"let var = 1; var", "let var$ = 1;\nvar$;\n"},
+		{"let x = 1; let x = 2;", "let x = 1;\nx = 2;\n"},
+		{"let f = fn(x) { let x = 2; x }", "let f = (x) => {\n  x = 2;\n  return x;\n};\n"},
+		{"let f = fn() { let y = 1; }", "let f = () => {\n  let y = 1;\n  return null;\n};\n"},
+	}
+	for i, tc := range tests {
+		got, err := Generate(parser.MustParse(tc.input))
+		if err != nil {
+			t.Errorf("%d. Generate(%q): %v", i, tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%d. Generate(%q) =\n%s\nwant:\n%s", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "let f = fn(x) { let y = if (x) { return 1; 2 } else { 3 }; y }",
+			want:  "1:34: can't translate return inside an if expression whose value is used",
+		},
+	}
+	for i, tc := range tests {
+		_, err := Generate(parser.MustParse(tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. Generate(%q) error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// TestRunDivision checks dividing by zero fails under node, if it's
+// installed.
+func TestRunDivision(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	tests := []struct {
+		input string
+		want  string // what it prints, or the error
+	}{
+		{"let x = 3; let result = x / 2;", "1"},
+		{"let x = -7; let result = x / 2;", "-3"},
+		{"let x = 3; x / 0; let result = 7;", "division by zero"},
+		{"let result = 1 / 0;", "division by zero"},
+	}
+	for i, tc := range tests {
+		js, err := Generate(parser.MustParse(tc.input))
+		if err != nil {
+			t.Errorf("%d. Generate(%q): %v", i, tc.input, err)
+			continue
+		}
+		out, err := exec.Command(node, "-e", js+"console.log(String(result));\n").CombinedOutput()
+		if got := strings.TrimSpace(string(out)); err == nil && got != tc.want || err != nil && !strings.Contains(got, "Error: "+tc.want) {
+			t.Errorf("%d. %q: node printed %s (%v), want %s", i, tc.input, got, err, tc.want)
+		}
+	}
+}
+
+// TestRun runs the golden files with node, if it's installed, and checks the
+// value they leave in result.
+func TestRun(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"fact.mk", "3628800"},
+		{"closures.mk", "42"},
+		{"if.mk", "410"},
+		{"truthy.mk", "2"},
+		{"names.mk", "25"},
+	}
+	for _, tc := range tests {
+		js, err := os.ReadFile(filepath.Join("testdata", strings.TrimSuffix(tc.file, ".mk")+".js"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(node, "-e", string(js)+"console.log(String(result));\n")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("%s: node: %v\n%s", tc.file, err, out)
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.want {
+			t.Errorf("%s: result = %s, want %s", tc.file, got, tc.want)
+		}
+	}
+}
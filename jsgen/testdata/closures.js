@@ -0,0 +1,4 @@
+let adder = (x) => (y) => x + y;
+let compose = (f, g) => (x) => f(g(x));
+let addTwo = compose(adder(1), adder(1));
+let result = addTwo(40);
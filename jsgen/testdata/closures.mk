@@ -0,0 +1,4 @@
+let adder = fn(x) { fn(y) { x + y } };
+let compose = fn(f, g) { fn(x) { f(g(x)) } };
+let addTwo = compose(adder(1), adder(1));
+let result = addTwo(40);
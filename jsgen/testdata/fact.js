@@ -0,0 +1,2 @@
+let fact = (n) => n < 2 ? 1 : n * fact(n - 1);
+let result = fact(10);
@@ -0,0 +1,4 @@
+let fact = fn(n) {
+  if (n < 2) { 1 } else { n * fact(n - 1) }
+};
+let result = fact(10);
@@ -0,0 +1,32 @@
+const $truthy = (v) => v !== false && v != null;
+
+let max = (a, b) => a > b ? a : b;
+let sign = (n) => {
+  if (n < 0) {
+    return -1;
+  }
+  return n === 0 ? 0 : 1;
+};
+let clamp = (n) => {
+  let low = 0;
+  let high = 10;
+  if (n < low) {
+    return low;
+  } else {
+    if (n > high) {
+      let over = n - high;
+      return high;
+    } else {
+      return n;
+    }
+  }
+};
+let nothing = (c) => $truthy(c) ? 1 : null;
+let result = max(sign(-5), clamp(42)) + 100 * (() => {
+  if ($truthy(1)) {
+    let a = 2;
+    return a * a;
+  } else {
+    return 0;
+  }
+})();
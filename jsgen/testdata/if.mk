@@ -0,0 +1,18 @@
+let max = fn(a, b) { if (a > b) { a } else { b } };
+let sign = fn(n) {
+  if (n < 0) {
+    return -1;
+  }
+  if (n == 0) { 0 } else { 1 }
+};
+let clamp = fn(n) {
+  let low = 0;
+  let high = 10;
+  if (n < low) {
+    low
+  } else {
+    if (n > high) { let over = n - high; high } else { n }
+  }
+};
+let nothing = fn(c) { if (c) { 1 } };
+let result = max(sign(-5), clamp(42)) + 100 * (if (1) { let a = 2; a * a } else { 0 });
@@ -0,0 +1,13 @@
+let new$ = 7;
+let x = 1;
+x = x + 1;
+let shadow = (x) => {
+  x = x * 10;
+  let y = () => {
+    let x$1 = x + 1;
+    return x$1;
+  };
+  return y();
+};
+let Math$ = 3;
+let result = shadow(x) + Math.trunc(new$ / 2) + Math$ - -(-x);
@@ -0,0 +1,10 @@
+let new = 7;
+let x = 1;
+let x = x + 1;
+let shadow = fn(x) {
+  let x = x * 10;
+  let y = fn() { let x = x + 1; x };
+  y()
+};
+let Math = 3;
+let result = shadow(x) + new / 2 + Math - --x;
@@ -0,0 +1,7 @@
+(() => {
+  let result = 5;
+  if (result > 2) {
+    return result;
+  }
+  result = 0;
+})();
@@ -0,0 +1,5 @@
+let result = 5;
+if (result > 2) {
+  return result;
+}
+let result = 0;
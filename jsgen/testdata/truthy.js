@@ -0,0 +1,8 @@
+const $truthy = (v) => v !== false && v != null;
+
+let nothing = (c) => $truthy(c) ? 1 : null;
+let zero = 0;
+let t = $truthy(zero) ? true : false;
+let f = !$truthy(zero);
+let n = !!$truthy(nothing(false));
+let result = t === true ? ($truthy(f) ? 1 : 2) : 3;
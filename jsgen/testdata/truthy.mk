@@ -0,0 +1,6 @@
+let nothing = fn(c) { if (c) { 1 } };
+let zero = 0;
+let t = if (zero) { true } else { false };
+let f = !zero;
+let n = !!nothing(false);
+let result = if (t == true) { if (f) { 1 } else { 2 } } else { 3 };
@@ -13,16 +13,22 @@ type Lexer struct {
 	input        string
 	pos, readPos int  // current & next position in input
 	ch           byte // current char being examined
+	line, col    int  // line & column of ch
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 // readChar consumes the next character, placing it in the ch field..
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.col = 0
+	}
+	l.col++
 	if l.readPos >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -45,6 +51,7 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := token.Pos{Line: l.line, Col: l.col}
 
 	switch l.ch {
 	case '=':
@@ -57,6 +64,8 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = token.Token{Type: token.SEMICOLON, Literal: string(l.ch)}
+	case ':':
+		tok = token.Token{Type: token.COLON, Literal: string(l.ch)}
 	case '(':
 		tok = token.Token{Type: token.LPAREN, Literal: string(l.ch)}
 	case ')':
@@ -70,7 +79,13 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = token.Token{Type: token.PLUS, Literal: string(l.ch)}
 	case '-':
-		tok = token.Token{Type: token.MINUS, Literal: string(l.ch)}
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = token.Token{Type: token.MINUS, Literal: string(l.ch)}
+		}
 	case '*':
 		tok = token.Token{Type: token.ASTERISK, Literal: string(l.ch)}
 	case '/':
@@ -94,16 +109,19 @@ func (l *Lexer) NextToken() token.Token {
 			ident := l.readIdentifier()
 			tok.Literal = ident
 			tok.Type = token.Lookup(ident)
+			tok.Pos = pos
 			return tok // we have already called readChar()
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok // we have already called readChar()
 		}
 		tok = token.Token{Type: token.ILLEGAL, Literal: string(l.ch)}
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
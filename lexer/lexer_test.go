@@ -24,6 +24,7 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+let inc: fn(int) -> int = fn(n: int) -> int { n - -1 };
 `
 
 	tests := []struct {
@@ -113,6 +114,32 @@ if (5 < 10) {
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
 
+		{token.LET, "let"},
+		{token.IDENT, "inc"},
+		{token.COLON, ":"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.ASSIGN, "="},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "n"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "n"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+
 		{token.EOF, ""},
 	}
 
@@ -127,3 +154,34 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestNextTokenPos(t *testing.T) {
+	input := "let x = 5;\n  x == 10;\n\n\tfn\r\n"
+	tests := []struct {
+		wantLit string
+		want    token.Pos
+	}{
+		{"let", token.Pos{Line: 1, Col: 1}},
+		{"x", token.Pos{Line: 1, Col: 5}},
+		{"=", token.Pos{Line: 1, Col: 7}},
+		{"5", token.Pos{Line: 1, Col: 9}},
+		{";", token.Pos{Line: 1, Col: 10}},
+		{"x", token.Pos{Line: 2, Col: 3}},
+		{"==", token.Pos{Line: 2, Col: 5}},
+		{"10", token.Pos{Line: 2, Col: 8}},
+		{";", token.Pos{Line: 2, Col: 10}},
+		{"fn", token.Pos{Line: 4, Col: 2}},
+		{"", token.Pos{Line: 5, Col: 1}},
+	}
+
+	lex := New(input)
+	for i, tc := range tests {
+		tok := lex.NextToken()
+		if tok.Literal != tc.wantLit {
+			t.Fatalf("%d. token literal = %q, want %q", i, tok.Literal, tc.wantLit)
+		}
+		if tok.Pos != tc.want {
+			t.Errorf("%d. %q: token pos = %v, want %v", i, tok.Literal, tok.Pos, tc.want)
+		}
+	}
+}
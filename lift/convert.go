@@ -0,0 +1,211 @@
+package lift
+
+import (
+	"sort"
+
+	"monkey/ast"
+	"monkey/names"
+	"monkey/token"
+)
+
+// program returns the converted program: the pair functions, the call
+// helpers, the lifted functions in source order, and the program's own
+// statements.
+func (c *converter) program(prog *ast.Program) *ast.Program {
+	out := &ast.Program{}
+	if len(c.order) > 0 || len(c.arity) > 0 {
+		a, b, get := c.names.Prefix+"a", c.names.Prefix+"b", c.names.Prefix+"get"
+		out.Statements = append(out.Statements,
+			let(c.names.Prefix+"pair", fnLit([]string{a, b},
+				expr(fnLit([]string{get}, expr(call(ident(get), ident(a), ident(b))))))),
+			let(c.names.Prefix+"first", fnLit([]string{a, b}, expr(ident(a)))),
+			let(c.names.Prefix+"second", fnLit([]string{a, b}, expr(ident(b)))),
+		)
+	}
+	var arities []int
+	for n := range c.arity {
+		arities = append(arities, n)
+	}
+	sort.Ints(arities)
+	for _, n := range arities {
+		self := c.names.Prefix + "self"
+		params := []string{self}
+		args := []ast.Expression{ident(self)}
+		for i := 1; i <= n; i++ {
+			params = append(params, c.names.Prefix+names.Letters(i))
+			args = append(args, ident(c.names.Prefix+names.Letters(i)))
+		}
+		code := call(ident(self), ident(c.names.Prefix+"first"))
+		out.Statements = append(out.Statements, let(c.callName(n), fnLit(params, expr(call(code, args...)))))
+	}
+	for _, f := range c.order {
+		out.Statements = append(out.Statements, c.lift(f))
+	}
+	out.Statements = append(out.Statements, c.statements(prog.Statements)...)
+	return out
+}
+
+// lift returns the top-level definition of f's code. It starts by declaring
+// the variables f captures, from its closure.
+func (c *converter) lift(f *function) ast.Statement {
+	self := c.names.Prefix + "self"
+	params := []string{self}
+	for _, p := range f.lit.Parameters {
+		params = append(params, c.name(p))
+	}
+	var body []ast.Statement
+	for _, v := range f.free {
+		var value ast.Expression
+		switch {
+		case v == f.self:
+			value = ident(self)
+		case f.member(v):
+			// Another function of the group, made from the same environment.
+			sibling := c.fns[v.let.Value.(*ast.FunctionLiteral)]
+			value = call(ident(c.names.Prefix+"pair"), ident(sibling.code), c.env(self))
+		default:
+			value = c.env(self)
+			for _, w := range f.env() {
+				if w == v {
+					break
+				}
+				value = call(value, ident(c.names.Prefix+"second"))
+			}
+			value = call(value, ident(c.names.Prefix+"first"))
+		}
+		body = append(body, let(v.name, value))
+	}
+	if f.lit.Body != nil {
+		body = append(body, c.statements(f.lit.Body.Statements)...)
+	}
+	fl := fnLit(params, body...)
+	fl.Token = f.lit.Token
+	for i, p := range f.lit.Parameters {
+		fl.Parameters[i+1].Token = p.Token
+	}
+	return let(f.code, fl)
+}
+
+// env returns the environment of the closure self.
+func (c *converter) env(self string) ast.Expression {
+	return call(ident(self), ident(c.names.Prefix+"second"))
+}
+
+// closure returns the expression which makes a closure of f.
+func (c *converter) closure(f *function) ast.Expression {
+	var env ast.Expression = &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}}
+	vs := f.env()
+	for i := len(vs) - 1; i >= 0; i-- {
+		env = call(ident(c.names.Prefix+"pair"), ident(vs[i].name), env)
+	}
+	return call(ident(c.names.Prefix+"pair"), ident(f.code), env)
+}
+
+func (c *converter) statements(stmts []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for _, s := range stmts {
+		out = append(out, c.statement(s))
+	}
+	return out
+}
+
+func (c *converter) statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		return &ast.LetStatement{Token: s.Token, Name: c.ident(s.Name), Value: c.expression(s.Value)}
+	case *ast.ReturnStatement:
+		return &ast.ReturnStatement{Token: s.Token, ReturnValue: c.expression(s.ReturnValue)}
+	case *ast.ExpressionStatement:
+		return &ast.ExpressionStatement{Token: s.Token, Expression: c.expression(s.Expression)}
+	case *ast.BlockStatement:
+		return c.block(s)
+	}
+	return s
+}
+
+func (c *converter) block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return &ast.BlockStatement{Token: b.Token, Statements: c.statements(b.Statements)}
+}
+
+func (c *converter) expression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return c.ident(e)
+	case *ast.PrefixExpression:
+		return &ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: c.expression(e.Right)}
+	case *ast.InfixExpression:
+		return &ast.InfixExpression{Token: e.Token, Left: c.expression(e.Left), Operator: e.Operator, Right: c.expression(e.Right)}
+	case *ast.IfExpression:
+		return &ast.IfExpression{
+			Token:       e.Token,
+			Condition:   c.expression(e.Condition),
+			Consequence: c.block(e.Consequence),
+			Alternative: c.block(e.Alternative),
+		}
+	case *ast.FunctionLiteral:
+		return c.closure(c.fns[e])
+	case *ast.CallExpression:
+		args := []ast.Expression{c.expression(e.Function)}
+		for _, a := range e.Arguments {
+			args = append(args, c.expression(a))
+		}
+		ce := call(ident(c.callName(len(e.Arguments))), args...)
+		ce.Token = e.Token
+		return ce
+	}
+	return e
+}
+
+// name returns the name of the variable id declares or refers to.
+func (c *converter) name(id *ast.Identifier) string {
+	d := c.info.Defs[id]
+	if d == nil {
+		d = c.info.Uses[id]
+	}
+	return c.vars[d].name
+}
+
+// ident returns a copy of id with the name of its variable.
+func (c *converter) ident(id *ast.Identifier) *ast.Identifier {
+	name := c.name(id)
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: id.Pos()}, Value: name}
+}
+
+func ident(name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func let(name string, value ast.Expression) *ast.LetStatement {
+	return &ast.LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident(name), Value: value}
+}
+
+func expr(e ast.Expression) *ast.ExpressionStatement {
+	tok := token.Token{Type: token.IDENT, Literal: e.TokenLiteral()}
+	switch e := e.(type) {
+	case *ast.CallExpression:
+		// It starts with the function.
+		return &ast.ExpressionStatement{Token: expr(e.Function).Token, Expression: e}
+	case *ast.FunctionLiteral:
+		tok = e.Token
+	}
+	return &ast.ExpressionStatement{Token: tok, Expression: e}
+}
+
+func call(fn ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	return &ast.CallExpression{Token: token.Token{Type: token.LPAREN, Literal: "("}, Function: fn, Arguments: args}
+}
+
+func fnLit(params []string, body ...ast.Statement) *ast.FunctionLiteral {
+	var ids []*ast.Identifier
+	for _, p := range params {
+		ids = append(ids, ident(p))
+	}
+	return &ast.FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+		Parameters: ids,
+		Body:       &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: body},
+	}
+}
@@ -0,0 +1,392 @@
+// Package lift rewrites monkey programs so that no function refers to the
+// variables of the functions enclosing it, and every function is defined at
+// the top level of the program: closure conversion and lambda lifting.
+//
+// Monkey has no data structures other than functions, so the converted
+// program builds them from functions. A record is a chain of pairs, ending
+// with false, and a pair is a function which passes its two values to the
+// function it's called with:
+//
+//	let lift_pair = fn(lift_a, lift_b) { fn(lift_get) { lift_get(lift_a, lift_b) } };
+//	let lift_first = fn(lift_a, lift_b) { lift_a };
+//	let lift_second = fn(lift_a, lift_b) { lift_b };
+//
+// This is the one function literal which isn't at the top level. Each
+// function literal of the program becomes a top-level function which takes
+// the closure it's called through as an extra first parameter. A closure is
+// a pair of that function and an environment record holding the values of
+// the variables it captures, and each call goes through a helper which calls
+// the closure's function:
+//
+//	let adder = fn(x) { fn(y) { x + y } };
+//	adder(2)(40)
+//
+// becomes, after the pair functions,
+//
+//	let lift_call_a = fn(lift_self, lift_a) { lift_self(lift_first)(lift_self, lift_a) };
+//	let lift_adder = fn(lift_self, x) { lift_pair(lift_fn, lift_pair(x, false)) };
+//	let lift_fn = fn(lift_self, y) { let x = lift_self(lift_second)(lift_first); (x + y) };
+//	let adder = lift_pair(lift_adder, false);
+//	lift_call_a(lift_call_a(adder, 2), 40)
+//
+// Variables declared at the top level of the program are used by name, as
+// before, so they aren't captured. Other variables are captured by value,
+// when the closure is made, so a function may only capture a variable which
+// has been declared by then and isn't declared again afterwards. The
+// exception is a group of functions declared by let statements in the same
+// block, which may use each other whatever their order: they share an
+// environment, and the functions of the group are made anew from it when
+// they're used.
+//
+// Variables declared in functions and blocks with the same name as a global
+// are renamed, as the converted functions are at the top level.
+//
+// The generated names all start with a prefix which no name in the program
+// starts with: "lift_", or that with more underscores.
+package lift
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/names"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// Convert returns a copy of prog in which every function is at the top level.
+// Type annotations are dropped, as the converted functions don't have the
+// same types. A call with the wrong number of arguments still fails, but the
+// error counts the closure as an argument.
+func Convert(prog *ast.Program) (*ast.Program, error) {
+	c := &converter{
+		info:  resolve.Resolve(prog),
+		vars:  make(map[*resolve.Decl]*variable),
+		fns:   make(map[*ast.FunctionLiteral]*function),
+		arity: make(map[int]bool),
+		names: names.New(prog, "lift_"),
+	}
+	if err := c.info.Err(); err != nil {
+		return nil, err
+	}
+	c.groupScope(c.info.Scope)
+	c.findFree(prog, []ast.Node{prog})
+	c.checkCaptures(prog, prog, make(map[*variable]int), false)
+	c.checkCaptures(prog, prog, make(map[*variable]int), true)
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.checkNames()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.program(prog), nil
+}
+
+// variable is a monkey variable: the declarations of a name in a single
+// scope (see resolve.Scope.Variables).
+type variable struct {
+	name  string // in the converted program
+	scope *resolve.Scope
+	fn    ast.Node // the function literal or program it's declared in
+	decls int
+	// global is set for variables declared at the top level of the program.
+	global bool
+	// let is the let statement which declares the variable, if it's
+	// declared once, by a function literal.
+	let *ast.LetStatement
+}
+
+// function is what's known about a function literal.
+type function struct {
+	lit    *ast.FunctionLiteral
+	index  int      // in source order
+	parent ast.Node // the function literal or program it's in
+	// free are the variables of enclosing functions, other than globals,
+	// the function uses, including those used by the functions nested in it.
+	free []*variable
+	// self is the variable the function is bound to, if it's declared once,
+	// by the function literal.
+	self  *variable
+	name  string // the name of the variable it's bound to, if any
+	group *group
+	code  string // the name of the top-level function
+}
+
+// group is a group of functions which use each other before they're
+// declared.
+type group struct {
+	members []*function
+	// env are the variables the members capture, other than the members.
+	env []*variable
+}
+
+type converter struct {
+	info *resolve.Info
+	vars map[*resolve.Decl]*variable
+	fns  map[*ast.FunctionLiteral]*function
+	// order is the function literals in source order.
+	order []*function
+	err   error
+
+	names *names.Namer
+	arity map[int]bool // numbers of arguments of calls
+}
+
+func (c *converter) errorf(pos token.Pos, format string, args ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf("%v: %s", pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// groupScope makes the variables for the declarations in s and its children.
+func (c *converter) groupScope(s *resolve.Scope) {
+	for _, decls := range s.Variables() {
+		v := &variable{name: decls[0].Name.Value, scope: s, fn: s.Function().Node, global: s.Parent == nil, decls: len(decls)}
+		if d := decls[0]; len(decls) == 1 && d.Let != nil {
+			if _, ok := d.Let.Value.(*ast.FunctionLiteral); ok {
+				v.let = d.Let
+			}
+		}
+		for _, d := range decls {
+			c.vars[d] = v
+		}
+	}
+	for _, ch := range s.Children {
+		c.groupScope(ch)
+	}
+}
+
+// findFree finds the function literals in n and their free variables. stack
+// is the functions enclosing n, outermost first.
+func (c *converter) findFree(n ast.Node, stack []ast.Node) {
+	switch n := n.(type) {
+	case *ast.FunctionLiteral:
+		f := &function{lit: n, index: len(c.order), parent: stack[len(stack)-1]}
+		c.fns[n] = f
+		c.order = append(c.order, f)
+		stack = append(stack, n)
+	case *ast.CallExpression:
+		c.arity[len(n.Arguments)] = true
+	case *ast.Identifier:
+		v := c.vars[c.info.Uses[n]]
+		if v == nil || v.global {
+			return
+		}
+		// The variable is free in every function between its own and the use.
+		for i := len(stack) - 1; i >= 0 && stack[i] != v.fn; i-- {
+			f := c.fns[stack[i].(*ast.FunctionLiteral)]
+			if !contains(f.free, v) {
+				f.free = append(f.free, v)
+			}
+		}
+		return
+	}
+	for _, ch := range ast.Children(n) {
+		c.findFree(ch, stack)
+	}
+}
+
+func contains(vs []*variable, v *variable) bool {
+	for _, w := range vs {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCaptures walks n, in fn, in evaluation order; declared counts the
+// declarations of each variable which have been evaluated. The first walk
+// puts functions which use each other before they're declared in groups. The
+// second, once the groups are known, checks every captured variable has its
+// final value when the closures capturing it are made.
+func (c *converter) checkCaptures(n ast.Node, fn ast.Node, declared map[*variable]int, final bool) {
+	switch n := n.(type) {
+	case *ast.LetStatement:
+		if fl, ok := n.Value.(*ast.FunctionLiteral); ok {
+			c.fns[fl].name = n.Name.Value
+			if v := c.vars[c.info.Defs[n.Name]]; v != nil && v.let == n {
+				c.fns[fl].self = v
+			}
+		}
+		if n.Value != nil {
+			c.checkCaptures(n.Value, fn, declared, final)
+		}
+		if v := c.vars[c.info.Defs[n.Name]]; v != nil {
+			declared[v]++
+		}
+		return
+	case *ast.FunctionLiteral:
+		f := c.fns[n]
+		if final {
+			c.checkMade(f, fn, declared)
+		} else {
+			c.findGroup(f, fn, declared)
+		}
+		for _, p := range n.Parameters {
+			if v := c.vars[c.info.Defs[p]]; v != nil {
+				declared[v]++
+			}
+		}
+		if n.Body != nil {
+			c.checkCaptures(n.Body, n, declared, final)
+		}
+		return
+	}
+	for _, ch := range ast.Children(n) {
+		c.checkCaptures(ch, fn, declared, final)
+	}
+}
+
+// findGroup joins f, which is being made in fn, to the group of each
+// function it uses before it's declared.
+func (c *converter) findGroup(f *function, fn ast.Node, declared map[*variable]int) {
+	for _, v := range f.free {
+		if v.fn != fn || declared[v] > 0 || v.let == nil || f.self == nil || v.scope != f.self.scope {
+			continue
+		}
+		if f.group == nil {
+			f.group = &group{members: []*function{f}}
+		}
+		g := c.fns[v.let.Value.(*ast.FunctionLiteral)]
+		switch {
+		case g.group == nil:
+			g.group = f.group
+			f.group.members = append(f.group.members, g)
+		case g.group != f.group:
+			old := g.group
+			for _, m := range old.members {
+				m.group = f.group
+			}
+			f.group.members = append(f.group.members, old.members...)
+		}
+	}
+}
+
+// env returns the variables f's closures hold.
+func (f *function) env() []*variable {
+	if f.group != nil {
+		return f.group.env
+	}
+	return f.free
+}
+
+// member reports whether v is bound to a function of f's group.
+func (f *function) member(v *variable) bool {
+	if f.group == nil {
+		return false
+	}
+	for _, m := range f.group.members {
+		if m.self == v {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMade checks that the variables f captures when it's made in fn have
+// their final values.
+func (c *converter) checkMade(f *function, fn ast.Node, declared map[*variable]int) {
+	if g := f.group; g != nil && g.env == nil {
+		sort.Slice(g.members, func(i, j int) bool { return g.members[i].index < g.members[j].index })
+		g.env = []*variable{}
+		for _, m := range g.members {
+			for _, v := range m.free {
+				if !f.member(v) && !contains(g.env, v) {
+					g.env = append(g.env, v)
+				}
+			}
+		}
+	}
+	for _, v := range f.env() {
+		if v.fn != fn {
+			// It's captured by fn too, and was checked then.
+			continue
+		}
+		switch {
+		case declared[v] == 0:
+			c.errorf(f.lit.Pos(), "can't convert %s: it captures %s before its declaration", c.describe(f), v.name)
+		case declared[v] < v.decls:
+			c.errorf(f.lit.Pos(), "can't convert %s: it captures %s, which is declared again later", c.describe(f), v.name)
+		}
+	}
+}
+
+func (c *converter) describe(f *function) string {
+	if f.name != "" {
+		return f.name
+	}
+	return "function"
+}
+
+// checkNames checks the variables each function captures can be declared by
+// their names in the converted function, and chooses the generated names,
+// renaming the variables which share a global's name.
+func (c *converter) checkNames() {
+	for _, f := range c.order {
+		names := make(map[string]bool)
+		for _, v := range f.free {
+			if names[v.name] {
+				c.errorf(f.lit.Pos(), "can't convert %s: it captures two variables named %s", c.describe(f), v.name)
+			}
+			names[v.name] = true
+		}
+	}
+
+	for _, name := range []string{"pair", "first", "second", "self", "get", "a", "b"} {
+		c.names.Use(name)
+	}
+	for n := range c.arity {
+		c.names.Use(callSuffix(n))
+		for i := 1; i <= n; i++ {
+			c.names.Use(names.Letters(i))
+		}
+	}
+	for _, f := range c.order {
+		base := "fn"
+		if f.name != "" {
+			base = f.name
+		}
+		f.code = c.names.Fresh(base)
+	}
+
+	// The converted functions are at the top level, where a global's name
+	// means the global, so the other variables with the same name are
+	// renamed.
+	globals := make(map[string]bool)
+	for _, decls := range c.info.Scope.Variables() {
+		globals[decls[0].Name.Value] = true
+	}
+	var rename func(s *resolve.Scope)
+	rename = func(s *resolve.Scope) {
+		for _, decls := range s.Variables() {
+			if v := c.vars[decls[0]]; !v.global && globals[v.name] {
+				v.name = c.names.Fresh(v.name)
+			}
+		}
+		for _, ch := range s.Children {
+			rename(ch)
+		}
+	}
+	rename(c.info.Scope)
+}
+
+// callName returns the name of the helper which makes calls with n
+// arguments. It's named after its parameters.
+func (c *converter) callName(n int) string {
+	return c.names.Prefix + callSuffix(n)
+}
+
+// callSuffix is callName without the prefix.
+func callSuffix(n int) string {
+	s := "call"
+	if n > 0 {
+		s += "_"
+	}
+	for i := 1; i <= n; i++ {
+		s += names.Letters(i)
+	}
+	return s
+}
@@ -0,0 +1,250 @@
+package lift
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/cgen"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+// convert converts input, and parses the converted program's source.
+func convert(t *testing.T, input string) (*ast.Program, bool) {
+	t.Helper()
+	prog, err := Convert(parser.MustParse(input))
+	if err != nil {
+		t.Errorf("Convert(%q): %v", input, err)
+		return nil, false
+	}
+	src := prog.String()
+	p := parser.New(lexer.New(src))
+	reparsed := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Errorf("Convert(%q) = %s, which doesn't parse: %v", input, src, errs)
+		return nil, false
+	}
+	return reparsed, true
+}
+
+// programs are monkey programs and what they evaluate to.
+var programs = []struct {
+	input string
+	want  string
+}{
+	{"1 + 2 * 3", "7"},
+	{"let f = fn(x) { x }; f == f", "true"},
+	{"fn() {}()", "null"},
+	{"let f = fn() { 1 }; f", "fn"},
+	{
+		input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(20)",
+		want:  "2432902008176640000",
+	},
+	{
+		input: "let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(40)",
+		want:  "42",
+	},
+	{
+		input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7)",
+		want:  "true",
+	},
+	{
+		input: "let x = 1; let f = fn() { x }; let x = 2; f()",
+		want:  "2",
+	},
+	{
+		input: "let x = 1; let x = x + 1; let f = fn(x) { let x = x * 10; let g = fn() { let x = x + 1; x }; g() }; f(x)",
+		want:  "21",
+	},
+	{
+		input: "let compose = fn(f, g) { fn(x) { f(g(x)) } }; let inc = fn(x) { x + 1 }; let dbl = fn(x) { x * 2 }; compose(inc, dbl)(5)",
+		want:  "11",
+	},
+	{
+		input: "let counter = fn(n) { let next = fn() { counter(n + 1) }; if (n == 3) { n } else { next() } }; counter(0)",
+		want:  "3",
+	},
+	{
+		input: "let f = fn(n) { let loop = fn(i, acc) { if (i > n) { acc } else { loop(i + 1, acc + i) } }; loop(1, 0) }; f(10)",
+		want:  "55",
+	},
+	{
+		input: "let f = fn(k) { let even = fn(n) { if (n == 0) { k } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { -k } else { even(n - 1) } }; even(5) }; f(3)",
+		want:  "-3",
+	},
+	{
+		input: "let f = fn(a) { fn(b) { fn(c) { a * 100 + b * 10 + c } } }; f(1)(2)(3)",
+		want:  "123",
+	},
+	{
+		input: "if (true) { let k = 4; let sq = fn() { k * k }; sq() }",
+		want:  "16",
+	},
+	{
+		input: "let sign = fn(n) { if (n < 0) { return -1; }; if (n == 0) { 0 } else { 1 } }; sign(-5) + sign(0) * 10 + sign(7) * 100",
+		want:  "99",
+	},
+	{
+		input: "let f = fn(x) { let g = fn() { x }; g == g }; f(1)",
+		want:  "true",
+	},
+	// The functions' own x, declared at the top level, mustn't mean the
+	// global x.
+	{
+		input: "let x = 1; let f = fn() { let x = x + 1; x }; f()",
+		want:  "2",
+	},
+	{
+		input: "let x = 1; let f = fn() { let x = 10; let g = fn() { x + 1 }; g() }; f() + x",
+		want:  "12",
+	},
+}
+
+func TestConvert(t *testing.T) {
+	prog, err := Convert(parser.MustParse("let adder = fn(x) { fn(y) { x + y } }; adder(2)(40)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "let lift_pair = fn(lift_a, lift_b) { fn(lift_get) { lift_get(lift_a, lift_b); }; };" +
+		"let lift_first = fn(lift_a, lift_b) { lift_a; };" +
+		"let lift_second = fn(lift_a, lift_b) { lift_b; };" +
+		"let lift_call_a = fn(lift_self, lift_a) { lift_self(lift_first)(lift_self, lift_a); };" +
+		"let lift_adder = fn(lift_self, x) { lift_pair(lift_fn, lift_pair(x, false)); };" +
+		"let lift_fn = fn(lift_self, y) { let x = lift_self(lift_second)(lift_first); (x + y); };" +
+		"let adder = lift_pair(lift_adder, false);" +
+		"lift_call_a(lift_call_a(adder, 2), 40);"
+	if got := strings.ReplaceAll(prog.String(), "\n", " "); got != want {
+		t.Errorf("Convert() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConvertNames(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"let lift_x = 1; fn() { lift_x }()", []string{"let lift__pair =", "let lift__fn = fn(lift__self) {"}},
+		{"let f = fn() { 1 }; let f = fn() { 2 }; f()", []string{"let lift_f =", "let lift_f_b ="}},
+		{"let pair = fn(a) { a }; pair(1)", []string{"let lift_pair_b = fn(lift_self, a)", "let pair = lift_pair(lift_pair_b, false);"}},
+		{"fn() { fn() { 1 } }", []string{"let lift_fn =", "let lift_fn_b ="}},
+	}
+	for i, tc := range tests {
+		prog, err := Convert(parser.MustParse(tc.input))
+		if err != nil {
+			t.Errorf("%d. Convert(%q): %v", i, tc.input, err)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(prog.String(), want) {
+				t.Errorf("%d. Convert(%q) =\n%s\nwant it to contain %q", i, tc.input, prog, want)
+			}
+		}
+	}
+}
+
+// TestLifted checks the only function literal which isn't at the top level
+// is lift_pair's.
+func TestLifted(t *testing.T) {
+	for i, tc := range programs {
+		prog, ok := convert(t, tc.input)
+		if !ok {
+			continue
+		}
+		for _, s := range prog.Statements {
+			var top ast.Node
+			if ls, ok := s.(*ast.LetStatement); ok {
+				if ls.Name.Value == "lift_pair" {
+					continue
+				}
+				top = ls.Value
+			}
+			nested := false
+			ast.Inspect(s, func(n ast.Node) bool {
+				if _, ok := n.(*ast.FunctionLiteral); ok && n != top {
+					nested = true
+				}
+				return true
+			})
+			if nested {
+				t.Errorf("%d. Convert(%q) has a nested function in %s", i, tc.input, s)
+			}
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x + 1", "1:1: undefined: x"},
+		{"let a = b; let b = 1;", "1:9: b used before its declaration at 1:16"},
+		{
+			input: "let f = fn() { let g = fn() { a }; let a = 2; g() }",
+			want:  "1:24: can't convert g: it captures a before its declaration",
+		},
+		{
+			input: "let f = fn() { let a = 1; let g = fn() { a }; let a = 2; g() }",
+			want:  "1:35: can't convert g: it captures a, which is declared again later",
+		},
+		{
+			input: "let f = fn() { let g = fn() { h() }; let k = 1; let h = fn() { k }; g() }",
+			want:  "1:24: can't convert g: it captures k before its declaration",
+		},
+		{
+			input: "let f = fn() { let g = if (true) { fn() { g } }; g }",
+			want:  "1:36: can't convert function: it captures g before its declaration",
+		},
+	}
+	for i, tc := range tests {
+		_, err := Convert(parser.MustParse(tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%d. Convert(%q) error = %v, want %q", i, tc.input, err, tc.want)
+		}
+	}
+}
+
+// TestRun compiles the converted programs to C, and checks they evaluate to
+// what the programs did, if there is a C compiler.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc not found")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prog.c")
+	exe := filepath.Join(dir, "prog")
+	for i, tc := range programs {
+		prog, ok := convert(t, tc.input)
+		if !ok {
+			continue
+		}
+		src, err := cgen.Generate(prog)
+		if err != nil {
+			t.Errorf("%d. %q: Generate(%s): %v", i, tc.input, prog, err)
+			continue
+		}
+		if err := os.WriteFile(file, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command(cc, "-o", exe, file).CombinedOutput(); err != nil {
+			t.Errorf("%d. %q: %s: %v\n%s", i, tc.input, cc, err, out)
+			continue
+		}
+		out, err := exec.Command(exe).CombinedOutput()
+		if err != nil {
+			t.Errorf("%d. %q: %v\n%s\n%s", i, tc.input, err, out, prog)
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.want {
+			t.Errorf("%d. %q converted to %s, which printed %s, want %s", i, tc.input, prog, got, tc.want)
+		}
+	}
+}
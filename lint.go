@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/lint"
+)
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	enable := fs.String("enable", "", "comma separated `rules` to run (default all)")
+	disable := fs.String("disable", "", "comma separated `rules` not to run")
+	list := fs.Bool("list", false, "list the available rules")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey lint [-enable rules] [-disable rules] [-list] [file ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *list {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-14s %s\n", r.Name, r.Doc)
+		}
+		return nil
+	}
+
+	rules, err := lint.Select(lint.ParseList(*enable), lint.ParseList(*disable))
+	if err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	problems := 0
+	for _, name := range files {
+		prog, err := parseFile(name)
+		if err != nil {
+			return err
+		}
+		for _, f := range lint.Run(prog, rules) {
+			fmt.Fprintf(os.Stdout, "%s:%v\n", name, f)
+			problems++
+		}
+	}
+	switch {
+	case problems == 1:
+		return fmt.Errorf("found 1 problem")
+	case problems > 1:
+		return fmt.Errorf("found %d problems", problems)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package lint finds likely mistakes in monkey programs.
+//
+// Each check is a Rule, which can be enabled or disabled by name.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// Rule is a single check.
+type Rule struct {
+	// Name identifies the rule, e.g. "unused-let".
+	Name string
+	// Doc is a one line description of what the rule reports.
+	Doc string
+	// Run checks the program in pass, reporting anything it finds.
+	Run func(pass *Pass)
+}
+
+// Finding is a problem reported by a rule.
+type Finding struct {
+	Pos  token.Pos
+	Rule string
+	Msg  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %s (%s)", f.Pos, f.Msg, f.Rule)
+}
+
+// Pass is the running of a single rule over a program.
+type Pass struct {
+	Rule *Rule
+	Prog *ast.Program
+	// Info is the result of resolving Prog.
+	Info *resolve.Info
+
+	findings []Finding
+}
+
+// Reportf records a finding at pos.
+func (p *Pass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	p.findings = append(p.findings, Finding{
+		Pos:  pos,
+		Rule: p.Rule.Name,
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Rules are all the available rules.
+var Rules = []*Rule{
+	UnusedLet,
+	Unreachable,
+	BoolCompare,
+	EmptyIf,
+	SelfCompare,
+}
+
+// Lookup returns the rule with the given name, or nil.
+func Lookup(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Select returns the rules to run. If enable is empty, all rules are
+// enabled. Rules named in disable are then removed.
+func Select(enable, disable []string) ([]*Rule, error) {
+	on := make(map[*Rule]bool)
+	if len(enable) == 0 {
+		for _, r := range Rules {
+			on[r] = true
+		}
+	}
+	for _, name := range enable {
+		r := Lookup(name)
+		if r == nil {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		on[r] = true
+	}
+	for _, name := range disable {
+		r := Lookup(name)
+		if r == nil {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		delete(on, r)
+	}
+
+	var rules []*Rule
+	for _, r := range Rules {
+		if on[r] {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// ParseList splits a comma separated list of rule names.
+func ParseList(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Run checks prog with each of rules, returning the findings in source order.
+func Run(prog *ast.Program, rules []*Rule) []Finding {
+	info := resolve.Resolve(prog)
+	var findings []Finding
+	for _, r := range rules {
+		pass := &Pass{Rule: r, Prog: prog, Info: info}
+		r.Run(pass)
+		findings = append(findings, pass.findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Pos.Before(findings[j].Pos)
+	})
+	return findings
+}
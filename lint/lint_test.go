@@ -0,0 +1,79 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+func ruleNames(rules []*Rule) string {
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		enable, disable string
+		want            string
+		wantErr         bool
+	}{
+		{"", "", "unused-let,unreachable,bool-compare,empty-if,self-compare", false},
+		{"empty-if", "", "empty-if", false},
+		{"self-compare, unused-let", "", "unused-let,self-compare", false},
+		{"", "unreachable,bool-compare", "unused-let,empty-if,self-compare", false},
+		{"empty-if,unreachable", "empty-if", "unreachable", false},
+		{"nope", "", "", true},
+		{"", "nope", "", true},
+	}
+	for i, tc := range tests {
+		rules, err := Select(ParseList(tc.enable), ParseList(tc.disable))
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%d. Select(%q, %q) error = %v, want error %t", i, tc.enable, tc.disable, err, tc.wantErr)
+			continue
+		}
+		if got := ruleNames(rules); got != tc.want {
+			t.Errorf("%d. Select(%q, %q) = %q, want %q", i, tc.enable, tc.disable, got, tc.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	input := `let unused = 1;
+let f = fn(x) {
+  if (x == true) {}
+  return x;
+  x == x;
+};
+f(1);
+`
+	want := []string{
+		"1:5: unused declared and not used (unused-let)",
+		"3:3: empty if consequence (empty-if)",
+		"3:9: comparison with true; use the condition directly (bool-compare)",
+		"5:3: unreachable code (unreachable)",
+		"5:5: x == x is always true (self-compare)",
+	}
+	var got []string
+	for _, f := range Run(parser.MustParse(input), Rules) {
+		got = append(got, f.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Run() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	rules, err := Select(nil, []string{"unused-let", "unreachable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, f := range Run(parser.MustParse(input), rules) {
+		got = append(got, f.Rule)
+	}
+	if got, want := strings.Join(got, ","), "empty-if,bool-compare,self-compare"; got != want {
+		t.Errorf("Run() with rules disabled found %q, want %q", got, want)
+	}
+}
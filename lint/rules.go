@@ -0,0 +1,127 @@
+package lint
+
+import (
+	"monkey/ast"
+	"monkey/resolve"
+)
+
+// UnusedLet reports let statements whose names are never used.
+var UnusedLet = &Rule{
+	Name: "unused-let",
+	Doc:  "report let bindings which are never used",
+	Run: func(pass *Pass) {
+		var visit func(s *resolve.Scope)
+		visit = func(s *resolve.Scope) {
+			for _, d := range s.Decls {
+				if d.Kind == resolve.Let && len(d.Uses) == 0 && d.Name.Value != "_" {
+					pass.Reportf(d.Name.Pos(), "%s declared and not used", d.Name.Value)
+				}
+			}
+			for _, c := range s.Children {
+				visit(c)
+			}
+		}
+		visit(pass.Info.Scope)
+	},
+}
+
+// Unreachable reports statements following a return statement.
+var Unreachable = &Rule{
+	Name: "unreachable",
+	Doc:  "report statements after a return",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Prog, func(n ast.Node) bool {
+			var stmts []ast.Statement
+			switch n := n.(type) {
+			case *ast.Program:
+				stmts = n.Statements
+			case *ast.BlockStatement:
+				stmts = n.Statements
+			}
+			for i, s := range stmts {
+				if _, ok := s.(*ast.ReturnStatement); ok && i+1 < len(stmts) {
+					pass.Reportf(stmts[i+1].Pos(), "unreachable code")
+					break
+				}
+			}
+			return true
+		})
+	},
+}
+
+// BoolCompare reports comparisons against true or false.
+var BoolCompare = &Rule{
+	Name: "bool-compare",
+	Doc:  "report comparisons against the literals true and false",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Prog, func(n ast.Node) bool {
+			ie, ok := n.(*ast.InfixExpression)
+			if !ok || ie.Operator != "==" && ie.Operator != "!=" {
+				return true
+			}
+			for _, side := range []ast.Expression{ie.Left, ie.Right} {
+				if b, ok := side.(*ast.Boolean); ok {
+					pass.Reportf(ie.Pos(), "comparison with %v; use the condition directly", b)
+					break
+				}
+			}
+			return true
+		})
+	},
+}
+
+// EmptyIf reports if expressions whose consequence does nothing.
+var EmptyIf = &Rule{
+	Name: "empty-if",
+	Doc:  "report if expressions with an empty consequence",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Prog, func(n ast.Node) bool {
+			if ie, ok := n.(*ast.IfExpression); ok && ie.Consequence != nil && len(ie.Consequence.Statements) == 0 {
+				pass.Reportf(ie.Pos(), "empty if consequence")
+			}
+			return true
+		})
+	},
+}
+
+// selfCompareResults are the results of comparing a value with itself.
+var selfCompareResults = map[string]bool{
+	"==": true,
+	"!=": false,
+	"<":  false,
+	">":  false,
+}
+
+// SelfCompare reports comparisons of an expression with itself.
+var SelfCompare = &Rule{
+	Name: "self-compare",
+	Doc:  "report comparisons of an expression with itself",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Prog, func(n ast.Node) bool {
+			ie, ok := n.(*ast.InfixExpression)
+			if !ok {
+				return true
+			}
+			result, ok := selfCompareResults[ie.Operator]
+			if ok && isSimple(ie.Left) && ie.Left.String() == ie.Right.String() {
+				pass.Reportf(ie.Pos(), "%s %s %s is always %t", ie.Left, ie.Operator, ie.Right, result)
+			}
+			return true
+		})
+	},
+}
+
+// isSimple reports whether e always evaluates to the same value, so it can
+// be compared with itself. Calls may not, and every function literal makes a
+// new function.
+func isSimple(e ast.Expression) bool {
+	simple := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.CallExpression, *ast.FunctionLiteral, *ast.IfExpression:
+			simple = false
+		}
+		return simple
+	})
+	return simple
+}
@@ -0,0 +1,52 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  *Rule
+		input string
+		want  []string
+	}{
+		{UnusedLet, "let x = 1; x;", nil},
+		{UnusedLet, "let x = 1;", []string{"1:5: x declared and not used"}},
+		{UnusedLet, "let _ = 1;", nil},
+		{UnusedLet, "let x = 1; let x = x + 1;", []string{"1:16: x declared and not used"}},
+		{UnusedLet, "let f = fn(unused) { let y = 2; 3 }; f(1);", []string{"1:26: y declared and not used"}},
+		{UnusedLet, "let f = fn(n) { f(n) };", nil},
+		{UnusedLet, "if (true) { let y = 1; }", []string{"1:17: y declared and not used"}},
+
+		{Unreachable, "return 1; 2;", []string{"1:11: unreachable code"}},
+		{Unreachable, "return 1;", nil},
+		{Unreachable, "fn() { return 1; let x = 2; x }", []string{"1:18: unreachable code"}},
+		{Unreachable, "if (true) { return 1 } else { return 2 }; 3", nil},
+
+		{BoolCompare, "x == true", []string{"1:3: comparison with true; use the condition directly"}},
+		{BoolCompare, "false != x", []string{"1:7: comparison with false; use the condition directly"}},
+		{BoolCompare, "x == 1; x < true", nil},
+
+		{EmptyIf, "if (x) {}", []string{"1:1: empty if consequence"}},
+		{EmptyIf, "if (x) {} else { y }", []string{"1:1: empty if consequence"}},
+		{EmptyIf, "if (x) { y } else {}", nil},
+
+		{SelfCompare, "x == x", []string{"1:3: x == x is always true"}},
+		{SelfCompare, "(a + 1) != (a + 1)", []string{"1:9: (a + 1) != (a + 1) is always false"}},
+		{SelfCompare, "x < x; x > x", []string{"1:3: x < x is always false", "1:10: x > x is always false"}},
+		{SelfCompare, "x + x; x == y", nil},
+		{SelfCompare, "f() == f(); fn() {} == fn() {}", nil},
+	}
+	for i, tc := range tests {
+		var got []string
+		for _, f := range Run(parser.MustParse(tc.input), []*Rule{tc.rule}) {
+			got = append(got, f.Pos.String()+": "+f.Msg)
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%d. %s: Run(%q) =\n%s\nwant:\n%s", i, tc.rule.Name, tc.input, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
@@ -2,12 +2,47 @@ package main
 
 import (
 	"fmt"
-	"monkey/repl"
+	"io"
 	"os"
 	"os/user"
+	"sort"
+	"strings"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/repl"
 )
 
+// command is a subcommand of monkey, e.g. "monkey fmt".
+type command struct {
+	run   func(args []string) error
+	short string
+}
+
+var commands = map[string]command{
+	"c":         {runC, "translate monkey source to C"},
+	"callgraph": {runCallgraph, "print the call graph of monkey source"},
+	"fmt":       {runFmt, "pretty print monkey source"},
+	"js":        {runJS, "translate monkey source to JavaScript"},
+	"lint":      {runLint, "report likely mistakes in monkey source"},
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		name := os.Args[1]
+		cmd, ok := commands[name]
+		if !ok {
+			usage()
+			os.Exit(2)
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	u, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,3 +51,36 @@ func main() {
 
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: monkey [command] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nWith no command, monkey starts a REPL. The commands are:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-10s %s\n", name, commands[name].short)
+	}
+}
+
+// parseFile reads and parses the named file, or stdin if name is "-".
+func parseFile(name string) (*ast.Program, error) {
+	var src []byte
+	var err error
+	if name == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	prog := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %s", name, strings.Join(errs, "\n\t"))
+	}
+	return prog, nil
+}
@@ -0,0 +1,86 @@
+// Package names makes names for the variables and functions which passes add
+// to monkey programs. The names start with a prefix which no name in the
+// program starts with, so they can't clash with the program's own, and as
+// monkey names can't contain digits, they're numbered with letters.
+package names
+
+import (
+	"strings"
+
+	"monkey/ast"
+)
+
+// Letters writes n, from 1, in letters: a, b, ..., z, aa, ab, ....
+func Letters(n int) string {
+	s := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		s = string(rune('a'+(n-1)%26)) + s
+	}
+	return s
+}
+
+// Prefix returns base, followed by as many underscores as it takes for no
+// identifier in n to start with it.
+func Prefix(n ast.Node, base string) string {
+	var ids []string
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok {
+			ids = append(ids, id.Value)
+		}
+		return true
+	})
+	prefix := base
+	for clash := true; clash; {
+		clash = false
+		for _, id := range ids {
+			if strings.HasPrefix(id, prefix) {
+				prefix += "_"
+				clash = true
+				break
+			}
+		}
+	}
+	return prefix
+}
+
+// A Namer makes new names which start with its prefix.
+type Namer struct {
+	Prefix string
+	used   map[string]bool
+	n      int // number of names made by Next
+}
+
+// New returns a Namer whose prefix is Prefix(n, base).
+func New(n ast.Node, base string) *Namer {
+	return &Namer{Prefix: Prefix(n, base), used: make(map[string]bool)}
+}
+
+// Use marks the prefix followed by name as taken, for names made without
+// the Namer.
+func (nm *Namer) Use(name string) {
+	nm.used[nm.Prefix+name] = true
+}
+
+// Fresh returns a new name based on name: the prefix followed by name, or if
+// that's taken, by name, an underscore, and b, c, and so on.
+func (nm *Namer) Fresh(name string) string {
+	s := nm.Prefix + name
+	for i := 2; nm.used[s]; i++ {
+		s = nm.Prefix + name + "_" + Letters(i)
+	}
+	nm.used[s] = true
+	return s
+}
+
+// Next returns the next of the numbered names: the prefix followed by a, b,
+// and so on, skipping those which are taken.
+func (nm *Namer) Next() string {
+	for {
+		nm.n++
+		s := nm.Prefix + Letters(nm.n)
+		if !nm.used[s] {
+			nm.used[s] = true
+			return s
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package names
+
+import (
+	"testing"
+
+	"monkey/parser"
+)
+
+func TestLetters(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "a"},
+		{2, "b"},
+		{26, "z"},
+		{27, "aa"},
+		{52, "az"},
+		{53, "ba"},
+		{702, "zz"},
+		{703, "aaa"},
+	}
+	for i, tt := range tests {
+		if got := Letters(tt.n); got != tt.want {
+			t.Errorf("%d. Letters(%d): got %q, want %q", i, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x = 1;", "tmp_"},
+		{"let tmp = 1;", "tmp_"},
+		{"let tmp_x = 1;", "tmp__"},
+		{"let f = fn(tmp__) { tmp_x };", "tmp___"},
+	}
+	for i, tt := range tests {
+		prog := parser.MustParse(tt.input)
+		if got := Prefix(prog, "tmp_"); got != tt.want {
+			t.Errorf("%d. Prefix(%q): got %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNamer(t *testing.T) {
+	nm := &Namer{Prefix: "p_", used: make(map[string]bool)}
+	nm.Use("b")
+	var got []string
+	for _, name := range []string{"f", "f", "f"} {
+		got = append(got, nm.Fresh(name))
+	}
+	got = append(got, nm.Next(), nm.Next(), nm.Fresh("c"))
+	want := []string{"p_f", "p_f_b", "p_f_c", "p_a", "p_c", "p_c_b"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d. got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package optimize
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// Reason says why EliminateDeadCode removed some code.
+type Reason int
+
+const (
+	// Unreachable code follows a return statement.
+	Unreachable Reason = iota
+	// UnusedLet is a let statement whose name is never used.
+	UnusedLet
+	// NoEffect is an expression statement whose value is thrown away.
+	NoEffect
+	// NeverTaken is a branch of an if expression whose condition is a literal.
+	NeverTaken
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Unreachable:
+		return "unreachable code"
+	case UnusedLet:
+		return "unused let"
+	case NoEffect:
+		return "statement with no effect"
+	case NeverTaken:
+		return "branch never taken"
+	}
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+// Removal is a statement or block removed by EliminateDeadCode.
+type Removal struct {
+	Pos    token.Pos
+	Reason Reason
+	// Node is the code which was removed.
+	Node ast.Node
+}
+
+func (r Removal) String() string {
+	if ls, ok := r.Node.(*ast.LetStatement); ok {
+		return fmt.Sprintf("%v: removed %s %s", r.Pos, r.Reason, ls.Name.Value)
+	}
+	return fmt.Sprintf("%v: removed %s", r.Pos, r.Reason)
+}
+
+// EliminateDeadCode removes code from prog which can never run, or whose
+// only effect is to compute a value which is never used:
+//
+//   - statements after a return statement, other than let statements which
+//     the code kept refers to, as functions may use names declared later;
+//   - let statements whose name is never used;
+//   - expression statements, other than the last in a block, which can't fail;
+//   - the branch of an if expression which a literal condition never takes.
+//
+// Only let statements whose value can't fail are removed. The last statement
+// of a block is always kept, as it gives the block its value. Removing code
+// can leave other code unused, so this repeats until nothing changes.
+func EliminateDeadCode(prog *ast.Program) (*ast.Program, []Removal) {
+	var removed []Removal
+	for {
+		d := &deadCode{info: resolve.Resolve(prog)}
+		rw := &rewriter{filter: d.filter, post: d.prune}
+		prog = rw.program(prog)
+		if len(d.removed) == 0 {
+			break
+		}
+		removed = append(removed, d.removed...)
+	}
+	sort.SliceStable(removed, func(i, j int) bool {
+		return removed[i].Pos.Before(removed[j].Pos)
+	})
+	return prog, removed
+}
+
+type deadCode struct {
+	info    *resolve.Info
+	removed []Removal
+	// failing are identifiers which may not have a value when evaluated.
+	failing map[*ast.Identifier]bool
+}
+
+func (d *deadCode) remove(n ast.Node, reason Reason) {
+	d.removed = append(d.removed, Removal{Pos: n.Pos(), Reason: reason, Node: n})
+}
+
+// filter removes dead statements from a block.
+func (d *deadCode) filter(stmts []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for i, s := range stmts {
+		if i == len(stmts)-1 {
+			out = append(out, s)
+			break
+		}
+		switch s := s.(type) {
+		case *ast.ReturnStatement:
+			out = append(out, s)
+			tail := stmts[i+1:]
+			keep := d.referenced(tail)
+			for j, u := range tail {
+				if keep[j] {
+					out = append(out, u)
+				} else {
+					d.remove(u, Unreachable)
+				}
+			}
+			return out
+		case *ast.LetStatement:
+			if d.unused(s) {
+				d.remove(s, UnusedLet)
+				continue
+			}
+		case *ast.ExpressionStatement:
+			if s.Expression != nil && d.pure(s.Expression) {
+				d.remove(s, NoEffect)
+				continue
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// referenced reports which of the unreachable statements in tail are let
+// statements whose names are used by code outside the others, such as a
+// function returned before them.
+func (d *deadCode) referenced(tail []ast.Statement) []bool {
+	keep := make([]bool, len(tail))
+	// removed reports whether n is in a statement of tail which isn't kept.
+	removed := func(n ast.Node) bool {
+		for j, u := range tail {
+			if !keep[j] && contains(u, n) {
+				return true
+			}
+		}
+		return false
+	}
+	for changed := true; changed; {
+		changed = false
+		for j, u := range tail {
+			ls, ok := u.(*ast.LetStatement)
+			if !ok || keep[j] || d.info.Defs[ls.Name] == nil {
+				continue
+			}
+			for _, use := range d.info.Defs[ls.Name].Uses {
+				if !removed(use) {
+					keep[j] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return keep
+}
+
+// unused reports whether ls can be removed.
+func (d *deadCode) unused(ls *ast.LetStatement) bool {
+	decl := d.info.Defs[ls.Name]
+	if decl == nil || len(decl.Uses) > 0 || !d.pure(ls.Value) {
+		return false
+	}
+	// A function may be using an earlier declaration of the same name, which
+	// this one replaces when it runs.
+	for _, other := range decl.Scope.Decls {
+		if other != decl && other.Name.Value == decl.Name.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// pure reports whether evaluating e certainly succeeds and has no effect
+// other than producing its value.
+func (d *deadCode) pure(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.Identifier:
+		return d.info.Uses[e] != nil && !d.isFailing(e)
+	case *ast.PrefixExpression:
+		switch e.Operator {
+		case "!":
+			return d.pure(e.Right)
+		case "-":
+			_, ok := e.Right.(*ast.IntegerLiteral)
+			return ok
+		}
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "==", "!=":
+			// Values of any type can be compared.
+			return d.pure(e.Left) && d.pure(e.Right)
+		}
+		_, lok := e.Left.(*ast.IntegerLiteral)
+		r, rok := e.Right.(*ast.IntegerLiteral)
+		return lok && rok && !(e.Operator == "/" && r.Value == 0)
+	}
+	return false
+}
+
+func (d *deadCode) isFailing(id *ast.Identifier) bool {
+	if d.failing == nil {
+		d.failing = make(map[*ast.Identifier]bool)
+		for _, diag := range d.info.Diagnostics {
+			if diag.IsError() {
+				d.failing[diag.Ident] = true
+			}
+		}
+	}
+	return d.failing[id]
+}
+
+// prune removes the branch of an if expression which is never taken.
+func (d *deadCode) prune(e ast.Expression) ast.Expression {
+	ie, ok := e.(*ast.IfExpression)
+	if !ok {
+		return e
+	}
+	truthy, ok := truthiness(ie.Condition)
+	if !ok {
+		return e
+	}
+	untaken := ie.Consequence
+	if truthy {
+		untaken = ie.Alternative
+	}
+	if untaken == nil || !truthy && len(untaken.Statements) == 0 && ie.Alternative == nil {
+		// Nothing to remove.
+		return e
+	}
+	d.remove(untaken, NeverTaken)
+	return foldIf(ie)
+}
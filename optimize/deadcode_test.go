@@ -0,0 +1,125 @@
+package optimize
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/parser"
+	"monkey/resolve"
+)
+
+func TestEliminateDeadCode(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        string
+		wantRemoved []string
+	}{
+		{
+			input: "let x = 1; x;",
+			want:  "let x = 1;x;",
+		},
+		{
+			input:       "return 1; f(); 2",
+			want:        "return 1;",
+			wantRemoved: []string{"1:11: removed unreachable code", "1:16: removed unreachable code"},
+		},
+		{
+			input:       "let f = fn() { return 1; g(); };\nf();",
+			want:        "let f = fn() {\nreturn 1;\n};f();",
+			wantRemoved: []string{"1:26: removed unreachable code"},
+		},
+		{
+			// The returned function uses a, which uses b.
+			input:       "let f = fn() { return fn() { a }; f(); let b = 1; let a = b; let c = 2; };\nf()()",
+			want:        "let f = fn() {\nreturn fn() {\na;\n};\nlet b = 1;\nlet a = b;\n};f()();",
+			wantRemoved: []string{"1:35: removed unreachable code", "1:62: removed unreachable code c"},
+		},
+		{
+			input:       "let x = 1;\nlet y = 2;\nx;",
+			want:        "let x = 1;x;",
+			wantRemoved: []string{"2:1: removed unused let y"},
+		},
+		{
+			// Removing b leaves a unused.
+			input: "let a = 1;\nlet b = a;\nlet c = fn() { b };\n3",
+			want:  "3;",
+			wantRemoved: []string{
+				"1:1: removed unused let a",
+				"2:1: removed unused let b",
+				"3:1: removed unused let c",
+			},
+		},
+		{
+			// These may fail or have effects.
+			input: "let a = f(); let b = 1 / 0; let c = -true; let d = x; let e = 1 + true; 3",
+			want:  "let a = f();let b = (1 / 0);let c = (-true);let d = x;let e = (1 + true);3;",
+		},
+		{
+			input:       "let a = 1 == true; let b = !5; let c = 2 * 3; 4",
+			want:        "4;",
+			wantRemoved: []string{"1:1: removed unused let a", "1:20: removed unused let b", "1:32: removed unused let c"},
+		},
+		{
+			// f's x is replaced by the second let when it is called.
+			input: "let x = 1; let f = fn() { x }; let x = 2; f()",
+			want:  "let x = 1;let f = fn() {\nx;\n};let x = 2;f();",
+		},
+		{
+			input:       "let x = 1;\nx;\n5;\nf();\n6",
+			want:        "f();6;",
+			wantRemoved: []string{"1:1: removed unused let x", "2:1: removed statement with no effect", "3:1: removed statement with no effect"},
+		},
+		{
+			// The last statement gives the block its value.
+			input: "let f = fn() { let x = 1; }; f()",
+			want:  "let f = fn() {\nlet x = 1;\n};f();",
+		},
+		{
+			input:       "if (false) { f() } else { g() }",
+			want:        "g();",
+			wantRemoved: []string{"1:12: removed branch never taken"},
+		},
+		{
+			input:       "if (true) { f() } else { g() }",
+			want:        "f();",
+			wantRemoved: []string{"1:24: removed branch never taken"},
+		},
+		{
+			input:       "if (false) { f() }",
+			want:        "if (false) {\n};",
+			wantRemoved: []string{"1:12: removed branch never taken"},
+		},
+		{
+			input: "if (true) { f() }; if (false) {}; if (x) { f() } else { g() }",
+			want:  "if (true) {\nf();\n};if (false) {\n};if (x) {\nf();\n} else {\ng();\n};",
+		},
+		{
+			// Undefined names fail when evaluated.
+			input: "x; y",
+			want:  "x;y;",
+		},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		before := prog.String()
+		got, removed := EliminateDeadCode(prog)
+		if got := got.String(); got != tc.want {
+			t.Errorf("%d. EliminateDeadCode(%q) = %q, want %q", i, tc.input, got, tc.want)
+		}
+		var gotRemoved []string
+		for _, r := range removed {
+			gotRemoved = append(gotRemoved, r.String())
+		}
+		if strings.Join(gotRemoved, "\n") != strings.Join(tc.wantRemoved, "\n") {
+			t.Errorf("%d. EliminateDeadCode(%q) removed\n%s\nwant\n%s", i, tc.input, strings.Join(gotRemoved, "\n"), strings.Join(tc.wantRemoved, "\n"))
+		}
+		if resolve.Resolve(prog).Err() == nil {
+			if err := resolve.Resolve(got).Err(); err != nil {
+				t.Errorf("%d. EliminateDeadCode(%q) = %s: %v", i, tc.input, got, err)
+			}
+		}
+		if after := prog.String(); after != before {
+			t.Errorf("%d. EliminateDeadCode(%q) modified its input: %q, was %q", i, tc.input, after, before)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package optimize rewrites monkey programs into simpler programs which
+// behave the same way.
+//
+// Passes never modify the program they're given; they return a new tree.
+package optimize
+
+import (
+	"math"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Fold evaluates the operators in prog whose operands are integer or boolean
+// literals, and replaces if expressions whose condition is a literal with the
+// branch that would be taken.
+//
+// Arithmetic wraps around, as it does when evaluated. Division by zero is left
+// alone, so that it still fails when run.
+func Fold(prog *ast.Program) *ast.Program {
+	rw := &rewriter{post: fold}
+	return rw.program(prog)
+}
+
+// fold simplifies e, whose children have already been folded.
+func fold(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		return foldPrefix(e)
+	case *ast.InfixExpression:
+		return foldInfix(e)
+	case *ast.IfExpression:
+		return foldIf(e)
+	}
+	return e
+}
+
+func intLit(pos token.Pos, v int64) *ast.IntegerLiteral {
+	lit := strconv.FormatInt(v, 10)
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit, Pos: pos}, Value: v}
+}
+
+// intExpr returns an expression whose value is v. Monkey has no negative
+// literals, so a negative v is the negation of a literal, as the parser would
+// read it. The most negative integer can't be written that way, as its
+// negation doesn't fit in an int64, so intExpr returns nil for it and the
+// expression which computes it is left alone.
+func intExpr(pos token.Pos, v int64) ast.Expression {
+	switch {
+	case v == math.MinInt64:
+		return nil
+	case v < 0:
+		return &ast.PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-", Pos: pos}, Operator: "-", Right: intLit(pos, -v)}
+	}
+	return intLit(pos, v)
+}
+
+// intValue returns the value of e if it's an integer literal, or the
+// negation of one.
+func intValue(e ast.Expression) (int64, bool) {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return e.Value, true
+	case *ast.PrefixExpression:
+		if lit, ok := e.Right.(*ast.IntegerLiteral); ok && e.Operator == "-" {
+			return -lit.Value, true
+		}
+	}
+	return 0, false
+}
+
+func boolLit(pos token.Pos, v bool) *ast.Boolean {
+	lit := strconv.FormatBool(v)
+	return &ast.Boolean{Token: token.Token{Type: token.Lookup(lit), Literal: lit, Pos: pos}, Value: v}
+}
+
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	if v, ok := intValue(pe.Right); ok {
+		switch pe.Operator {
+		case "-":
+			if _, lit := pe.Right.(*ast.IntegerLiteral); !lit {
+				if e := intExpr(pe.Pos(), -v); e != nil {
+					return e
+				}
+			}
+		case "!":
+			// Every integer is truthy.
+			return boolLit(pe.Pos(), false)
+		}
+	}
+	if right, ok := pe.Right.(*ast.Boolean); ok && pe.Operator == "!" {
+		return boolLit(pe.Pos(), !right.Value)
+	}
+	return pe
+}
+
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	if l, ok := intValue(ie.Left); ok {
+		r, ok := intValue(ie.Right)
+		if !ok {
+			return ie
+		}
+		var v ast.Expression
+		switch ie.Operator {
+		case "+":
+			v = intExpr(ie.Pos(), l+r)
+		case "-":
+			v = intExpr(ie.Pos(), l-r)
+		case "*":
+			v = intExpr(ie.Pos(), l*r)
+		case "/":
+			if r != 0 {
+				v = intExpr(ie.Pos(), l/r)
+			}
+		case "<":
+			return boolLit(ie.Pos(), l < r)
+		case ">":
+			return boolLit(ie.Pos(), l > r)
+		case "==":
+			return boolLit(ie.Pos(), l == r)
+		case "!=":
+			return boolLit(ie.Pos(), l != r)
+		}
+		if v != nil {
+			return v
+		}
+		return ie
+	}
+	if left, ok := ie.Left.(*ast.Boolean); ok {
+		right, ok := ie.Right.(*ast.Boolean)
+		if !ok {
+			return ie
+		}
+		switch ie.Operator {
+		case "==":
+			return boolLit(ie.Pos(), left.Value == right.Value)
+		case "!=":
+			return boolLit(ie.Pos(), left.Value != right.Value)
+		}
+	}
+	return ie
+}
+
+// truthiness reports whether e is a literal, and if so whether an if
+// expression would take its consequence.
+func truthiness(e ast.Expression) (truthy, ok bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	}
+	if _, ok := intValue(e); ok {
+		return true, true
+	}
+	return false, false
+}
+
+// foldIf replaces an if expression whose condition is known with the branch
+// it takes. A branch which is a single expression replaces the whole if
+// expression. Otherwise the if expression is kept, as its block is needed,
+// but without the branch which is never taken.
+func foldIf(ie *ast.IfExpression) ast.Expression {
+	truthy, ok := truthiness(ie.Condition)
+	if !ok {
+		return ie
+	}
+	taken := ie.Alternative
+	if truthy {
+		taken = ie.Consequence
+	}
+	if taken == nil {
+		// Evaluates to null.
+		return &ast.IfExpression{
+			Token:       ie.Token,
+			Condition:   boolLit(ie.Condition.Pos(), false),
+			Consequence: &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}},
+		}
+	}
+	if len(taken.Statements) == 1 {
+		if es, ok := taken.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+			return es.Expression
+		}
+	}
+	return &ast.IfExpression{
+		Token:       ie.Token,
+		Condition:   boolLit(ie.Condition.Pos(), true),
+		Consequence: taken,
+	}
+}
@@ -0,0 +1,112 @@
+package optimize
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestFold(t *testing.T) {
+	maxInt := strconv.FormatInt(math.MaxInt64, 10)
+	tests := []struct {
+		input, want string
+	}{
+		{"1 + 2 * 3", "7;"},
+		{"(1 + 2) * 3", "9;"},
+		{"10 - 4 - 3", "3;"},
+		{"7 / 2", "3;"},
+		{"-7 / 2", "(-3);"},
+		{"-(2 - 5)", "3;"},
+		{"--5", "5;"},
+		{"-5", "(-5);"},
+		{"1 - 10; 1 + -5; -5 * -5", "(-9);(-4);25;"},
+		{maxInt + " * 2", "(-2);"},
+		// The most negative integer has no literal to negate.
+		{maxInt + " + 1", "(" + maxInt + " + 1);"},
+		{"-" + maxInt + " - 1", "((-" + maxInt + ") - 1);"},
+		{"1 < 2; 2 > 3; 1 == 1; 1 != 1", "true;false;true;false;"},
+		{"!true; !false; !!true; !5", "false;true;true;false;"},
+		{"true == false; true != false", "false;true;"},
+		{"1 + x * 2", "(1 + (x * 2));"},
+		{"x + 1 + 2", "((x + 1) + 2);"},
+		{"f(1 + 2, 3 * 4)", "f(3, 12);"},
+		{"let x = 2 * 21;", "let x = 42;"},
+		{"fn(a) { return 1 + 1; }", "fn(a) {\nreturn 2;\n};"},
+
+		// Never fold division by zero.
+		{"1 / 0", "(1 / 0);"},
+		{"1 / (2 - 2)", "(1 / 0);"},
+
+		// Mixed types are left to fail at run time.
+		{"1 + true; -true; 1 == true", "(1 + true);(-true);(1 == true);"},
+
+		{"if (true) { 1 } else { 2 }", "1;"},
+		{"if (1 > 2) { 1 } else { 2 + 3 }", "5;"},
+		{"if (0) { x } else { y }", "x;"},
+		{"if (1 - 2) { x } else { y }", "x;"},
+		{"if (false) { x }", "if (false) {\n};"},
+		{"if (true) { let y = 1; y }", "if (true) {\nlet y = 1;\ny;\n};"},
+		{"if (false) { x } else { return 1; }", "if (true) {\nreturn 1;\n};"},
+		{"if (x) { 1 + 1 } else { 2 * 2 }", "if (x) {\n2;\n} else {\n4;\n};"},
+		{"let f = fn() { if (true) { 1 } }; f()", "let f = fn() {\n1;\n};f();"},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		before := prog.String()
+		got := Fold(prog)
+		if got := got.String(); got != tc.want {
+			t.Errorf("%d. Fold(%q) = %q, want %q", i, tc.input, got, tc.want)
+		}
+		if after := prog.String(); after != before {
+			t.Errorf("%d. Fold(%q) modified its input: %q, was %q", i, tc.input, after, before)
+		}
+	}
+}
+
+// TestFoldReparses checks that folded programs print in the canonical form:
+// parsing what they print gives back the same tree.
+func TestFoldReparses(t *testing.T) {
+	maxInt := strconv.FormatInt(math.MaxInt64, 10)
+	tests := []string{
+		"-7 / 2",
+		"1 - 10; 1 + -5; --5",
+		maxInt + " * 2",
+		maxInt + " + 1",
+		"-" + maxInt + " - 1",
+		"let f = fn(x) { if (x) { 2 - 3 } else { -(1 + 1) } }; f(0 - 1)",
+	}
+	for i, input := range tests {
+		folded := Fold(parser.MustParse(input))
+		src := folded.String()
+		p := parser.New(lexer.New(src))
+		got := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%d. Fold(%q) printed %q, which doesn't parse: %v", i, input, src, errs)
+			continue
+		}
+		if !ast.Equal(got, folded) {
+			t.Errorf("%d. Fold(%q) printed %q, which parses as %v", i, input, src, got)
+		}
+	}
+}
+
+func TestFoldCopies(t *testing.T) {
+	prog := parser.MustParse("let f = fn(x) { x + y }; f(z);")
+	got := Fold(prog)
+
+	seen := make(map[ast.Node]bool)
+	ast.Inspect(prog, func(n ast.Node) bool {
+		seen[n] = true
+		return true
+	})
+	ast.Inspect(got, func(n ast.Node) bool {
+		if seen[n] {
+			t.Errorf("Fold() shares %T %v with its input", n, n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,330 @@
+package optimize
+
+import (
+	"monkey/ast"
+	"monkey/names"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// DefaultInlineSize is a size for Inline which inlines functions of a line or
+// two, such as fn(x, y) { x + y }.
+const DefaultInlineSize = 12
+
+// Inline replaces calls of small functions with their bodies. A function may
+// be inlined if it's bound by a let statement which is the only declaration
+// of its name in its scope, it has no return statements, it doesn't call
+// itself, directly or through other functions which may be inlined, and its
+// body has at most maxSize nodes. A call is replaced if it calls such a
+// function by name, with the right number of arguments, from where the names
+// the body uses from outside the function mean the same as they do in it.
+//
+// Monkey has no expression which declares names other than a block, so the
+// body takes the place of the call in the block of an if expression which is
+// always taken, after let statements which bind the arguments:
+//
+//	let add = fn(x, y) { x * y + 1 }; add(f(1), 2)
+//
+// becomes
+//
+//	let add = fn(x, y) { x * y + 1 }; if (true) { let inline_x = f(1); let inline_y = 2; ((inline_x * inline_y) + 1) }
+//
+// The parameters and the names declared in the body are renamed, with a
+// prefix which no name in the program starts with, so they can't hide the
+// names the arguments use. If the body is a single expression, and the
+// arguments are literals or names it doesn't hide, it replaces the call
+// without a block, with the arguments in place of the parameters:
+//
+//	add(a, 2)
+//
+// becomes
+//
+//	((a * 2) + 1)
+//
+// The functions are left in place; EliminateDeadCode removes the ones which
+// are no longer used. Calls in the bodies which are inlined are left alone,
+// so inlining again may inline them too.
+func Inline(prog *ast.Program, maxSize int) *ast.Program {
+	in := &inliner{
+		info:    resolve.Resolve(prog),
+		scopes:  make(map[*ast.CallExpression]*resolve.Scope),
+		fns:     make(map[*resolve.Decl]*ast.FunctionLiteral),
+		maxSize: maxSize,
+		names:   names.New(prog, "inline_"),
+	}
+	in.findCalls(prog, nil)
+	in.findFunctions()
+
+	rw := &rewriter{}
+	rw.pre = func(e ast.Expression) ast.Expression {
+		if ce, ok := e.(*ast.CallExpression); ok {
+			if out := in.inline(rw, ce); out != nil {
+				return out
+			}
+		}
+		return nil
+	}
+	return rw.program(prog)
+}
+
+type inliner struct {
+	info *resolve.Info
+	// scopes are the scopes calls are in.
+	scopes map[*ast.CallExpression]*resolve.Scope
+	// fns are the functions which may be inlined.
+	fns     map[*resolve.Decl]*ast.FunctionLiteral
+	maxSize int
+	names   *names.Namer
+}
+
+// findCalls finds the scope of each call in n, which is in scope s.
+func (in *inliner) findCalls(n ast.Node, s *resolve.Scope) {
+	if scope := in.info.Scopes[n]; scope != nil {
+		s = scope
+	}
+	if ce, ok := n.(*ast.CallExpression); ok {
+		in.scopes[ce] = s
+	}
+	for _, c := range ast.Children(n) {
+		in.findCalls(c, s)
+	}
+}
+
+// findFunctions finds the functions which may be inlined.
+func (in *inliner) findFunctions() {
+	// calls are the functions each function refers to by name.
+	calls := make(map[*resolve.Decl][]*resolve.Decl)
+	for _, d := range in.info.Defs {
+		if d.Let == nil || len(d.Uses) == 0 {
+			continue
+		}
+		fl, ok := d.Let.Value.(*ast.FunctionLiteral)
+		if !ok || fl.Body == nil || size(fl.Body) > in.maxSize || ast.HasReturn(fl.Body) {
+			continue
+		}
+		once := true
+		for _, other := range d.Scope.Decls {
+			if other != d && other.Name.Value == d.Name.Value {
+				once = false
+			}
+		}
+		if !once {
+			continue
+		}
+		in.fns[d] = fl
+		ast.Inspect(fl.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Identifier); ok && in.info.Uses[id] != nil {
+				calls[d] = append(calls[d], in.info.Uses[id])
+			}
+			return true
+		})
+	}
+	// Functions which can reach themselves are recursive.
+	for d := range in.fns {
+		seen := make(map[*resolve.Decl]bool)
+		var reaches func(from *resolve.Decl) bool
+		reaches = func(from *resolve.Decl) bool {
+			for _, to := range calls[from] {
+				if to == d {
+					return true
+				}
+				if !seen[to] && in.fns[to] != nil {
+					seen[to] = true
+					if reaches(to) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if reaches(d) {
+			delete(in.fns, d)
+		}
+	}
+}
+
+// size returns the number of nodes in n.
+func size(n ast.Node) int {
+	count := 0
+	ast.Inspect(n, func(ast.Node) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// inline returns the inlined body of the function ce calls, or nil if it
+// can't be inlined. rw copies the arguments.
+func (in *inliner) inline(rw *rewriter, ce *ast.CallExpression) ast.Expression {
+	callee, ok := ce.Function.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	d := in.info.Uses[callee]
+	fl := in.fns[d]
+	if fl == nil || len(fl.Parameters) != len(ce.Arguments) || !in.sameNames(fl, ce) {
+		return nil
+	}
+
+	if e := in.substitute(fl, ce); e != nil {
+		return e
+	}
+	// The declarations the body's names refer to, and what replaces them.
+	// The declarations of a variable share one new name, so closures in the
+	// body see the values later declarations bind.
+	replace := make(map[*resolve.Decl]ast.Expression)
+	for _, decls := range in.info.Scopes[fl].Variables() {
+		name := in.names.Fresh(decls[0].Name.Value)
+		for _, d := range decls {
+			replace[d] = ident(d.Name.Pos(), name)
+		}
+	}
+	var out []ast.Statement
+	for i, p := range fl.Parameters {
+		out = append(out, &ast.LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let", Pos: ce.Pos()},
+			Name:  replace[in.info.Defs[p]].(*ast.Identifier),
+			Type:  p.Type,
+			Value: rw.expression(ce.Arguments[i]),
+		})
+	}
+	body := in.copyBody(fl, replace)
+	return &ast.IfExpression{
+		Token:       token.Token{Type: token.IF, Literal: "if", Pos: ce.Pos()},
+		Condition:   boolLit(ce.Pos(), true),
+		Consequence: &ast.BlockStatement{Token: fl.Body.Token, Statements: append(out, body.Statements...)},
+	}
+}
+
+func ident(pos token.Pos, name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: pos}, Value: name}
+}
+
+// substitute returns the body of fl with ce's arguments in place of its
+// parameters, if it's a single expression and the arguments are literals or
+// names it doesn't declare, or nil.
+func (in *inliner) substitute(fl *ast.FunctionLiteral, ce *ast.CallExpression) ast.Expression {
+	if len(fl.Body.Statements) != 1 {
+		return nil
+	}
+	es, ok := fl.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil {
+		return nil
+	}
+	declared := make(map[string]bool)
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok && in.info.Defs[id] != nil {
+			declared[id.Value] = true
+		}
+		return true
+	})
+	replace := make(map[*resolve.Decl]ast.Expression)
+	for i, a := range ce.Arguments {
+		switch a := a.(type) {
+		case *ast.IntegerLiteral, *ast.Boolean:
+		case *ast.Identifier:
+			if declared[a.Value] {
+				return nil
+			}
+		default:
+			return nil
+		}
+		replace[in.info.Defs[fl.Parameters[i]]] = ce.Arguments[i]
+	}
+	return in.copyBody(fl, replace).Statements[0].(*ast.ExpressionStatement).Expression
+}
+
+// copyBody copies the body of fl, replacing the names which refer to the
+// declarations in replace.
+func (in *inliner) copyBody(fl *ast.FunctionLiteral, replace map[*resolve.Decl]ast.Expression) *ast.BlockStatement {
+	rw := &rewriter{pre: func(e ast.Expression) ast.Expression {
+		id, ok := e.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		d := in.info.Uses[id]
+		if d == nil {
+			d = in.info.Defs[id]
+		}
+		if r, ok := replace[d]; ok {
+			// Each use gets its own copy.
+			return (&rewriter{}).expression(r)
+		}
+		return nil
+	}}
+	return rw.block(fl.Body)
+}
+
+// sameNames reports whether the names fl's body uses from outside fl refer to
+// the same declarations at ce.
+func (in *inliner) sameNames(fl *ast.FunctionLiteral, ce *ast.CallExpression) bool {
+	inside := make(map[*resolve.Scope]bool)
+	var mark func(s *resolve.Scope)
+	mark = func(s *resolve.Scope) {
+		inside[s] = true
+		for _, c := range s.Children {
+			mark(c)
+		}
+	}
+	mark(in.info.Scopes[fl])
+
+	ok := true
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		id, isIdent := n.(*ast.Identifier)
+		if !isIdent || in.info.Uses[id] == nil {
+			return ok
+		}
+		d := in.info.Uses[id]
+		if !inside[d.Scope] && !in.visible(d, ce) {
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// visible reports whether d is the declaration its name refers to at ce, and
+// has been declared when ce is evaluated.
+func (in *inliner) visible(d *resolve.Decl, ce *ast.CallExpression) bool {
+	deferred := false // whether ce is in a function inside d's scope
+	for s := in.scopes[ce]; s != nil; s = s.Parent {
+		for _, other := range s.Decls {
+			if other != d && other.Name.Value == d.Name.Value {
+				return false
+			}
+		}
+		if s == d.Scope {
+			return deferred || d.Kind == resolve.Param || declaredBefore(d, ce)
+		}
+		if s.IsFunction() {
+			deferred = true
+		}
+	}
+	return false
+}
+
+// declaredBefore reports whether d's let statement comes before the
+// statement of its scope which ce is in. The order of the statements, rather
+// than their positions, decides, so trees which weren't parsed are inlined
+// too.
+func declaredBefore(d *resolve.Decl, ce *ast.CallExpression) bool {
+	for _, st := range d.Scope.Statements() {
+		if contains(st, ce) {
+			return false
+		}
+		if st == d.Let {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether n is in the tree rooted at root.
+func contains(root, n ast.Node) bool {
+	found := false
+	ast.Inspect(root, func(c ast.Node) bool {
+		found = found || c == n
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,192 @@
+package optimize
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/build"
+	"monkey/cgen"
+	"monkey/parser"
+	"monkey/resolve"
+)
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "let add = fn(x, y) { x + y }; add(a, 2)",
+			want:  "let add = fn(x, y) {\n(x + y);\n};(a + 2);",
+		},
+		{
+			input: "let add = fn(x, y) { x * y + 1 }; add(f(1), 2)",
+			want:  "let add = fn(x, y) {\n((x * y) + 1);\n};if (true) {\nlet inline_x = f(1);\nlet inline_y = 2;\n((inline_x * inline_y) + 1);\n};",
+		},
+		// The parameters are renamed, so y + 1 doesn't see the new x.
+		{
+			input: "let x = 1; let y = 2; let f = fn(y, x) { y - x }; f(x + 1, y + 1)",
+			want:  "let x = 1;let y = 2;let f = fn(y, x) {\n(y - x);\n};if (true) {\nlet inline_y = (x + 1);\nlet inline_x = (y + 1);\n(inline_y - inline_x);\n};",
+		},
+		// So are the names declared in the body.
+		{
+			input: "let f = fn(x) { let t = x * 2; t + t }; let t = 5; f(t)",
+			want:  "let f = fn(x) {\nlet t = (x * 2);\n(t + t);\n};let t = 5;if (true) {\nlet inline_x = t;\nlet inline_t = (inline_x * 2);\n(inline_t + inline_t);\n};",
+		},
+		// Substituting y for x would make the inner function's y mean x.
+		{
+			input: "let f = fn(x) { fn(y) { x + y } }; let y = 1; f(y)",
+			want:  "let f = fn(x) {\nfn(y) {\n(x + y);\n};\n};let y = 1;if (true) {\nlet inline_x = y;\nfn(y) {\n(inline_x + y);\n};\n};",
+		},
+		{
+			input: "let f = fn() { 1 }; f() + f()",
+			want:  "let f = fn() {\n1;\n};(1 + 1);",
+		},
+		{
+			input: "let inline_x = 1; let f = fn(x) { x * x }; f(inline_x + 1)",
+			want:  "let inline_x = 1;let f = fn(x) {\n(x * x);\n};if (true) {\nlet inline__x = (inline_x + 1);\n(inline__x * inline__x);\n};",
+		},
+		// Calls in arguments are inlined too.
+		{
+			input: "let inc = fn(x) { x + 1 }; inc(inc(inc(1)))",
+			want:  "let inc = fn(x) {\n(x + 1);\n};if (true) {\nlet inline_x = if (true) {\nlet inline_x_b = (1 + 1);\n(inline_x_b + 1);\n};\n(inline_x + 1);\n};",
+		},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		before := prog.String()
+		got := Inline(prog, DefaultInlineSize)
+		if got := got.String(); got != tc.want {
+			t.Errorf("%d. Inline(%q) = %q, want %q", i, tc.input, got, tc.want)
+		}
+		if after := prog.String(); after != before {
+			t.Errorf("%d. Inline(%q) modified its input: %q, was %q", i, tc.input, after, before)
+		}
+	}
+}
+
+func TestInlineLeaves(t *testing.T) {
+	tests := []string{
+		// Recursive, directly or not.
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)",
+		"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7)",
+		// Too big.
+		"let f = fn(a, b, c) { let x = a * b + c; let y = x * x - a; x + y * c }; f(1, 2, 3)",
+		// Returns.
+		"let f = fn(x) { return x; }; f(1)",
+		// Declared twice.
+		"let f = fn(x) { x }; let f = fn(x) { x + 1 }; f(1)",
+		// The wrong number of arguments.
+		"let f = fn(x) { x }; f(1, 2)",
+		// A name in the body means something else where it's called.
+		"let k = 1; let f = fn(x) { x + k }; let g = fn(k) { f(k) }; g",
+		"let f = fn(x) { x + k }; f(1); let k = 2;",
+		"let f = fn(x) { k }; let k = f(1);",
+		// Not called by name.
+		"let f = fn(x) { x }; let g = f; g(1); fn(x) { x }(1)",
+	}
+	for i, input := range tests {
+		prog := parser.MustParse(input)
+		if got, want := Inline(prog, DefaultInlineSize).String(), prog.String(); got != want {
+			t.Errorf("%d. Inline(%q) = %q, want it unchanged", i, input, got)
+		}
+	}
+}
+
+func TestInlineSize(t *testing.T) {
+	input := "let f = fn(x) { x + 1 }; f(2)"
+	// fn(x) { x + 1 } has a block, a statement, an infix expression and its
+	// operands.
+	for _, tc := range []struct {
+		size int
+		want string
+	}{
+		{4, "let f = fn(x) {\n(x + 1);\n};f(2);"},
+		{5, "let f = fn(x) {\n(x + 1);\n};(2 + 1);"},
+	} {
+		if got := Inline(parser.MustParse(input), tc.size).String(); got != tc.want {
+			t.Errorf("Inline(%q, %d) = %q, want %q", input, tc.size, got, tc.want)
+		}
+	}
+}
+
+// TestInlineBuilt checks trees made without the parser, whose positions are
+// all zero, are inlined where the order of their statements allows.
+func TestInlineBuilt(t *testing.T) {
+	inc := build.Let("inc", build.Fn(build.Params("x"), build.Expr(build.Add(build.Ident("x"), build.Ident("k")))))
+	tests := []struct {
+		prog *ast.Program
+		want string
+	}{
+		{
+			build.Program(build.Let("k", build.Int(1)), inc, build.Expr(build.Call(build.Ident("inc"), build.Int(2)))),
+			"let k = 1;let inc = fn(x) {\n(x + k);\n};(2 + k);",
+		},
+		// k isn't declared yet where inc is called.
+		{
+			build.Program(inc, build.Expr(build.Call(build.Ident("inc"), build.Int(2))), build.Let("k", build.Int(1))),
+			"let inc = fn(x) {\n(x + k);\n};inc(2);let k = 1;",
+		},
+	}
+	for i, tc := range tests {
+		if got := Inline(tc.prog, DefaultInlineSize).String(); got != tc.want {
+			t.Errorf("%d. Inline(%q) = %q, want %q", i, tc.prog, got, tc.want)
+		}
+	}
+}
+
+// TestInlineRun checks inlined programs print what they did, compiled to C,
+// if there is a C compiler.
+func TestInlineRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc not found")
+	}
+	dir := t.TempDir()
+	run := func(prog *ast.Program) string {
+		src, err := cgen.Generate(prog)
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", prog, err)
+		}
+		file := filepath.Join(dir, "prog.c")
+		if err := os.WriteFile(file, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+		exe := filepath.Join(dir, "prog")
+		if out, err := exec.Command(cc, "-o", exe, file).CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", cc, err, out)
+		}
+		out, _ := exec.Command(exe).CombinedOutput()
+		return strings.TrimSpace(string(out))
+	}
+	tests := []string{
+		"let add = fn(x, y) { x + y }; let sum = fn(n) { if (n == 0) { 0 } else { add(n, sum(n - 1)) } }; sum(100)",
+		"let x = 1; let y = 2; let f = fn(y, x) { y - x }; f(x + 1, y + 10)",
+		"let f = fn(x) { let t = x * 2; t + t }; let t = 5; f(t) + f(f(1))",
+		"let f = fn(x) { fn(y) { x * 10 + y } }; let y = 1; f(y)(2)",
+		"let both = fn(x, x) { x }; both(1, 2)",
+		"let g = fn(x) { if (x) { 1 } }; g(false) == g(false)",
+		"let div = fn(a, b) { a / b }; div(7, 0)",
+		// The closures see the values of the later declarations.
+		"let make = fn() { let n = 0; let get = fn() { n }; let n = 5; get }; make()()",
+		"let f = fn(x) { let g = fn() { x }; let x = x + 1; g() }; f(1)",
+	}
+	for i, input := range tests {
+		prog := parser.MustParse(input)
+		// Big enough to inline the functions which may be inlined.
+		inlined := Inline(prog, 50)
+		if err := resolve.Resolve(inlined).Err(); err != nil {
+			t.Errorf("%d. Inline(%q) = %s: %v", i, input, inlined, err)
+		}
+		if got, want := run(inlined), run(prog); got != want {
+			t.Errorf("%d. %q printed %s, but inlined to %s it printed %s", i, input, want, inlined, got)
+		}
+	}
+}
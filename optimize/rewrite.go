@@ -0,0 +1,123 @@
+package optimize
+
+import (
+	"monkey/ast"
+)
+
+// rewriter copies a tree, letting a pass change it along the way.
+type rewriter struct {
+	// filter, if set, chooses which of a list of statements from the
+	// original tree are copied.
+	filter func(stmts []ast.Statement) []ast.Statement
+	// post, if set, is called on each copied expression, after its children,
+	// and returns its replacement.
+	post func(e ast.Expression) ast.Expression
+	// pre, if set, is called on each expression of the original tree, and on
+	// the names declared by let statements, before they're copied. If it
+	// returns an expression, that's used instead of the copy.
+	pre func(e ast.Expression) ast.Expression
+}
+
+func (rw *rewriter) program(prog *ast.Program) *ast.Program {
+	return &ast.Program{Statements: rw.statements(prog.Statements)}
+}
+
+func (rw *rewriter) statements(stmts []ast.Statement) []ast.Statement {
+	if rw.filter != nil {
+		stmts = rw.filter(stmts)
+	}
+	var out []ast.Statement
+	for _, s := range stmts {
+		out = append(out, rw.statement(s))
+	}
+	return out
+}
+
+func (rw *rewriter) statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		return &ast.LetStatement{Token: s.Token, Name: rw.name(s.Name), Type: s.Type, Value: rw.expression(s.Value)}
+	case *ast.ReturnStatement:
+		return &ast.ReturnStatement{Token: s.Token, ReturnValue: rw.expression(s.ReturnValue)}
+	case *ast.ExpressionStatement:
+		return &ast.ExpressionStatement{Token: s.Token, Expression: rw.expression(s.Expression)}
+	case *ast.BlockStatement:
+		return rw.block(s)
+	}
+	return s
+}
+
+func (rw *rewriter) block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return &ast.BlockStatement{Token: b.Token, Statements: rw.statements(b.Statements)}
+}
+
+// name copies the name declared by a let statement.
+func (rw *rewriter) name(id *ast.Identifier) *ast.Identifier {
+	if rw.pre != nil && id != nil {
+		if e, ok := rw.pre(id).(*ast.Identifier); ok {
+			return e
+		}
+	}
+	return copyIdent(id)
+}
+
+func copyIdent(id *ast.Identifier) *ast.Identifier {
+	if id == nil {
+		return nil
+	}
+	return &ast.Identifier{Token: id.Token, Value: id.Value, Type: id.Type}
+}
+
+func (rw *rewriter) expression(e ast.Expression) ast.Expression {
+	if rw.pre != nil && e != nil {
+		if out := rw.pre(e); out != nil {
+			return out
+		}
+	}
+	var out ast.Expression
+	switch e := e.(type) {
+	case *ast.Identifier:
+		out = copyIdent(e)
+	case *ast.IntegerLiteral:
+		out = &ast.IntegerLiteral{Token: e.Token, Value: e.Value}
+	case *ast.Boolean:
+		out = &ast.Boolean{Token: e.Token, Value: e.Value}
+	case *ast.PrefixExpression:
+		out = &ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: rw.expression(e.Right)}
+	case *ast.InfixExpression:
+		out = &ast.InfixExpression{
+			Token:    e.Token,
+			Left:     rw.expression(e.Left),
+			Operator: e.Operator,
+			Right:    rw.expression(e.Right),
+		}
+	case *ast.IfExpression:
+		out = &ast.IfExpression{
+			Token:       e.Token,
+			Condition:   rw.expression(e.Condition),
+			Consequence: rw.block(e.Consequence),
+			Alternative: rw.block(e.Alternative),
+		}
+	case *ast.FunctionLiteral:
+		fl := &ast.FunctionLiteral{Token: e.Token, ReturnType: e.ReturnType, Body: rw.block(e.Body)}
+		for _, p := range e.Parameters {
+			fl.Parameters = append(fl.Parameters, copyIdent(p))
+		}
+		out = fl
+	case *ast.CallExpression:
+		ce := &ast.CallExpression{Token: e.Token, Function: rw.expression(e.Function)}
+		for _, a := range e.Arguments {
+			ce.Arguments = append(ce.Arguments, rw.expression(a))
+		}
+		out = ce
+	default:
+		return e
+	}
+	if rw.post != nil {
+		out = rw.post(out)
+	}
+	return out
+}
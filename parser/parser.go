@@ -7,6 +7,7 @@ import (
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 type prec int
@@ -80,6 +81,17 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// MustParse parses input, and panics if it has syntax errors. It's meant for
+// programs known to be valid, such as those in tests.
+func MustParse(input string) *ast.Program {
+	p := New(lexer.New(input))
+	prog := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		panic(fmt.Sprintf("parser.MustParse(%q): %s", input, strings.Join(errs, "; ")))
+	}
+	return prog
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
@@ -128,6 +140,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	st.Name = &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if st.Type = p.parseType(); st.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -332,7 +352,18 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	params, ok := p.parseFunctionParameters()
+	if !ok {
+		return nil
+	}
+	lit.Parameters = params
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -340,27 +371,85 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, bool) {
 	// Special case: empty function parameter list
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return nil
+		return nil, true
 	}
 
-	p.nextToken()
-
-	ids := []*ast.Identifier{{Token: p.curTok, Value: p.curTok.Literal}}
-	for p.peekTokenIs(token.COMMA) {
+	var ids []*ast.Identifier
+	for {
+		if !p.peekTokenIs(token.IDENT) {
+			msg := fmt.Sprintf("expected parameter name, got token %v (%q)", p.peekTok.Type, p.peekTok.Literal)
+			p.errors = append(p.errors, msg)
+			return nil, false
+		}
 		p.nextToken()
+		id := &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if id.Type = p.parseType(); id.Type == nil {
+				return nil, false
+			}
+		}
+		ids = append(ids, id)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ids = append(ids, &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal})
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, false
 	}
 
-	return ids
+	return ids, true
+}
+
+// parseType parses a type annotation starting at the current token: a name,
+// such as int, or a function type, such as fn(int, int) -> bool.
+func (p *Parser) parseType() ast.TypeExpression {
+	switch p.curTok.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curTok, Name: p.curTok.Literal}
+	case token.FUNCTION:
+		ft := &ast.FunctionType{Token: p.curTok}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+		} else {
+			for {
+				p.nextToken()
+				param := p.parseType()
+				if param == nil {
+					return nil
+				}
+				ft.Params = append(ft.Params, param)
+				if !p.peekTokenIs(token.COMMA) {
+					break
+				}
+				p.nextToken()
+			}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		if ft.Result = p.parseType(); ft.Result == nil {
+			return nil
+		}
+		return ft
+	}
+	msg := fmt.Sprintf("expected type, got token %v (%q)", p.curTok.Type, p.curTok.Literal)
+	p.errors = append(p.errors, msg)
+	return nil
 }
 
 func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
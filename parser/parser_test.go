@@ -52,19 +52,19 @@ func TestLetStatements(t *testing.T) {
 	want := &ast.Program{
 		Statements: []ast.Statement{
 			&ast.LetStatement{
-				Token: token.Token{token.LET, "let"},
-				Name:  &ast.Identifier{Token: token.Token{token.IDENT, "x"}, Value: "x"},
-				Value: &ast.IntegerLiteral{Token: token.Token{token.INT, "5"}, Value: 5},
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+				Value: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
 			},
 			&ast.LetStatement{
-				Token: token.Token{token.LET, "let"},
-				Name:  &ast.Identifier{Token: token.Token{token.IDENT, "y"}, Value: "y"},
-				Value: &ast.IntegerLiteral{Token: token.Token{token.INT, "10"}, Value: 10},
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+				Value: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
 			},
 			&ast.LetStatement{
-				Token: token.Token{token.LET, "let"},
-				Name:  &ast.Identifier{Token: token.Token{token.IDENT, "foobar"}, Value: "foobar"},
-				Value: &ast.IntegerLiteral{Token: token.Token{token.INT, "836383"}, Value: 836383},
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "foobar"}, Value: "foobar"},
+				Value: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "836383"}, Value: 836383},
 			},
 		},
 	}
@@ -325,30 +325,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input, want string
 	}{
-		{"-a * b", "((-a) * b)"},
-		{"!-a", "(!(-a))"},
-		{"a + b + c", "((a + b) + c)"},
-		{"a + b - c", "((a + b) - c)"},
-		{"a * b * c", "((a * b) * c)"},
-		{"a * b / c", "((a * b) / c)"},
-		{"a + b / c", "(a + (b / c))"},
-		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
-		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
-		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
-		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
-		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
-		{"true", "true"},
-		{"false", "false"},
-		{"3 > 5 == false", "((3 > 5) == false)"},
-		{"3 < 5 == true", "((3 < 5) == true)"},
-		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
-		{"(5 + 5) * 2", "((5 + 5) * 2)"},
-		{"2 / (5 + 5)", "(2 / (5 + 5))"},
-		{"-(5 + 5)", "(-(5 + 5))"},
-		{"!(true == true)", "(!(true == true))"},
-		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
-		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
-		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"-a * b", "((-a) * b);"},
+		{"!-a", "(!(-a));"},
+		{"a + b + c", "((a + b) + c);"},
+		{"a + b - c", "((a + b) - c);"},
+		{"a * b * c", "((a * b) * c);"},
+		{"a * b / c", "((a * b) / c);"},
+		{"a + b / c", "(a + (b / c));"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f);"},
+		{"3 + 4; -5 * 5", "(3 + 4);((-5) * 5);"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4));"},
+		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4));"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)));"},
+		{"true", "true;"},
+		{"false", "false;"},
+		{"3 > 5 == false", "((3 > 5) == false);"},
+		{"3 < 5 == true", "((3 < 5) == true);"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4);"},
+		{"(5 + 5) * 2", "((5 + 5) * 2);"},
+		{"2 / (5 + 5)", "(2 / (5 + 5));"},
+		{"-(5 + 5)", "(-(5 + 5));"},
+		{"!(true == true)", "(!(true == true));"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d);"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)));"},
+		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g));"},
 	}
 	for i, tc := range tests {
 		p := New(lexer.New(tc.input))
@@ -598,3 +598,119 @@ func TestCallExpression(t *testing.T) {
 		t.Errorf("arg[2]: %v", err)
 	}
 }
+
+func TestParseFunctionParametersErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"fn(1, true) {};", `expected parameter name, got token INT ("1")`},
+		{"fn(x, true) {};", `expected parameter name, got token TRUE ("true")`},
+		{"fn(x,) {};", `expected parameter name, got token ) (")")`},
+		{"fn(,) {};", `expected parameter name, got token , (",")`},
+		{"fn(x y) {};", `expected token ), got token IDENT ("y")`},
+	}
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.Parse()
+		errs := p.Errors()
+		if len(errs) == 0 {
+			t.Errorf("%q: no errors, want %q", tc.input, tc.want)
+			continue
+		}
+		if got := errs[0]; got != tc.want {
+			t.Errorf("%q: first error = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let b: bool = true", "let b: bool = true;"},
+		{"let f: fn() -> int = g;", "let f: fn() -> int = g;"},
+		{"let f: fn(int, bool) -> fn(int) -> int = g;", "let f: fn(int, bool) -> fn(int) -> int = g;"},
+		{"let f: fn(fn(int) -> int) -> int = g;", "let f: fn(fn(int) -> int) -> int = g;"},
+		{"fn(a: int, b: int) -> int { a + b }", "fn(a: int, b: int) -> int {\n(a + b);\n};"},
+		{"fn(a, b: bool) { a }", "fn(a, b: bool) {\na;\n};"},
+		{"fn() -> bool { true }", "fn() -> bool {\ntrue;\n};"},
+		{"fn(f: fn(int) -> int) -> fn(int) -> int { f }", "fn(f: fn(int) -> int) -> fn(int) -> int {\nf;\n};"},
+	}
+	for i, tc := range tests {
+		p := New(lexer.New(tc.input))
+		prog := p.Parse()
+		checkParseErrors(t, p)
+		if got := prog.String(); got != tc.want {
+			t.Errorf("%d. Parse(%q).String() = %q, want %q", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestTypeAnnotationFields(t *testing.T) {
+	p := New(lexer.New("let f: fn(int) -> bool = fn(x: int) -> bool { x > 0 };"))
+	prog := p.Parse()
+	checkParseErrors(t, p)
+
+	ls := prog.Statements[0].(*ast.LetStatement)
+	ft, ok := ls.Type.(*ast.FunctionType)
+	if !ok {
+		t.Fatalf("let type = %T, want *ast.FunctionType", ls.Type)
+	}
+	if got, want := len(ft.Params), 1; got != want {
+		t.Errorf("len(ft.Params) = %d, want %d", got, want)
+	}
+	if got, want := ft.Result.String(), "bool"; got != want {
+		t.Errorf("ft.Result = %q, want %q", got, want)
+	}
+	fl := ls.Value.(*ast.FunctionLiteral)
+	if got, want := fl.Parameters[0].Type.String(), "int"; got != want {
+		t.Errorf("parameter type = %q, want %q", got, want)
+	}
+	if got, want := fl.ReturnType.String(), "bool"; got != want {
+		t.Errorf("return type = %q, want %q", got, want)
+	}
+	if got, want := fl.ReturnType.Pos().String(), "1:40"; got != want {
+		t.Errorf("return type pos = %s, want %s", got, want)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x: = 5;", `expected type, got token = ("=")`},
+		{"let x: 5 = 5;", `expected type, got token INT ("5")`},
+		{"fn(x: ) {}", `expected type, got token ) (")")`},
+		{"fn(x) -> { x }", `expected type, got token { ("{")`},
+		{"let f: fn(int) = g;", `expected token ->, got token = ("=")`},
+		{"let f: fn(int bool) -> int = g;", `expected token ), got token IDENT ("bool")`},
+	}
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.Parse()
+		errs := p.Errors()
+		if len(errs) == 0 {
+			t.Errorf("%q: no errors, want %q", tc.input, tc.want)
+			continue
+		}
+		if got := errs[0]; got != tc.want {
+			t.Errorf("%q: first error = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	if got, want := MustParse("let x = 1 + 2;").String(), "let x = (1 + 2);"; got != want {
+		t.Errorf("MustParse: got %q, want %q", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustParse of a syntax error didn't panic")
+		}
+	}()
+	MustParse("let = 1;")
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/ast/gen"
+	"monkey/lexer"
+)
+
+// TestRoundTrip checks that printing a random program and parsing the result
+// gives back the same tree.
+func TestRoundTrip(t *testing.T) {
+	for seed := int64(0); seed < 500; seed++ {
+		want := gen.New(gen.DefaultConfig, seed).Program()
+		src := want.String()
+
+		p := New(lexer.New(src))
+		got := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("seed %d: Parse(%q) errors: %v", seed, src, errs)
+			continue
+		}
+		if !ast.Equal(got, want) {
+			t.Errorf("seed %d: Parse(%q):\ngot:  %s\nwant: %s", seed, src, got, want)
+		}
+	}
+}
+
+func TestRoundTripExamples(t *testing.T) {
+	tests := []string{
+		"if (x) { y }",
+		"if (!x) { y } else { z }",
+		"if (f(x)) { 1 } else { 2 }",
+		"let f = fn(a, b) { return a + b; }; f(1, 2);",
+		"fn(x) { x }(5)",
+		"(-a)(b)",
+		"let x = 010;",
+		"-(if (true) { 1 } else { 2 } + 3)",
+		"let f: fn(int) -> fn(bool) -> int = fn(x: int) -> fn(bool) -> int { fn(b) { x } };",
+	}
+	for i, input := range tests {
+		p := New(lexer.New(input))
+		want := p.Parse()
+		checkParseErrors(t, p)
+
+		src := want.String()
+		p = New(lexer.New(src))
+		got := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%d. %q: Parse(%q) errors: %v", i, input, src, errs)
+			continue
+		}
+		if !ast.Equal(got, want) {
+			t.Errorf("%d. %q: Parse(%q):\ngot:  %s\nwant: %s", i, input, src, got, want)
+		}
+		if got, want := got.String(), src; got != want {
+			t.Errorf("%d. %q: printing is not stable: %q, want %q", i, input, got, want)
+		}
+	}
+}
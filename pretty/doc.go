@@ -0,0 +1,197 @@
+// Package pretty is a width-aware pretty printer for monkey programs.
+//
+// Programs are first turned into a Doc, a small document algebra after
+// Wadler's "A prettier printer", and then laid out so that each Group is
+// printed on a single line if it fits in the available width and broken
+// across lines otherwise.
+package pretty
+
+import (
+	"strings"
+)
+
+// Doc is a document to be laid out.
+type Doc interface {
+	doc()
+}
+
+type text string
+
+type line struct {
+	soft bool // print nothing (rather than a space) when flat
+}
+
+type hardLine struct{}
+
+type concat []Doc
+
+type nest struct {
+	indent int
+	d      Doc
+}
+
+type group struct {
+	d    Doc
+	hard bool // contains a hardLine, so can never be flat
+}
+
+func (text) doc()     {}
+func (line) doc()     {}
+func (hardLine) doc() {}
+func (concat) doc()   {}
+func (nest) doc()     {}
+func (group) doc()    {}
+
+// Text is a literal string, which must not contain newlines.
+func Text(s string) Doc { return text(s) }
+
+// Line is a newline, or a single space when its group is flat.
+func Line() Doc { return line{} }
+
+// SoftLine is a newline, or nothing when its group is flat.
+func SoftLine() Doc { return line{soft: true} }
+
+// HardLine is always a newline. Any group containing one is never flat.
+func HardLine() Doc { return hardLine{} }
+
+// Concat joins documents together.
+func Concat(ds ...Doc) Doc { return concat(ds) }
+
+// Nest increases the indentation of any lines inside d.
+func Nest(indent int, d Doc) Doc { return nest{indent, d} }
+
+// Group lays d out flat if it fits on the rest of the line.
+func Group(d Doc) Doc { return group{d, hasHardLine(d)} }
+
+// Join concatenates ds, placing sep between each.
+func Join(sep Doc, ds []Doc) Doc {
+	var out concat
+	for i, d := range ds {
+		if i > 0 {
+			out = append(out, sep)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func hasHardLine(d Doc) bool {
+	switch d := d.(type) {
+	case hardLine:
+		return true
+	case concat:
+		for _, c := range d {
+			if hasHardLine(c) {
+				return true
+			}
+		}
+	case nest:
+		return hasHardLine(d.d)
+	case group:
+		return d.hard
+	}
+	return false
+}
+
+type mode int
+
+const (
+	modeBreak mode = iota
+	modeFlat
+)
+
+// cmd is a document waiting to be laid out.
+type cmd struct {
+	indent int
+	mode   mode
+	d      Doc
+}
+
+// Render lays out d so that, where possible, no line is longer than width.
+func Render(d Doc, width int) string {
+	var out strings.Builder
+	col := 0
+	stack := []cmd{{0, modeBreak, d}}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := c.d.(type) {
+		case text:
+			out.WriteString(string(d))
+			col += len(d)
+		case line:
+			if c.mode == modeFlat {
+				if !d.soft {
+					out.WriteString(" ")
+					col++
+				}
+				continue
+			}
+			col = newline(&out, c.indent)
+		case hardLine:
+			col = newline(&out, c.indent)
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, cmd{c.indent, c.mode, d[i]})
+			}
+		case nest:
+			stack = append(stack, cmd{c.indent + d.indent, c.mode, d.d})
+		case group:
+			m := modeBreak
+			if c.mode == modeFlat || !d.hard && fits(width-col, cmd{c.indent, modeFlat, d.d}, stack) {
+				m = modeFlat
+			}
+			stack = append(stack, cmd{c.indent, m, d.d})
+		}
+	}
+	return out.String()
+}
+
+func newline(out *strings.Builder, indent int) int {
+	out.WriteString("\n")
+	out.WriteString(strings.Repeat(" ", indent))
+	return indent
+}
+
+// fits reports whether c, followed by the rest of the stack up to the next
+// newline, can be printed in the remaining width.
+func fits(width int, c cmd, rest []cmd) bool {
+	pending := []cmd{c}
+	for width >= 0 {
+		if len(pending) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			pending = append(pending, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		switch d := c.d.(type) {
+		case text:
+			width -= len(d)
+		case line:
+			if c.mode == modeBreak {
+				return true
+			}
+			if !d.soft {
+				width--
+			}
+		case hardLine:
+			return true
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				pending = append(pending, cmd{c.indent, c.mode, d[i]})
+			}
+		case nest:
+			pending = append(pending, cmd{c.indent + d.indent, c.mode, d.d})
+		case group:
+			m := c.mode
+			if d.hard {
+				m = modeBreak
+			}
+			pending = append(pending, cmd{c.indent, m, d.d})
+		}
+	}
+	return false
+}
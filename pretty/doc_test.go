@@ -0,0 +1,36 @@
+package pretty
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	args := Group(Concat(
+		Text("f("),
+		Nest(2, Concat(SoftLine(), Join(Concat(Text(","), Line()), []Doc{Text("aaa"), Text("bbb")}))),
+		SoftLine(),
+		Text(")"),
+	))
+	tests := []struct {
+		d     Doc
+		width int
+		want  string
+	}{
+		{Text("hello"), 1, "hello"},
+		{Concat(Text("a"), Line(), Text("b")), 80, "a\nb"},
+		{Group(Concat(Text("a"), Line(), Text("b"))), 80, "a b"},
+		{Group(Concat(Text("a"), SoftLine(), Text("b"))), 80, "ab"},
+		{Group(Concat(Text("a"), HardLine(), Text("b"))), 80, "a\nb"},
+		{Group(Concat(Text("a"), Nest(2, Concat(Line(), Text("b"))))), 2, "a\n  b"},
+		{args, 80, "f(aaa, bbb)"},
+		{args, 11, "f(aaa, bbb)"},
+		{args, 10, "f(\n  aaa,\n  bbb\n)"},
+		// The text following a group counts towards whether it fits.
+		{Concat(args, Text(";")), 11, "f(\n  aaa,\n  bbb\n);"},
+		// Outer groups break before inner ones.
+		{Group(Concat(Text("x"), Line(), args)), 12, "x\nf(aaa, bbb)"},
+	}
+	for i, tc := range tests {
+		if got := Render(tc.d, tc.width); got != tc.want {
+			t.Errorf("%d. Render(width=%d) = %q, want %q", i, tc.width, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package pretty
+
+import (
+	"io"
+	"strconv"
+
+	"monkey/ast"
+)
+
+// DefaultWidth is the line width used when none is given.
+const DefaultWidth = 80
+
+// indent is the indentation of nested lines.
+const indent = 2
+
+// Binding strengths of expressions, mirroring the parser's precedences.
+const (
+	precLowest = iota
+	precEquals
+	precLessGreater
+	precSum
+	precProduct
+	precPrefix
+	precCall
+	precAtom
+)
+
+var infixPrecs = map[string]int{
+	"==": precEquals,
+	"!=": precEquals,
+	"<":  precLessGreater,
+	">":  precLessGreater,
+	"+":  precSum,
+	"-":  precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+}
+
+// Format returns the source of n laid out to fit in width columns.
+// Unlike String(), it only adds the parentheses needed to preserve the tree.
+func Format(n ast.Node, width int) string {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	return Render(FromNode(n), width)
+}
+
+// Fprint writes n to w, laid out to fit in width columns, followed by a
+// newline.
+func Fprint(w io.Writer, n ast.Node, width int) error {
+	_, err := io.WriteString(w, Format(n, width)+"\n")
+	return err
+}
+
+// FromNode converts n to a document.
+func FromNode(n ast.Node) Doc {
+	switch n := n.(type) {
+	case *ast.Program:
+		return statements(n.Statements)
+	case ast.Statement:
+		return statement(n)
+	case ast.Expression:
+		return expression(n)
+	}
+	return Text("")
+}
+
+func statements(stmts []ast.Statement) Doc {
+	var ds []Doc
+	for _, s := range stmts {
+		ds = append(ds, statement(s))
+	}
+	return Join(HardLine(), ds)
+}
+
+func statement(s ast.Statement) Doc {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		return Concat(Text("let "), Text(s.Name.Value), annotation(s.Type), Text(" = "), expression(s.Value), Text(";"))
+	case *ast.ReturnStatement:
+		return Concat(Text("return "), expression(s.ReturnValue), Text(";"))
+	case *ast.ExpressionStatement:
+		return Concat(expression(s.Expression), Text(";"))
+	case *ast.BlockStatement:
+		return block(s)
+	}
+	return Text(s.String())
+}
+
+func block(b *ast.BlockStatement) Doc {
+	return Group(blockBody(b))
+}
+
+// blockBody is a block without its own group, so that it can break along
+// with its siblings.
+func blockBody(b *ast.BlockStatement) Doc {
+	if len(b.Statements) == 0 {
+		return Text("{}")
+	}
+	return Concat(
+		Text("{"),
+		Nest(indent, Concat(Line(), statements(b.Statements))),
+		Line(),
+		Text("}"),
+	)
+}
+
+// precedence is how tightly e binds when printed without parentheses.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return infixPrecs[e.Operator]
+	case *ast.PrefixExpression:
+		return precPrefix
+	case *ast.CallExpression:
+		return precCall
+	}
+	return precAtom
+}
+
+// operand prints e, wrapped in parentheses if it binds less tightly than min.
+func operand(e ast.Expression, min int) Doc {
+	if precedence(e) < min {
+		return Concat(Text("("), expression(e), Text(")"))
+	}
+	return expression(e)
+}
+
+func expression(e ast.Expression) Doc {
+	switch e := e.(type) {
+	case nil:
+		return Text("")
+	case *ast.Identifier:
+		return Text(e.Value)
+	case *ast.IntegerLiteral:
+		return Text(strconv.FormatInt(e.Value, 10))
+	case *ast.Boolean:
+		return Text(strconv.FormatBool(e.Value))
+	case *ast.PrefixExpression:
+		return Concat(Text(e.Operator), operand(e.Right, precPrefix))
+	case *ast.InfixExpression:
+		return infix(e)
+	case *ast.IfExpression:
+		// Both branches are laid out the same way.
+		d := Concat(Text("if ("), expression(e.Condition), Text(") "), blockBody(e.Consequence))
+		if e.Alternative != nil {
+			d = Concat(d, Text(" else "), blockBody(e.Alternative))
+		}
+		return Group(d)
+	case *ast.FunctionLiteral:
+		var params []Doc
+		for _, p := range e.Parameters {
+			params = append(params, Concat(Text(p.Value), annotation(p.Type)))
+		}
+		d := Concat(Text("fn"), list(params), Text(" "))
+		if e.ReturnType != nil {
+			d = Concat(d, Text("-> "+e.ReturnType.String()+" "))
+		}
+		return Concat(d, block(e.Body))
+	case *ast.CallExpression:
+		var args []Doc
+		for _, a := range e.Arguments {
+			args = append(args, expression(a))
+		}
+		return Concat(operand(e.Function, precCall), list(args))
+	}
+	return Text(e.String())
+}
+
+// annotation prints an optional type annotation after a name.
+func annotation(t ast.TypeExpression) Doc {
+	if t == nil {
+		return Text("")
+	}
+	return Text(": " + t.String())
+}
+
+// list is a parenthesised, comma separated list which puts each item on its
+// own line if they don't all fit.
+func list(items []Doc) Doc {
+	if len(items) == 0 {
+		return Text("()")
+	}
+	return Group(Concat(
+		Text("("),
+		Nest(indent, Concat(SoftLine(), Join(Concat(Text(","), Line()), items))),
+		SoftLine(),
+		Text(")"),
+	))
+}
+
+// infix prints a chain of left-associative operators of the same precedence,
+// such as a + b - c, breaking after the operators if it doesn't fit.
+func infix(e *ast.InfixExpression) Doc {
+	prec := infixPrecs[e.Operator]
+	var rest []Doc
+	for {
+		rest = append(rest, Concat(Text(" "+e.Operator), Line(), operand(e.Right, prec+1)))
+		left, ok := e.Left.(*ast.InfixExpression)
+		if !ok || infixPrecs[left.Operator] != prec {
+			break
+		}
+		e = left
+	}
+	first := operand(e.Left, prec)
+	for i, j := 0, len(rest)-1; i < j; i, j = i+1, j-1 {
+		rest[i], rest[j] = rest[j], rest[i]
+	}
+	return Group(Concat(first, Nest(indent, Concat(rest...))))
+}
@@ -0,0 +1,141 @@
+package pretty
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		width int
+		want  []string
+	}{
+		{
+			input: "1 + 2 * 3",
+			width: 80,
+			want:  []string{"1 + 2 * 3;"},
+		},
+		{
+			input: "(1 + 2) * 3 - (4 - 5)",
+			width: 80,
+			want:  []string{"(1 + 2) * 3 - (4 - 5);"},
+		},
+		{
+			input: "-(a + b); !-a; (-f)(x); -f(x)",
+			width: 80,
+			want:  []string{"-(a + b);", "!-a;", "(-f)(x);", "-f(x);"},
+		},
+		{
+			input: "let add = fn(x, y) { x + y; };",
+			width: 80,
+			want:  []string{"let add = fn(x, y) { x + y; };"},
+		},
+		{
+			input: "let add = fn(x, y) { let z = x + y; z };",
+			width: 80,
+			want: []string{
+				"let add = fn(x, y) {",
+				"  let z = x + y;",
+				"  z;",
+				"};",
+			},
+		},
+		{
+			input: "if (x < y) { x } else { y }",
+			width: 80,
+			want:  []string{"if (x < y) { x; } else { y; };"},
+		},
+		{
+			input: "if (x < y) { x } else { y }",
+			width: 20,
+			want: []string{
+				"if (x < y) {",
+				"  x;",
+				"} else {",
+				"  y;",
+				"};",
+			},
+		},
+		{
+			input: "fn() {}",
+			width: 80,
+			want:  []string{"fn() {};"},
+		},
+		{
+			input: "compute(alpha, beta, gamma(delta, epsilon))",
+			width: 30,
+			want: []string{
+				"compute(",
+				"  alpha,",
+				"  beta,",
+				"  gamma(delta, epsilon)",
+				");",
+			},
+		},
+		{
+			input: "first + second + third * fourth - fifth",
+			width: 20,
+			want: []string{
+				"first +",
+				"  second +",
+				"  third * fourth -",
+				"  fifth;",
+			},
+		},
+		{
+			input: "fn(alpha, beta, gamma) { alpha }",
+			width: 20,
+			want: []string{
+				"fn(",
+				"  alpha,",
+				"  beta,",
+				"  gamma",
+				") { alpha; };",
+			},
+		},
+		{
+			input: "let add: fn(int, int) -> int = fn(x: int, y: int) -> int { x + y }",
+			width: 80,
+			want:  []string{"let add: fn(int, int) -> int = fn(x: int, y: int) -> int { x + y; };"},
+		},
+		{
+			input: "fn(x: int, y: bool) -> int { x }",
+			width: 20,
+			want: []string{
+				"fn(",
+				"  x: int,",
+				"  y: bool",
+				") -> int { x; };",
+			},
+		},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		want := strings.Join(tc.want, "\n")
+		if got := Format(prog, tc.width); got != want {
+			t.Errorf("%d. Format(%q, %d) =\n%s\nwant:\n%s", i, tc.input, tc.width, got, want)
+		}
+	}
+}
+
+func TestFormatReparses(t *testing.T) {
+	inputs := []string{
+		"let f = fn(a, b) { if (a > b) { return a - b; } else { return f(b, a); } }; f(10, 3);",
+		"a - (b - c); a / (b * c); (a == b) == c; a == (b == c); a < b == c > d",
+		"fn(x) { x }(5); if (c) { f } else { g }(1); -if (c) { 1 } else { 2 } + 3",
+		"outer(fn(x) { inner(x, fn(y) { x + y * (x - y) }) }, if (t) { 1 } else { 2 })",
+		"let f: fn(fn(int) -> bool) -> int = fn(g: fn(int) -> bool, n: int) -> int { n };",
+	}
+	for _, input := range inputs {
+		want := parser.MustParse(input).String()
+		for _, width := range []int{1, 10, 40, 80} {
+			src := Format(parser.MustParse(input), width)
+			if got := parser.MustParse(src).String(); got != want {
+				t.Errorf("Format(%q, %d) = %q, reparses as %q, want %q", input, width, src, got, want)
+			}
+		}
+	}
+}
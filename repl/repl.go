@@ -5,13 +5,26 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/parser"
+	"monkey/pretty"
 )
 
 var Prompt = ">> "
 
+// Width is the line width used by the :fmt command.
+var Width = pretty.DefaultWidth
+
+// metaCommands are run by starting a line with a colon and their name,
+// e.g. ":fmt let x = 1;". They are passed the rest of the line.
+var metaCommands = map[string]func(out io.Writer, arg string){
+	"dot": dotCommand,
+	"fmt": fmtCommand,
+}
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	for {
@@ -22,10 +35,12 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		p := parser.New(lexer.New(line))
-		prog := p.Parse()
-		if len(p.Errors()) > 0 {
-			printParserErrors(out, p.Errors())
+		if strings.HasPrefix(line, ":") {
+			runMetaCommand(out, line[1:])
+			continue
+		}
+		prog, ok := parse(out, line)
+		if !ok {
 			continue
 		}
 		io.WriteString(out, prog.String())
@@ -33,6 +48,43 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+func runMetaCommand(out io.Writer, line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	cmd, ok := metaCommands[name]
+	if !ok {
+		fmt.Fprintf(out, "\tunknown command :%s\n", name)
+		return
+	}
+	cmd(out, arg)
+}
+
+func fmtCommand(out io.Writer, arg string) {
+	prog, ok := parse(out, arg)
+	if !ok {
+		return
+	}
+	pretty.Fprint(out, prog, Width)
+}
+
+func dotCommand(out io.Writer, arg string) {
+	prog, ok := parse(out, arg)
+	if !ok {
+		return
+	}
+	ast.WriteDOT(out, prog)
+}
+
+// parse parses line, printing any errors to out.
+func parse(out io.Writer, line string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(line))
+	prog := p.Parse()
+	if len(p.Errors()) > 0 {
+		printParserErrors(out, p.Errors())
+		return nil, false
+	}
+	return prog, true
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		fmt.Fprintf(out, "\t%s\n", msg)
@@ -27,6 +27,38 @@ func TestRepl(t *testing.T) {
 			input: "let y 5 9;",
 			wantLines: []string{`	expected token =, got token INT ("5")`},
 		},
+		{
+			input: ":fmt let add = fn(x, y) { let z = x + y; z };",
+			wantLines: []string{
+				"let add = fn(x, y) {",
+				"  let z = x + y;",
+				"  z;",
+				"};",
+			},
+		},
+		{
+			input:     ":fmt let y 5;",
+			wantLines: []string{`	expected token =, got token INT ("5")`},
+		},
+		{
+			input: ":dot -x",
+			wantLines: []string{
+				"digraph ast {",
+				"\tnode [shape=box];",
+				`	n0 [label="Program"];`,
+				`	n1 [label="ExpressionStatement"];`,
+				`	n2 [label="PrefixExpression\n-"];`,
+				`	n3 [label="Identifier\nx"];`,
+				`	n2 -> n3 [label="Right"];`,
+				`	n1 -> n2 [label="Expression"];`,
+				`	n0 -> n1 [label="Statements[0]"];`,
+				"}",
+			},
+		},
+		{
+			input:     ":nope",
+			wantLines: []string{"\tunknown command :nope"},
+		},
 	}
 	for i, tc := range tests {
 		in := strings.NewReader(tc.input)
@@ -0,0 +1,423 @@
+// Package resolve links each use of a name in a monkey program to the let
+// statement or function parameter which declares it.
+//
+// The program, each function literal and each branch of an if expression
+// introduce a scope. A function's parameters and the let statements in its
+// body share a scope. Giving the branches of an if expression scopes of their
+// own is a choice of this package, which the compilers follow: a let
+// statement in a branch isn't visible after the if expression. A name is
+// bound by a let statement only once its value has been computed, so it's
+// visible to the statements which follow it. Uses inside a function body
+// aren't evaluated until the function is called, so they may also refer to
+// names declared later in an enclosing scope, which is how recursive
+// functions refer to themselves.
+package resolve
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/token"
+)
+
+// DeclKind says how a name was declared.
+type DeclKind int
+
+const (
+	Let DeclKind = iota
+	Param
+)
+
+func (k DeclKind) String() string {
+	switch k {
+	case Let:
+		return "let"
+	case Param:
+		return "parameter"
+	}
+	return fmt.Sprintf("DeclKind(%d)", int(k))
+}
+
+// Decl is a single declaration of a name.
+type Decl struct {
+	Name  *ast.Identifier
+	Kind  DeclKind
+	Scope *Scope
+	// Let is the declaring statement, for Let declarations.
+	Let *ast.LetStatement
+	// Uses are the identifiers which refer to this declaration.
+	Uses []*ast.Identifier
+}
+
+// Scope is a region of the program in which names are declared.
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	// Node is the *ast.Program, *ast.FunctionLiteral or *ast.BlockStatement
+	// which introduces the scope.
+	Node ast.Node
+	// Decls are the declarations in the scope, in source order. A name may be
+	// declared more than once in the same scope.
+	Decls []*Decl
+
+	bound int // number of Decls visible while resolving
+}
+
+// IsFunction reports whether s is the scope of a function literal.
+func (s *Scope) IsFunction() bool {
+	_, ok := s.Node.(*ast.FunctionLiteral)
+	return ok
+}
+
+// Function returns the scope of the function literal s is in, or the
+// program's scope if it isn't in one.
+func (s *Scope) Function() *Scope {
+	for ; s.Parent != nil && !s.IsFunction(); s = s.Parent {
+	}
+	return s
+}
+
+// Statements returns the statements of the program, function body or block
+// which introduces s, in which its let statements are.
+func (s *Scope) Statements() []ast.Statement {
+	switch n := s.Node.(type) {
+	case *ast.Program:
+		return n.Statements
+	case *ast.FunctionLiteral:
+		if n.Body != nil {
+			return n.Body.Statements
+		}
+	case *ast.BlockStatement:
+		return n.Statements
+	}
+	return nil
+}
+
+// Variables returns the declarations in s grouped by name, in the order the
+// names are first declared. The declarations of a name in a single scope
+// share one variable: a let statement which declares the name again
+// overwrites the value the earlier one bound, so functions which captured the
+// name see the new value.
+func (s *Scope) Variables() [][]*Decl {
+	var vars [][]*Decl
+	index := make(map[string]int)
+	for _, d := range s.Decls {
+		i, ok := index[d.Name.Value]
+		if !ok {
+			i = len(vars)
+			index[d.Name.Value] = i
+			vars = append(vars, nil)
+		}
+		vars[i] = append(vars[i], d)
+	}
+	return vars
+}
+
+// Lookup returns the last declaration of name in s or its parents, or nil.
+func (s *Scope) Lookup(name string) *Decl {
+	for ; s != nil; s = s.Parent {
+		for i := len(s.Decls) - 1; i >= 0; i-- {
+			if d := s.Decls[i]; d.Name.Value == name {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// visible returns the latest declaration of name which has been bound.
+func (s *Scope) visible(name string) *Decl {
+	for i := s.bound - 1; i >= 0; i-- {
+		if d := s.Decls[i]; d.Name.Value == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// later returns the next declaration of name which hasn't been bound yet.
+func (s *Scope) later(name string) *Decl {
+	for _, d := range s.Decls[s.bound:] {
+		if d.Name.Value == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Code classifies a Diagnostic.
+type Code int
+
+const (
+	// Undefined is a use of a name which is never declared.
+	Undefined Code = iota
+	// Shadowed is a declaration which hides one in an enclosing scope.
+	Shadowed
+	// UseBeforeDef is a use of a name before the declaration it refers to.
+	UseBeforeDef
+	// DuplicateParam is a function parameter with the same name as an
+	// earlier one.
+	DuplicateParam
+)
+
+func (c Code) String() string {
+	switch c {
+	case Undefined:
+		return "undefined"
+	case Shadowed:
+		return "shadowed"
+	case UseBeforeDef:
+		return "use-before-definition"
+	case DuplicateParam:
+		return "duplicate-parameter"
+	}
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// Diagnostic is a problem found while resolving.
+type Diagnostic struct {
+	Pos token.Pos
+	// Ident is the use or declaration the diagnostic is about.
+	Ident *ast.Identifier
+	Code  Code
+	Msg   string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %s", d.Pos, d.Msg)
+}
+
+func (d Diagnostic) Error() string { return d.String() }
+
+// IsError reports whether the diagnostic is for a name which has no value
+// when it's evaluated, so the program would fail if it ran. Backends refuse
+// to translate such programs.
+func (d Diagnostic) IsError() bool {
+	return d.Code == Undefined || d.Code == UseBeforeDef
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	// Scope is the top-level scope of the program.
+	Scope *Scope
+	// Scopes maps the nodes which introduce a scope to it. The body of a
+	// function literal has no scope of its own.
+	Scopes map[ast.Node]*Scope
+	// Defs maps each declaring identifier to its declaration.
+	Defs map[*ast.Identifier]*Decl
+	// Uses maps each resolved identifier to its declaration. Undefined names
+	// are missing.
+	Uses map[*ast.Identifier]*Decl
+	// Diagnostics are the problems found, in source order.
+	Diagnostics []Diagnostic
+}
+
+// Err returns the first diagnostic which is an error, or nil.
+func (info *Info) Err() error {
+	for _, d := range info.Diagnostics {
+		if d.IsError() {
+			return d
+		}
+	}
+	return nil
+}
+
+// Resolve builds the scopes of prog and resolves every identifier in it.
+func Resolve(prog *ast.Program) *Info {
+	r := &resolver{
+		info: &Info{
+			Scopes: make(map[ast.Node]*Scope),
+			Defs:   make(map[*ast.Identifier]*Decl),
+			Uses:   make(map[*ast.Identifier]*Decl),
+		},
+		initializing: make(map[*Decl]bool),
+	}
+	r.info.Scope = r.openScope(prog, nil, prog.Statements)
+	r.statements(prog.Statements)
+	sort.SliceStable(r.info.Diagnostics, func(i, j int) bool {
+		return r.info.Diagnostics[i].Pos.Before(r.info.Diagnostics[j].Pos)
+	})
+	return r.info
+}
+
+type resolver struct {
+	info  *Info
+	scope *Scope
+	// initializing are the let declarations whose values are being resolved.
+	initializing map[*Decl]bool
+}
+
+func (r *resolver) errorf(id *ast.Identifier, code Code, format string, args ...interface{}) {
+	r.info.Diagnostics = append(r.info.Diagnostics, Diagnostic{
+		Pos:   id.Pos(),
+		Ident: id,
+		Code:  code,
+		Msg:   fmt.Sprintf(format, args...),
+	})
+}
+
+// openScope makes a new scope inside the current one and enters it. The let
+// statements among stmts are declared up front, so that forward references
+// can be found.
+func (r *resolver) openScope(n ast.Node, params []*ast.Identifier, stmts []ast.Statement) *Scope {
+	s := &Scope{Parent: r.scope, Node: n}
+	if r.scope != nil {
+		r.scope.Children = append(r.scope.Children, s)
+	}
+	r.info.Scopes[n] = s
+	r.scope = s
+
+	for _, p := range params {
+		for _, d := range s.Decls {
+			if d.Name.Value == p.Value {
+				r.errorf(p, DuplicateParam, "duplicate parameter %s, first declared at %v", p.Value, d.Name.Pos())
+				break
+			}
+		}
+		r.declare(p, Param, nil)
+	}
+	for _, p := range params {
+		r.bind(r.info.Defs[p])
+	}
+	for _, st := range stmts {
+		if ls, ok := st.(*ast.LetStatement); ok && ls.Name != nil {
+			r.declare(ls.Name, Let, ls)
+		}
+	}
+	return s
+}
+
+func (r *resolver) closeScope() {
+	r.scope = r.scope.Parent
+}
+
+func (r *resolver) declare(id *ast.Identifier, kind DeclKind, ls *ast.LetStatement) {
+	d := &Decl{Name: id, Kind: kind, Scope: r.scope, Let: ls}
+	r.scope.Decls = append(r.scope.Decls, d)
+	r.info.Defs[id] = d
+}
+
+// bind makes d, the next declaration in its scope, visible.
+func (r *resolver) bind(d *Decl) {
+	s := d.Scope
+	s.bound++
+	for p := s.Parent; p != nil; p = p.Parent {
+		outer := p.visible(d.Name.Value)
+		if outer == nil {
+			outer = p.later(d.Name.Value)
+		}
+		if outer != nil {
+			r.errorf(d.Name, Shadowed, "%s %s shadows declaration at %v", d.Kind, d.Name.Value, outer.Name.Pos())
+			return
+		}
+	}
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, st := range stmts {
+		r.statement(st)
+	}
+}
+
+func (r *resolver) statement(st ast.Statement) {
+	switch st := st.(type) {
+	case *ast.LetStatement:
+		d := r.info.Defs[st.Name]
+		if d == nil {
+			r.expression(st.Value)
+			return
+		}
+		r.initializing[d] = true
+		r.expression(st.Value)
+		delete(r.initializing, d)
+		r.bind(d)
+	case *ast.ReturnStatement:
+		r.expression(st.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(st.Expression)
+	case *ast.BlockStatement:
+		r.block(st)
+	}
+}
+
+func (r *resolver) block(b *ast.BlockStatement) {
+	if b == nil {
+		return
+	}
+	r.openScope(b, nil, b.Statements)
+	r.statements(b.Statements)
+	r.closeScope()
+}
+
+func (r *resolver) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		r.use(e)
+	case *ast.PrefixExpression:
+		r.expression(e.Right)
+	case *ast.InfixExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.IfExpression:
+		r.expression(e.Condition)
+		r.block(e.Consequence)
+		r.block(e.Alternative)
+	case *ast.FunctionLiteral:
+		var stmts []ast.Statement
+		if e.Body != nil {
+			stmts = e.Body.Statements
+		}
+		r.openScope(e, e.Parameters, stmts)
+		r.statements(stmts)
+		r.closeScope()
+	case *ast.CallExpression:
+		r.expression(e.Function)
+		for _, a := range e.Arguments {
+			r.expression(a)
+		}
+	}
+}
+
+// use resolves an identifier to the declaration it refers to when evaluated.
+func (r *resolver) use(id *ast.Identifier) {
+	name := id.Value
+	var later *Decl        // nearest declaration which isn't bound yet
+	var laterDeferred bool // whether id is inside a function nested in later's scope
+	deferred := false
+	for s := r.scope; s != nil; s = s.Parent {
+		if d := s.visible(name); d != nil {
+			switch {
+			case later != nil && laterDeferred:
+				r.link(id, later)
+			case later != nil && !r.initializing[later]:
+				r.errorf(id, UseBeforeDef, "%s used before its declaration at %v", name, later.Name.Pos())
+				r.link(id, d)
+			default:
+				r.link(id, d)
+			}
+			return
+		}
+		if later == nil {
+			if later = s.later(name); later != nil {
+				laterDeferred = deferred
+			}
+		}
+		if s.IsFunction() {
+			deferred = true
+		}
+	}
+	if later == nil {
+		r.errorf(id, Undefined, "undefined: %s", name)
+		return
+	}
+	if !laterDeferred {
+		r.errorf(id, UseBeforeDef, "%s used before its declaration at %v", name, later.Name.Pos())
+	}
+	r.link(id, later)
+}
+
+func (r *resolver) link(id *ast.Identifier, d *Decl) {
+	r.info.Uses[id] = d
+	d.Uses = append(d.Uses, id)
+}
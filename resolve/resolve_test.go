@@ -0,0 +1,290 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/parser"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			input: "let x = 1; x;",
+		},
+		{
+			input: "y;",
+			want:  []string{"1:1: undefined: y"},
+		},
+		{
+			input: "let x = 1;\nlet f = fn(x) { x };",
+			want:  []string{"2:12: parameter x shadows declaration at 1:5"},
+		},
+		{
+			input: "let f = fn(a) { let g = fn(b) { let a = b; a }; g(a) };",
+			want:  []string{"1:37: let a shadows declaration at 1:12"},
+		},
+		{
+			input: "x;\nlet x = 1;",
+			want:  []string{"1:1: x used before its declaration at 2:5"},
+		},
+		{
+			input: "let x = x + 1;",
+			want:  []string{"1:9: x used before its declaration at 1:5"},
+		},
+		{
+			// Refers to the outer x, which is what was meant.
+			input: "let x = 1;\nlet f = fn() { let x = x + 1; x };",
+			want:  []string{"2:20: let x shadows declaration at 1:5"},
+		},
+		{
+			// Refers to the outer x, but looks like it means the inner one.
+			input: "let x = 1;\nlet f = fn() { let y = x; let x = 2; y };",
+			want: []string{
+				"2:24: x used before its declaration at 2:31",
+				"2:31: let x shadows declaration at 1:5",
+			},
+		},
+		{
+			input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };",
+		},
+		{
+			input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };\nlet odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };",
+		},
+		{
+			input: "let f = fn() { g() };\nf();\nlet g = fn() { 1 };",
+		},
+		{
+			input: "let c = true;\nif (c) { let y = 1; y } else { y };\ny;",
+			want: []string{
+				"2:32: undefined: y",
+				"3:1: undefined: y",
+			},
+		},
+		{
+			input: "let x = 1; let x = x + 1; x;",
+		},
+		{
+			input: "fn(x, x) { x }",
+			want:  []string{"1:7: duplicate parameter x, first declared at 1:4"},
+		},
+		{
+			input: "fn(a, b, a, b, a) { a + b }",
+			want: []string{
+				"1:10: duplicate parameter a, first declared at 1:4",
+				"1:13: duplicate parameter b, first declared at 1:7",
+				"1:16: duplicate parameter a, first declared at 1:4",
+			},
+		},
+		{
+			input: "fn(a, b) { a + b + c }",
+			want:  []string{"1:20: undefined: c"},
+		},
+	}
+	for i, tc := range tests {
+		info := Resolve(parser.MustParse(tc.input))
+		var got []string
+		for _, d := range info.Diagnostics {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%d. Resolve(%q) diagnostics =\n%s\nwant:\n%s", i, tc.input, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
+
+func TestErr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x = 1; x;", ""},
+		// Shadowing and duplicate parameters don't stop a program running.
+		{"let x = 1; let f = fn(x, x) { x };", ""},
+		{"let x = 1; let f = fn(x) { x }; y; z;", "1:33: undefined: y"},
+		{"let f = fn(x, x) { x }; x;\nlet x = 1;", "1:25: x used before its declaration at 2:5"},
+	}
+	for i, tc := range tests {
+		got := ""
+		if err := Resolve(parser.MustParse(tc.input)).Err(); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Resolve(%q).Err() = %q, want %q", i, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	tests := []struct {
+		input string
+		// want maps the position of each use to the position of its declaration.
+		want map[string]string
+	}{
+		{
+			input: "let x = 1; x;",
+			want:  map[string]string{"1:12": "1:5"},
+		},
+		{
+			input: "let x = 1; let x = x + 1; x;",
+			want:  map[string]string{"1:20": "1:5", "1:27": "1:16"},
+		},
+		{
+			input: "let x = 1; fn(x) { x }; x",
+			want:  map[string]string{"1:20": "1:15", "1:25": "1:5"},
+		},
+		{
+			input: "let f = fn(n) { f(n) };",
+			want:  map[string]string{"1:17": "1:5", "1:19": "1:12"},
+		},
+		{
+			// The call happens after the second declaration of x.
+			input: "let x = 1; let f = fn() { x }; let x = 2;",
+			want:  map[string]string{"1:27": "1:5"},
+		},
+		{
+			input: "let f = fn() { let g = fn() { x }; let x = 2; g() }; let x = 1;",
+			want:  map[string]string{"1:31": "1:40", "1:47": "1:20"},
+		},
+	}
+	for i, tc := range tests {
+		info := Resolve(parser.MustParse(tc.input))
+		got := make(map[string]string)
+		for id, d := range info.Uses {
+			got[id.Pos().String()] = d.Name.Pos().String()
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%d. Resolve(%q) uses = %v, want %v", i, tc.input, got, tc.want)
+			continue
+		}
+		for use, decl := range tc.want {
+			if got[use] != decl {
+				t.Errorf("%d. Resolve(%q) use at %s refers to %s, want %s", i, tc.input, use, got[use], decl)
+			}
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	prog := parser.MustParse("let a = 1; let f = fn(b) { let c = if (b) { let d = 2; d } else { 3 }; c };")
+	info := Resolve(prog)
+	if len(info.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", info.Diagnostics)
+	}
+
+	top := info.Scope
+	if top.Node != prog || top.Parent != nil {
+		t.Fatalf("top scope is for %T with parent %v, want *ast.Program with no parent", top.Node, top.Parent)
+	}
+	if got, want := declNames(top), "a f"; got != want {
+		t.Errorf("top scope declares %q, want %q", got, want)
+	}
+	if got, want := len(top.Children), 1; got != want {
+		t.Fatalf("top scope has %d children, want %d", got, want)
+	}
+
+	fn := top.Children[0]
+	if !fn.IsFunction() {
+		t.Errorf("function scope is for a %T", fn.Node)
+	}
+	if got, want := declNames(fn), "b c"; got != want {
+		t.Errorf("function scope declares %q, want %q", got, want)
+	}
+	if got, want := len(fn.Children), 2; got != want {
+		t.Fatalf("function scope has %d children, want %d", got, want)
+	}
+	if got, want := declNames(fn.Children[0]), "d"; got != want {
+		t.Errorf("consequence scope declares %q, want %q", got, want)
+	}
+	if got, want := declNames(fn.Children[1]), ""; got != want {
+		t.Errorf("alternative scope declares %q, want %q", got, want)
+	}
+
+	if d := fn.Children[0].Lookup("a"); d == nil || d.Scope != top || d.Kind != Let {
+		t.Errorf("Lookup(a) = %+v, want the top level let", d)
+	}
+	if d := fn.Children[0].Lookup("b"); d == nil || d.Scope != fn || d.Kind != Param {
+		t.Errorf("Lookup(b) = %+v, want the function's parameter", d)
+	}
+	if d := top.Lookup("d"); d != nil {
+		t.Errorf("Lookup(d) = %+v, want nil", d)
+	}
+	if got, want := len(top.Decls[0].Uses), 0; got != want {
+		t.Errorf("a has %d uses, want %d", got, want)
+	}
+
+	for _, s := range []*Scope{top, fn, fn.Children[0], fn.Children[1]} {
+		if got := s.Statements(); len(got) == 0 || got[len(got)-1] != lastStatement(s.Node) {
+			t.Errorf("Statements() of the scope of %T are %v", s.Node, got)
+		}
+	}
+
+	if got := fn.Children[0].Function(); got != fn {
+		t.Errorf("consequence scope's Function() is for a %T, want the function", got.Node)
+	}
+	if got := top.Function(); got != top {
+		t.Errorf("top scope's Function() is for a %T, want the program", got.Node)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let a = 1; let b = 2;", "a, b"},
+		{"let a = 1; let b = 2; let a = a + b; let c = 3; let b = 4;", "a a, b b, c"},
+		{"let f = fn(x, y) { let y = x; let x = 1; x };", "f"},
+	}
+	for i, tc := range tests {
+		info := Resolve(parser.MustParse(tc.input))
+		var got []string
+		for _, decls := range info.Scope.Variables() {
+			var names []string
+			for _, d := range decls {
+				names = append(names, d.Name.Value)
+			}
+			got = append(got, strings.Join(names, " "))
+		}
+		if got := strings.Join(got, ", "); got != tc.want {
+			t.Errorf("%d. Variables() in %q = %q, want %q", i, tc.input, got, tc.want)
+		}
+	}
+
+	// Parameters and let statements in a function share its scope.
+	prog := parser.MustParse("let f = fn(x, y) { let y = x; let x = 1; x };")
+	fn := Resolve(prog).Scope.Children[0]
+	var got []string
+	for _, decls := range fn.Variables() {
+		got = append(got, fmt.Sprintf("%s:%d", decls[0].Name.Value, len(decls)))
+	}
+	if got, want := strings.Join(got, " "), "x:2 y:2"; got != want {
+		t.Errorf("function Variables() = %q, want %q", got, want)
+	}
+}
+
+func declNames(s *Scope) string {
+	var names []string
+	for _, d := range s.Decls {
+		names = append(names, d.Name.Value)
+	}
+	return strings.Join(names, " ")
+}
+
+func lastStatement(n ast.Node) ast.Statement {
+	var stmts []ast.Statement
+	switch n := n.(type) {
+	case *ast.Program:
+		stmts = n.Statements
+	case *ast.FunctionLiteral:
+		stmts = n.Body.Statements
+	case *ast.BlockStatement:
+		stmts = n.Statements
+	}
+	return stmts[len(stmts)-1]
+}
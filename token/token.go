@@ -1,11 +1,35 @@
 // Package token represents all the possible tokens that the lexer can use.
 package token
 
+import "fmt"
+
 type Type string
 
 type Token struct {
 	Type    Type
 	Literal string
+	Pos     Pos
+}
+
+// Pos is a position in the input. Lines and columns count from 1; the zero
+// Pos is used for tokens which didn't come from the lexer.
+type Pos struct {
+	Line, Col int
+}
+
+// IsValid reports whether p is a real position.
+func (p Pos) IsValid() bool { return p.Line > 0 }
+
+// Before reports whether p comes before q in the input.
+func (p Pos) Before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
 }
 
 const (
@@ -27,10 +51,12 @@ const (
 	GT       Type = ">"
 	EQ       Type = "=="
 	NE       Type = "!="
+	ARROW    Type = "->"
 
 	// DELIMITERS
 	COMMA     Type = ","
 	SEMICOLON Type = ";"
+	COLON     Type = ":"
 
 	LPAREN Type = "("
 	RPAREN Type = ")"
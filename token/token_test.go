@@ -22,3 +22,37 @@ func TestLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestPosString(t *testing.T) {
+	tests := []struct {
+		pos  Pos
+		want string
+	}{
+		{Pos{}, "-"},
+		{Pos{Line: 1, Col: 1}, "1:1"},
+		{Pos{Line: 12, Col: 34}, "12:34"},
+	}
+	for i, tc := range tests {
+		if got := tc.pos.String(); got != tc.want {
+			t.Errorf("%d. %#v.String() = %q, want %q", i, tc.pos, got, tc.want)
+		}
+	}
+}
+
+func TestPosBefore(t *testing.T) {
+	tests := []struct {
+		p, q Pos
+		want bool
+	}{
+		{Pos{Line: 1, Col: 2}, Pos{Line: 1, Col: 3}, true},
+		{Pos{Line: 1, Col: 3}, Pos{Line: 1, Col: 2}, false},
+		{Pos{Line: 1, Col: 9}, Pos{Line: 2, Col: 1}, true},
+		{Pos{Line: 2, Col: 1}, Pos{Line: 1, Col: 9}, false},
+		{Pos{Line: 1, Col: 1}, Pos{Line: 1, Col: 1}, false},
+	}
+	for i, tc := range tests {
+		if got := tc.p.Before(tc.q); got != tc.want {
+			t.Errorf("%d. %v.Before(%v) = %v, want %v", i, tc.p, tc.q, got, tc.want)
+		}
+	}
+}
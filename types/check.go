@@ -0,0 +1,390 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+)
+
+// Error is a type error.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+// Info is the result of checking a program.
+type Info struct {
+	// Types maps every expression to its type.
+	Types map[ast.Expression]Type
+	// Defs maps the identifiers declared by let statements and function
+	// parameters to their types. Parameters are never polymorphic.
+	Defs map[*ast.Identifier]*Scheme
+	// Errors are the type errors found, in source order.
+	Errors []Error
+}
+
+// Check infers the type of every expression in prog.
+//
+// Names are resolved as by package resolve; undefined names are given a type
+// which fits wherever they are used, and are left to resolve to report. A
+// block which doesn't end with an expression, such as a missing else branch,
+// evaluates to null, which is allowed to stand for any type.
+//
+// Every value except false and null counts as true, so a condition or the
+// operand of ! may have any type.
+//
+// A function which is used before it is declared, as in mutually recursive
+// functions, isn't polymorphic.
+//
+// Type annotations constrain the inferred types: the types they name must be
+// int, bool or functions of them.
+func Check(prog *ast.Program) *Info {
+	c := &checker{
+		res: resolve.Resolve(prog),
+		info: &Info{
+			Types: make(map[ast.Expression]Type),
+			Defs:  make(map[*ast.Identifier]*Scheme),
+		},
+		bindings: make(map[*resolve.Decl]*binding),
+	}
+	c.statements(prog.Statements, c.fresh())
+
+	for e, t := range c.info.Types {
+		c.info.Types[e] = Resolve(t)
+	}
+	for d, b := range c.bindings {
+		c.info.Defs[d.Name] = &Scheme{Vars: b.vars, Type: Resolve(b.t)}
+	}
+	sort.SliceStable(c.info.Errors, func(i, j int) bool {
+		return c.info.Errors[i].Pos.Before(c.info.Errors[j].Pos)
+	})
+	return c.info
+}
+
+// binding is the type of a declared name.
+type binding struct {
+	t Type
+	// vars are the generic variables in t, which are replaced by new ones
+	// each time the name is used.
+	vars []*Var
+	// forward is set if the name was used before its declaration was seen.
+	forward bool
+}
+
+type checker struct {
+	res      *resolve.Info
+	info     *Info
+	bindings map[*resolve.Decl]*binding
+	// nonGeneric are the types of names which are being defined, such as
+	// parameters of the enclosing functions. Their variables mustn't be
+	// generalised.
+	nonGeneric []Type
+	// forward are the types of names used before their declaration, which
+	// are never generalised.
+	forward []Type
+	nextVar int
+}
+
+func (c *checker) fresh() *Var {
+	c.nextVar++
+	return &Var{id: c.nextVar}
+}
+
+func (c *checker) errorf(pos token.Pos, format string, args ...interface{}) {
+	c.info.Errors = append(c.info.Errors, Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// expect requires e, whose type is got, to have type want.
+func (c *checker) expect(e ast.Expression, got, want Type, context string) {
+	if err := unify(got, want); err != nil {
+		n := newNamer()
+		msg := fmt.Sprintf("cannot use %v (type %s) as type %s in %s", e, typeString(got, n), typeString(want, n), context)
+		if err.Error() == "infinite type" {
+			msg += " (infinite type)"
+		}
+		c.errorf(e.Pos(), "%s", msg)
+	}
+}
+
+// generalize makes a binding for t, quantifying the variables which don't
+// appear in the types being defined.
+func (c *checker) generalize(t Type) *binding {
+	fixed := make(map[*Var]bool)
+	for _, ng := range c.nonGeneric {
+		freeVars(ng, fixed, nil)
+	}
+	for _, fw := range c.forward {
+		freeVars(fw, fixed, nil)
+	}
+	vars := freeVars(t, fixed, nil)
+	return &binding{t: t, vars: vars}
+}
+
+// instantiate returns the type of b with new variables for its generic ones.
+func (c *checker) instantiate(b *binding) Type {
+	if len(b.vars) == 0 {
+		return b.t
+	}
+	subst := make(map[*Var]Type)
+	for _, v := range b.vars {
+		subst[v] = c.fresh()
+	}
+	return substitute(b.t, subst)
+}
+
+func substitute(t Type, subst map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if s, ok := subst[t]; ok {
+			return s
+		}
+		return t
+	case *Func:
+		f := &Func{Result: substitute(t.Result, subst)}
+		for _, p := range t.Params {
+			f.Params = append(f.Params, substitute(p, subst))
+		}
+		return f
+	default:
+		return t
+	}
+}
+
+// statements checks a block, returning its type. result is the result type of
+// the enclosing function, for return statements.
+func (c *checker) statements(stmts []ast.Statement, result Type) Type {
+	var t Type
+	for _, s := range stmts {
+		t = c.statement(s, result)
+	}
+	if t == nil {
+		// Null.
+		t = c.fresh()
+	}
+	return t
+}
+
+// statement checks s, returning the value it gives a block if it's last.
+func (c *checker) statement(s ast.Statement, result Type) Type {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.let(s, result)
+	case *ast.ReturnStatement:
+		if s.ReturnValue != nil {
+			c.expect(s.ReturnValue, c.expression(s.ReturnValue, result), result, "return")
+		}
+	case *ast.ExpressionStatement:
+		if s.Expression != nil {
+			return c.expression(s.Expression, result)
+		}
+	case *ast.BlockStatement:
+		return c.statements(s.Statements, result)
+	}
+	// Null, or never completes.
+	return c.fresh()
+}
+
+func (c *checker) let(s *ast.LetStatement, result Type) {
+	decl := c.res.Defs[s.Name]
+	if decl == nil {
+		if s.Value != nil {
+			c.expression(s.Value, result)
+		}
+		return
+	}
+	b := c.bindings[decl]
+	if b == nil {
+		// Recursive uses of the name see it with a single type.
+		b = &binding{t: c.fresh()}
+		c.bindings[decl] = b
+	}
+	if s.Type != nil {
+		want := c.annotation(s.Type)
+		if err := unify(b.t, want); err != nil {
+			n := newNamer()
+			c.errorf(s.Type.Pos(), "%s is used as type %s, but declared as %s", s.Name.Value, typeString(b.t, n), typeString(want, n))
+		}
+	}
+	c.nonGeneric = append(c.nonGeneric, b.t)
+	if s.Value != nil {
+		c.expect(s.Value, c.expression(s.Value, result), b.t, "let "+s.Name.Value)
+	}
+	c.nonGeneric = c.nonGeneric[:len(c.nonGeneric)-1]
+	if b.forward {
+		// Already used with its monomorphic type.
+		return
+	}
+	c.bindings[decl] = c.generalize(b.t)
+}
+
+func (c *checker) expression(e ast.Expression, result Type) Type {
+	t := c.infer(e, result)
+	c.info.Types[e] = t
+	return t
+}
+
+func (c *checker) infer(e ast.Expression, result Type) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.PrefixExpression:
+		right := c.expression(e.Right, result)
+		switch e.Operator {
+		case "-":
+			c.expect(e.Right, right, Int, "operand of "+e.Operator)
+			return Int
+		case "!":
+			// Any value may be negated, as any value may be a condition.
+			return Bool
+		}
+	case *ast.InfixExpression:
+		left := c.expression(e.Left, result)
+		right := c.expression(e.Right, result)
+		switch e.Operator {
+		case "+", "-", "*", "/":
+			c.expect(e.Left, left, Int, "operand of "+e.Operator)
+			c.expect(e.Right, right, Int, "operand of "+e.Operator)
+			return Int
+		case "<", ">":
+			c.expect(e.Left, left, Int, "operand of "+e.Operator)
+			c.expect(e.Right, right, Int, "operand of "+e.Operator)
+			return Bool
+		case "==", "!=":
+			if err := unify(left, right); err != nil {
+				n := newNamer()
+				c.errorf(e.Pos(), "mismatched types %s and %s in %s", typeString(left, n), typeString(right, n), e.Operator)
+			}
+			return Bool
+		}
+	case *ast.IfExpression:
+		c.expression(e.Condition, result)
+		cons := c.block(e.Consequence, result)
+		if e.Alternative == nil {
+			return cons
+		}
+		alt := c.block(e.Alternative, result)
+		if err := unify(cons, alt); err != nil {
+			n := newNamer()
+			c.errorf(e.Pos(), "if branches have mismatched types %s and %s", typeString(cons, n), typeString(alt, n))
+		}
+		return cons
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.CallExpression:
+		return c.call(e, result)
+	}
+	return c.fresh()
+}
+
+func (c *checker) block(b *ast.BlockStatement, result Type) Type {
+	if b == nil {
+		return c.fresh()
+	}
+	return c.statements(b.Statements, result)
+}
+
+func (c *checker) identifier(id *ast.Identifier) Type {
+	decl := c.res.Uses[id]
+	if decl == nil {
+		// Undefined.
+		return c.fresh()
+	}
+	b := c.bindings[decl]
+	if b == nil {
+		// Declared later; we'll find out its type when we get there.
+		b = &binding{t: c.fresh(), forward: true}
+		c.bindings[decl] = b
+		c.forward = append(c.forward, b.t)
+	}
+	return c.instantiate(b)
+}
+
+func (c *checker) function(fl *ast.FunctionLiteral) Type {
+	f := &Func{Result: c.fresh()}
+	if fl.ReturnType != nil {
+		f.Result = c.annotation(fl.ReturnType)
+	}
+	n := len(c.nonGeneric)
+	for _, p := range fl.Parameters {
+		var t Type = c.fresh()
+		if p.Type != nil {
+			t = c.annotation(p.Type)
+		}
+		f.Params = append(f.Params, t)
+		if decl := c.res.Defs[p]; decl != nil {
+			c.bindings[decl] = &binding{t: t}
+		}
+		c.nonGeneric = append(c.nonGeneric, t)
+	}
+	if fl.Body != nil {
+		body := c.statements(fl.Body.Statements, f.Result)
+		var last ast.Expression = fl
+		if k := len(fl.Body.Statements); k > 0 {
+			if es, ok := fl.Body.Statements[k-1].(*ast.ExpressionStatement); ok && es.Expression != nil {
+				last = es.Expression
+			}
+		}
+		c.expect(last, body, f.Result, "function result")
+	}
+	c.nonGeneric = c.nonGeneric[:n]
+	return f
+}
+
+// annotation returns the type written as t.
+func (c *checker) annotation(t ast.TypeExpression) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		switch t.Name {
+		case Int.Name:
+			return Int
+		case Bool.Name:
+			return Bool
+		}
+		c.errorf(t.Pos(), "unknown type %s", t.Name)
+	case *ast.FunctionType:
+		f := &Func{Result: c.annotation(t.Result)}
+		for _, p := range t.Params {
+			f.Params = append(f.Params, c.annotation(p))
+		}
+		return f
+	}
+	return c.fresh()
+}
+
+func (c *checker) call(ce *ast.CallExpression, result Type) Type {
+	fn := c.expression(ce.Function, result)
+	var args []Type
+	for _, a := range ce.Arguments {
+		args = append(args, c.expression(a, result))
+	}
+
+	switch ft := prune(fn).(type) {
+	case *Func:
+		if len(ft.Params) != len(ce.Arguments) {
+			c.errorf(ce.Pos(), "%v takes %d arguments, got %d", ce.Function, len(ft.Params), len(ce.Arguments))
+			return ft.Result
+		}
+		for i, a := range ce.Arguments {
+			c.expect(a, args[i], ft.Params[i], fmt.Sprintf("argument %d to %v", i+1, ce.Function))
+		}
+		return ft.Result
+	case *Var:
+		f := &Func{Params: args, Result: c.fresh()}
+		c.expect(ce.Function, fn, f, "call")
+		return f.Result
+	default:
+		c.errorf(ce.Pos(), "cannot call %v (type %s)", ce.Function, fn)
+		return c.fresh()
+	}
+}
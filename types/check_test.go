@@ -0,0 +1,242 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/parser"
+)
+
+// defTypes returns the types of the top level let statements in prog.
+func defTypes(info *Info, prog *ast.Program) map[string]string {
+	types := make(map[string]string)
+	for _, s := range prog.Statements {
+		if ls, ok := s.(*ast.LetStatement); ok {
+			types[ls.Name.Value] = info.Defs[ls.Name].String()
+		}
+	}
+	return types
+}
+
+func TestCheckTypes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  map[string]string
+	}{
+		{"let x = 5;", map[string]string{"x": "int"}},
+		{"let x = 5 < 10;", map[string]string{"x": "bool"}},
+		{"let x = !true == false;", map[string]string{"x": "bool"}},
+		{"let x = -5 * 2;", map[string]string{"x": "int"}},
+		{"let id = fn(x) { x };", map[string]string{"id": "fn(a) -> a"}},
+		{"let k = fn(x, y) { x };", map[string]string{"k": "fn(a, b) -> a"}},
+		{
+			input: "let id = fn(x) { x }; let a = id(1); let b = id(true);",
+			want:  map[string]string{"id": "fn(a) -> a", "a": "int", "b": "bool"},
+		},
+		{
+			input: "let add = fn(x, y) { x + y }; let three = add(1, 2);",
+			want:  map[string]string{"add": "fn(int, int) -> int", "three": "int"},
+		},
+		{
+			input: "let compose = fn(f, g) { fn(x) { f(g(x)) } };",
+			want:  map[string]string{"compose": "fn(fn(a) -> b, fn(c) -> a) -> fn(c) -> b"},
+		},
+		{
+			input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };",
+			want:  map[string]string{"fact": "fn(int) -> int"},
+		},
+		{
+			input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };\nlet odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };",
+			want:  map[string]string{"even": "fn(int) -> bool", "odd": "fn(int) -> bool"},
+		},
+		{
+			input: "let f = fn(x) { if (x) { return 1; }; 2 };",
+			want:  map[string]string{"f": "fn(a) -> int"},
+		},
+		{
+			input: "let f = fn(x) { let y = x; y };",
+			want:  map[string]string{"f": "fn(a) -> a"},
+		},
+		{
+			// Let-polymorphism inside a function.
+			input: "let f = fn(x) { let id = fn(y) { y }; if (id(true)) { id(x) } else { x } };",
+			want:  map[string]string{"f": "fn(a) -> a"},
+		},
+		{
+			input: "let apply = fn(f, x) { f(x) }; let y = apply(fn(n) { n > 0 }, 1);",
+			want:  map[string]string{"apply": "fn(fn(a) -> b, a) -> b", "y": "bool"},
+		},
+		{
+			// A missing else branch is null, which stands for any type.
+			input: "let f = fn(x) { if (x) { 1 } };",
+			want:  map[string]string{"f": "fn(a) -> int"},
+		},
+		{
+			// Every value but false and null is true.
+			input: "let x = !1; let y = !fn() { 1 };",
+			want:  map[string]string{"x": "bool", "y": "bool"},
+		},
+		{
+			input: "let n = 5; let x = if (n) { n } else { 0 }; let f = fn(x: int) { if (x) { 1 } else { 2 } };",
+			want:  map[string]string{"n": "int", "x": "int", "f": "fn(int) -> int"},
+		},
+		{
+			input: "let x = 1; let x = true;",
+			want:  map[string]string{"x": "bool"},
+		},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		info := Check(prog)
+		for _, err := range info.Errors {
+			t.Errorf("%d. Check(%q) error: %v", i, tc.input, err)
+		}
+		got := defTypes(info, prog)
+		for name, want := range tc.want {
+			if got[name] != want {
+				t.Errorf("%d. Check(%q): %s has type %s, want %s", i, tc.input, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"1 + true", []string{"1:5: cannot use true (type bool) as type int in operand of +"}},
+		{"let b = true; b * 2", []string{"1:15: cannot use b (type bool) as type int in operand of *"}},
+		{"1 < false", []string{"1:5: cannot use false (type bool) as type int in operand of <"}},
+		{"-true; !1", []string{"1:2: cannot use true (type bool) as type int in operand of -"}},
+		{"1 == true", []string{"1:3: mismatched types int and bool in =="}},
+		{"if (true) { 2 } else { false }", []string{"1:1: if branches have mismatched types int and bool"}},
+		{"let f = fn(x) { x + 1 }; f(true)", []string{"1:28: cannot use true (type bool) as type int in argument 1 to f"}},
+		{"let f = fn(x) { x }; f(1, 2)", []string{"1:23: f takes 1 arguments, got 2"}},
+		{"let x = 1; x(2)", []string{"1:13: cannot call x (type int)"}},
+		{"fn(f) { f(f) }", []string{"1:9: cannot use f (type a) as type fn(a) -> b in call (infinite type)"}},
+		{
+			// Parameters aren't polymorphic.
+			input: "fn(id) { id(1); id(true) }",
+			want:  []string{"1:20: cannot use true (type bool) as type int in argument 1 to id"},
+		},
+		{
+			input: "let f = fn(x) { if (x) { return 1; }; true };",
+			want:  []string{"1:39: cannot use true (type bool) as type int in function result"},
+		},
+		{
+			input: "let f = fn(x) { return x; }; let y = f(1) + f(true);",
+			want:  []string{"1:46: cannot use f(true) (type bool) as type int in operand of +"},
+		},
+		{
+			// Mutually recursive functions aren't polymorphic.
+			input: "let f = fn(x) { g(x) }; f(1); f(true); let g = fn(x) { x };",
+			want:  []string{"1:33: cannot use true (type bool) as type int in argument 1 to f"},
+		},
+		{
+			// Undefined names are left to package resolve.
+			input: "x + 1",
+		},
+	}
+	for i, tc := range tests {
+		info := Check(parser.MustParse(tc.input))
+		var got []string
+		for _, err := range info.Errors {
+			got = append(got, err.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%d. Check(%q) errors =\n%s\nwant:\n%s", i, tc.input, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
+
+func TestCheckExpressionTypes(t *testing.T) {
+	prog := parser.MustParse("let id = fn(x) { x }; id(1) + 2; id(id)(true)")
+	info := Check(prog)
+	if len(info.Errors) > 0 {
+		t.Fatalf("Check() errors: %v", info.Errors)
+	}
+	got := make(map[string]string)
+	ast.Inspect(prog, func(n ast.Node) bool {
+		if e, ok := n.(ast.Expression); ok {
+			if typ, ok := info.Types[e]; ok {
+				got[e.String()] = typ.String()
+			} else if _, ok := n.(*ast.Identifier); !ok {
+				t.Errorf("no type for %v", e)
+			}
+		}
+		return true
+	})
+	want := map[string]string{
+		"fn(x) {\nx;\n}": "fn(a) -> a",
+		"id(1)":          "int",
+		"(id(1) + 2)":    "int",
+		"id(id)":         "fn(bool) -> bool",
+		"id(id)(true)":   "bool",
+		"true":           "bool",
+	}
+	for e, typ := range want {
+		if got[e] != typ {
+			t.Errorf("%q has type %q, want %q", e, got[e], typ)
+		}
+	}
+}
+
+func TestCheckAnnotations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  map[string]string
+		errs  []string
+	}{
+		{
+			input: "let x: int = 5;",
+			want:  map[string]string{"x": "int"},
+		},
+		{
+			input: "let id: fn(int) -> int = fn(x) { x };",
+			want:  map[string]string{"id": "fn(int) -> int"},
+		},
+		{
+			input: "let f = fn(x: bool, y) -> int { y };",
+			want:  map[string]string{"f": "fn(bool, int) -> int"},
+		},
+		{
+			input: "let x: bool = 5;",
+			errs:  []string{"1:15: cannot use 5 (type int) as type bool in let x"},
+		},
+		{
+			input: "let f = fn(x: int) { !x };",
+			want:  map[string]string{"f": "fn(int) -> bool"},
+		},
+		{
+			input: "let f = fn(x) -> bool { x + 1 };",
+			errs:  []string{"1:27: cannot use (x + 1) (type int) as type bool in function result"},
+		},
+		{
+			input: "let f = fn() { g(1) }; let g: fn(bool) -> int = fn(b) { 1 };",
+			errs:  []string{"1:31: g is used as type fn(int) -> a, but declared as fn(bool) -> int"},
+		},
+		{
+			input: "let x: string = 5;",
+			errs:  []string{"1:8: unknown type string"},
+		},
+	}
+	for i, tc := range tests {
+		prog := parser.MustParse(tc.input)
+		info := Check(prog)
+		var errs []string
+		for _, err := range info.Errors {
+			errs = append(errs, err.Error())
+		}
+		if strings.Join(errs, "\n") != strings.Join(tc.errs, "\n") {
+			t.Errorf("%d. Check(%q) errors =\n%s\nwant:\n%s", i, tc.input, strings.Join(errs, "\n"), strings.Join(tc.errs, "\n"))
+		}
+		got := defTypes(info, prog)
+		for name, want := range tc.want {
+			if got[name] != want {
+				t.Errorf("%d. Check(%q): %s has type %s, want %s", i, tc.input, name, got[name], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package types infers the types of monkey programs.
+//
+// Monkey is dynamically typed; this package doesn't change that, but reports
+// programs which would go wrong at run time, such as adding a boolean to an
+// integer. Types are inferred with the Hindley-Milner algorithm: integers,
+// booleans and functions have types, and functions bound by let statements
+// are polymorphic, so
+//
+//	let id = fn(x) { x };
+//
+// has type fn(a) -> a and can be applied to both integers and booleans.
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a monkey value.
+type Type interface {
+	String() string
+	isType()
+}
+
+// Basic is a type with no parts.
+type Basic struct {
+	Name string
+}
+
+// The basic types.
+var (
+	Int  = &Basic{Name: "int"}
+	Bool = &Basic{Name: "bool"}
+)
+
+// Func is the type of a function.
+type Func struct {
+	Params []Type
+	Result Type
+}
+
+// Var is a type variable, standing for a type which isn't known yet, or for
+// any type in a polymorphic Scheme.
+type Var struct {
+	id int
+	// inst is the type this variable has been unified with, if any.
+	inst Type
+}
+
+func (*Basic) isType() {}
+func (*Func) isType()  {}
+func (*Var) isType()   {}
+
+func (t *Basic) String() string { return t.Name }
+func (t *Func) String() string  { return typeString(t, newNamer()) }
+func (t *Var) String() string   { return typeString(t, newNamer()) }
+
+// Scheme is a polymorphic type: Vars stand for any type.
+type Scheme struct {
+	Vars []*Var
+	Type Type
+}
+
+func (s *Scheme) String() string { return typeString(s.Type, newNamer()) }
+
+// namer gives type variables readable names: a, b, c, …
+type namer map[*Var]string
+
+func newNamer() namer { return make(namer) }
+
+func (n namer) name(v *Var) string {
+	if s, ok := n[v]; ok {
+		return s
+	}
+	i := len(n)
+	s := string(rune('a' + i%26))
+	if i >= 26 {
+		s += fmt.Sprint(i / 26)
+	}
+	n[v] = s
+	return s
+}
+
+func typeString(t Type, n namer) string {
+	switch t := prune(t).(type) {
+	case *Basic:
+		return t.Name
+	case *Var:
+		return n.name(t)
+	case *Func:
+		var params []string
+		for _, p := range t.Params {
+			params = append(params, typeString(p, n))
+		}
+		return fmt.Sprintf("fn(%s) -> %s", strings.Join(params, ", "), typeString(t.Result, n))
+	}
+	return "?"
+}
+
+// prune follows instantiated variables to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.inst == nil {
+			return t
+		}
+		t = v.inst
+	}
+}
+
+// Resolve returns t with every instantiated type variable replaced by the type
+// it stands for.
+func Resolve(t Type) Type {
+	switch t := prune(t).(type) {
+	case *Func:
+		f := &Func{Result: Resolve(t.Result)}
+		for _, p := range t.Params {
+			f.Params = append(f.Params, Resolve(p))
+		}
+		return f
+	default:
+		return t
+	}
+}
+
+// occurs reports whether v appears in t.
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *Func:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	}
+	return false
+}
+
+// freeVars adds the uninstantiated variables in t to vars, in order.
+func freeVars(t Type, seen map[*Var]bool, vars []*Var) []*Var {
+	switch t := prune(t).(type) {
+	case *Var:
+		if !seen[t] {
+			seen[t] = true
+			vars = append(vars, t)
+		}
+	case *Func:
+		for _, p := range t.Params {
+			vars = freeVars(p, seen, vars)
+		}
+		vars = freeVars(t.Result, seen, vars)
+	}
+	return vars
+}
+
+// unify makes a and b the same type, by instantiating type variables.
+func unify(a, b Type) error {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return nil
+	}
+	if v, ok := a.(*Var); ok {
+		if occurs(v, b) {
+			return fmt.Errorf("infinite type")
+		}
+		v.inst = b
+		return nil
+	}
+	if _, ok := b.(*Var); ok {
+		return unify(b, a)
+	}
+	switch a := a.(type) {
+	case *Basic:
+		if b, ok := b.(*Basic); ok && a.Name == b.Name {
+			return nil
+		}
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok {
+			break
+		}
+		if len(a.Params) != len(b.Params) {
+			return fmt.Errorf("different numbers of parameters")
+		}
+		for i := range a.Params {
+			if err := unify(a.Params[i], b.Params[i]); err != nil {
+				return err
+			}
+		}
+		return unify(a.Result, b.Result)
+	}
+	return fmt.Errorf("mismatched types")
+}
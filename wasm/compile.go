@@ -0,0 +1,391 @@
+// Package wasm compiles monkey programs to WebAssembly.
+//
+// Only part of monkey can be compiled: integers, booleans, let statements,
+// arithmetic, comparisons, if expressions, and functions which don't capture
+// variables, bound by let statements at the top level of the program and
+// called by name. Types are inferred by package types, and integers become
+// i64 values and booleans i32s, so a program with type errors, or with
+// polymorphic functions, can't be compiled.
+//
+// Each top-level function becomes an exported WebAssembly function with the
+// same name. The program's other top-level statements become an exported
+// function called main, which returns the value of the last of them if it's
+// an expression.
+//
+// The resulting Module can be written in the WebAssembly text format with
+// WAT, or in the binary format with Encode.
+package wasm
+
+import (
+	"fmt"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/resolve"
+	"monkey/token"
+	"monkey/types"
+)
+
+var intOps = map[string]Op{
+	"+":  OpI64Add,
+	"-":  OpI64Sub,
+	"*":  OpI64Mul,
+	"/":  OpI64DivS,
+	"<":  OpI64LtS,
+	">":  OpI64GtS,
+	"==": OpI64Eq,
+	"!=": OpI64Ne,
+}
+
+var boolOps = map[string]Op{
+	"==": OpI32Eq,
+	"!=": OpI32Ne,
+}
+
+// Compile compiles prog to a module.
+func Compile(prog *ast.Program) (*Module, error) {
+	c := &compiler{
+		res:   resolve.Resolve(prog),
+		types: types.Check(prog),
+		mod:   &Module{},
+		funcs: make(map[*resolve.Decl]int64),
+	}
+	if err := c.res.Err(); err != nil {
+		return nil, err
+	}
+	if len(c.types.Errors) > 0 {
+		return nil, c.types.Errors[0]
+	}
+
+	// Declare every function first, so they can call each other.
+	var fns []*ast.FunctionLiteral
+	var main []ast.Statement
+	for _, s := range prog.Statements {
+		ls, ok := s.(*ast.LetStatement)
+		if !ok {
+			main = append(main, s)
+			continue
+		}
+		fl, ok := ls.Value.(*ast.FunctionLiteral)
+		if !ok {
+			main = append(main, s)
+			continue
+		}
+		c.declare(ls, fl)
+		fns = append(fns, fl)
+	}
+	for i, fl := range fns {
+		c.function(c.mod.Funcs[i], fl)
+	}
+	if len(main) > 0 {
+		c.main(main)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.mod, nil
+}
+
+type compiler struct {
+	res   *resolve.Info
+	types *types.Info
+	mod   *Module
+	// funcs are the indexes of the functions declared at the top level.
+	funcs map[*resolve.Decl]int64
+
+	// The function being compiled.
+	fn     *Func
+	locals map[*resolve.Decl]int64
+	names  map[string]int // number of locals with each name
+
+	err error
+}
+
+func (c *compiler) errorf(pos token.Pos, format string, args ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf("%v: %s", pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// valType returns the WebAssembly type of t, which must be int or bool.
+func (c *compiler) valType(pos token.Pos, t types.Type) ValType {
+	switch t {
+	case types.Int:
+		return I64
+	case types.Bool:
+		return I32
+	}
+	c.errorf(pos, "can't compile a value of type %v", t)
+	return I64
+}
+
+// declare adds the function ls binds to the module.
+func (c *compiler) declare(ls *ast.LetStatement, fl *ast.FunctionLiteral) {
+	name := ls.Name.Value
+	for _, f := range c.mod.Funcs {
+		if f.Name == name {
+			c.errorf(ls.Name.Pos(), "function %s is declared more than once", name)
+		}
+	}
+	f := &Func{Name: name, Export: true}
+	c.funcs[c.res.Defs[ls.Name]] = int64(len(c.mod.Funcs))
+	c.mod.Funcs = append(c.mod.Funcs, f)
+
+	ft, ok := c.types.Defs[ls.Name].Type.(*types.Func)
+	if !ok || !monomorphic(ft) {
+		c.errorf(ls.Name.Pos(), "can't compile %s of type %v: functions must take and return int or bool", name, c.types.Defs[ls.Name].Type)
+		return
+	}
+	for i, p := range fl.Parameters {
+		f.Params = append(f.Params, Local{Name: p.Value, Type: c.valType(p.Pos(), ft.Params[i])})
+	}
+	f.Results = []ValType{c.valType(fl.Pos(), ft.Result)}
+}
+
+// monomorphic reports whether ft takes and returns only ints and bools.
+func monomorphic(ft *types.Func) bool {
+	for _, p := range append(ft.Params, ft.Result) {
+		if p != types.Int && p != types.Bool {
+			return false
+		}
+	}
+	return true
+}
+
+// start begins compiling f.
+func (c *compiler) start(f *Func) {
+	c.fn = f
+	c.locals = make(map[*resolve.Decl]int64)
+	c.names = make(map[string]int)
+}
+
+func (c *compiler) function(f *Func, fl *ast.FunctionLiteral) {
+	if f.Results == nil {
+		// Its type was wrong.
+		return
+	}
+	c.start(f)
+	for i, p := range fl.Parameters {
+		// The last of several parameters with the same name wins.
+		f.Params[i].Name = c.localName(p.Value)
+		c.locals[c.res.Defs[p]] = int64(i)
+	}
+	c.statements(fl.Body.Pos(), fl.Body.Statements, f.Results[0])
+}
+
+// localName returns a name for a new local called name. Locals with the same
+// name are numbered.
+func (c *compiler) localName(name string) string {
+	n := c.names[name]
+	c.names[name]++
+	if n == 0 {
+		return name
+	}
+	return name + "." + strconv.Itoa(n)
+}
+
+func (c *compiler) main(stmts []ast.Statement) {
+	for _, f := range c.mod.Funcs {
+		if f.Name == "main" {
+			c.errorf(stmts[0].Pos(), "function main is reserved for the program's top-level statements")
+			return
+		}
+	}
+	f := &Func{Name: "main", Export: true}
+	c.mod.Funcs = append(c.mod.Funcs, f)
+	c.start(f)
+
+	var result ValType
+	if es, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok && es.Expression != nil {
+		result = c.valType(es.Pos(), c.types.Types[es.Expression])
+		f.Results = []ValType{result}
+	}
+	c.statements(stmts[0].Pos(), stmts, result)
+}
+
+func (c *compiler) emit(op Op, imm int64) {
+	c.fn.Body = append(c.fn.Body, Instr{Op: op, Imm: imm})
+}
+
+// statements compiles a list of statements. If result isn't 0, they leave a
+// value of that type on the stack.
+func (c *compiler) statements(pos token.Pos, stmts []ast.Statement, result ValType) {
+	for i, s := range stmts {
+		if i < len(stmts)-1 || result == 0 {
+			c.statement(s)
+			continue
+		}
+		switch s := s.(type) {
+		case *ast.ExpressionStatement:
+			if ie, ok := s.Expression.(*ast.IfExpression); ok {
+				c.ifExpression(ie, result)
+				return
+			}
+			if s.Expression != nil {
+				c.expression(s.Expression)
+				return
+			}
+		case *ast.ReturnStatement:
+			// Whatever follows is unreachable.
+			c.statement(s)
+			return
+		}
+		c.errorf(s.Pos(), "can't compile a block whose value is null")
+		return
+	}
+	if len(stmts) == 0 && result != 0 {
+		c.errorf(pos, "can't compile a block whose value is null")
+	}
+}
+
+func (c *compiler) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		if s.Value == nil {
+			return
+		}
+		if _, ok := s.Value.(*ast.FunctionLiteral); ok {
+			c.errorf(s.Pos(), "can't compile function %s: functions must be declared at the top level", s.Name.Value)
+			return
+		}
+		c.expression(s.Value)
+		i := int64(len(c.fn.Params) + len(c.fn.Locals))
+		c.fn.Locals = append(c.fn.Locals, Local{
+			Name: c.localName(s.Name.Value),
+			Type: c.valType(s.Name.Pos(), c.types.Defs[s.Name].Type),
+		})
+		c.locals[c.res.Defs[s.Name]] = i
+		c.emit(OpLocalSet, i)
+	case *ast.ReturnStatement:
+		if c.fn.Results == nil {
+			c.errorf(s.Pos(), "can't compile return from main when the program's last statement isn't an expression")
+			return
+		}
+		if s.ReturnValue == nil {
+			c.errorf(s.Pos(), "can't compile a return without a value")
+			return
+		}
+		if t := c.expression(s.ReturnValue); t != c.fn.Results[0] {
+			c.errorf(s.Pos(), "can't return %v from a function which returns %v", t, c.fn.Results[0])
+		}
+		c.emit(OpReturn, 0)
+	case *ast.ExpressionStatement:
+		if s.Expression == nil {
+			return
+		}
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			c.ifExpression(ie, 0)
+			return
+		}
+		c.expression(s.Expression)
+		c.emit(OpDrop, 0)
+	case *ast.BlockStatement:
+		c.statements(s.Pos(), s.Statements, 0)
+	}
+}
+
+// ifExpression compiles ie, leaving a value of type result on the stack if
+// result isn't 0.
+func (c *compiler) ifExpression(ie *ast.IfExpression, result ValType) {
+	c.condition(ie.Condition)
+	c.fn.Body = append(c.fn.Body, Instr{Op: OpIf, Result: result})
+	c.statements(ie.Consequence.Pos(), ie.Consequence.Statements, result)
+	if ie.Alternative != nil {
+		c.emit(OpElse, 0)
+		c.statements(ie.Alternative.Pos(), ie.Alternative.Statements, result)
+	} else if result != 0 {
+		c.errorf(ie.Pos(), "can't compile an if expression without an else branch whose value is used")
+	}
+	c.emit(OpEnd, 0)
+}
+
+// expression compiles e, which leaves a value on the stack, and returns the
+// value's type.
+func (c *compiler) expression(e ast.Expression) ValType {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		c.emit(OpI64Const, e.Value)
+	case *ast.Boolean:
+		if e.Value {
+			c.emit(OpI32Const, 1)
+		} else {
+			c.emit(OpI32Const, 0)
+		}
+	case *ast.Identifier:
+		c.identifier(e)
+	case *ast.PrefixExpression:
+		switch e.Operator {
+		case "-":
+			c.emit(OpI64Const, 0)
+			c.expression(e.Right)
+			c.emit(OpI64Sub, 0)
+		case "!":
+			c.condition(e.Right)
+			c.emit(OpI32Eqz, 0)
+		default:
+			c.errorf(e.Pos(), "can't compile operator %s", e.Operator)
+		}
+	case *ast.InfixExpression:
+		ops := intOps
+		if c.expression(e.Left) == I32 {
+			ops = boolOps
+		}
+		c.expression(e.Right)
+		op, ok := ops[e.Operator]
+		if !ok {
+			c.errorf(e.Pos(), "can't compile operator %s", e.Operator)
+		}
+		c.emit(op, 0)
+	case *ast.IfExpression:
+		c.ifExpression(e, c.valType(e.Pos(), c.types.Types[e]))
+	case *ast.CallExpression:
+		c.call(e)
+	case *ast.FunctionLiteral:
+		c.errorf(e.Pos(), "can't compile a function which isn't bound at the top level")
+	default:
+		c.errorf(e.Pos(), "can't compile %T", e)
+	}
+	// After the cases above, which report why a function is used.
+	return c.valType(e.Pos(), c.types.Types[e])
+}
+
+// condition compiles e, which leaves 1 on the stack if its value counts as
+// true and 0 if not. Every integer is true, so an int is computed and
+// replaced by 1.
+func (c *compiler) condition(e ast.Expression) {
+	if c.expression(e) == I64 {
+		c.emit(OpDrop, 0)
+		c.emit(OpI32Const, 1)
+	}
+}
+
+func (c *compiler) identifier(id *ast.Identifier) {
+	d := c.res.Uses[id]
+	if _, ok := c.funcs[d]; ok {
+		c.errorf(id.Pos(), "can't compile use of function %s as a value", id.Value)
+		return
+	}
+	i, ok := c.locals[d]
+	if !ok {
+		c.errorf(id.Pos(), "can't compile %s, which is captured from an enclosing scope", id.Value)
+		return
+	}
+	c.emit(OpLocalGet, i)
+}
+
+func (c *compiler) call(ce *ast.CallExpression) {
+	id, ok := ce.Function.(*ast.Identifier)
+	if !ok {
+		c.errorf(ce.Function.Pos(), "can't compile a call of a function value: only functions declared at the top level can be called")
+		return
+	}
+	i, ok := c.funcs[c.res.Uses[id]]
+	if !ok {
+		c.errorf(id.Pos(), "can't compile a call of %s: only functions declared at the top level can be called", id.Value)
+		return
+	}
+	for _, arg := range ce.Arguments {
+		c.expression(arg)
+	}
+	c.emit(OpCall, i)
+}
@@ -0,0 +1,144 @@
+package wasm
+
+// Section ids.
+const (
+	sectionType     = 1
+	sectionFunction = 3
+	sectionExport   = 7
+	sectionCode     = 10
+)
+
+const (
+	funcType   = 0x60
+	exportFunc = 0x00
+	blockEmpty = 0x40
+)
+
+// Encode returns the module in the WebAssembly binary format.
+func (m *Module) Encode() []byte {
+	// Functions with the same signature share a type.
+	var typeSecs [][]byte
+	typeIndex := make(map[string]int)
+	funcSec := appendUint(nil, uint64(len(m.Funcs)))
+	for _, f := range m.Funcs {
+		t := []byte{funcType}
+		t = appendUint(t, uint64(len(f.Params)))
+		for _, p := range f.Params {
+			t = append(t, byte(p.Type))
+		}
+		t = appendUint(t, uint64(len(f.Results)))
+		for _, r := range f.Results {
+			t = append(t, byte(r))
+		}
+		i, ok := typeIndex[string(t)]
+		if !ok {
+			i = len(typeSecs)
+			typeIndex[string(t)] = i
+			typeSecs = append(typeSecs, t)
+		}
+		funcSec = appendUint(funcSec, uint64(i))
+	}
+	typeSec := appendUint(nil, uint64(len(typeSecs)))
+	for _, t := range typeSecs {
+		typeSec = append(typeSec, t...)
+	}
+
+	var exports [][]byte
+	for i, f := range m.Funcs {
+		if f.Export {
+			e := appendName(nil, f.Name)
+			e = append(e, exportFunc)
+			exports = append(exports, appendUint(e, uint64(i)))
+		}
+	}
+	exportSec := appendUint(nil, uint64(len(exports)))
+	for _, e := range exports {
+		exportSec = append(exportSec, e...)
+	}
+
+	codeSec := appendUint(nil, uint64(len(m.Funcs)))
+	for _, f := range m.Funcs {
+		code := encodeBody(f)
+		codeSec = appendUint(codeSec, uint64(len(code)))
+		codeSec = append(codeSec, code...)
+	}
+
+	b := []byte("\x00asm\x01\x00\x00\x00")
+	b = appendSection(b, sectionType, typeSec)
+	b = appendSection(b, sectionFunction, funcSec)
+	b = appendSection(b, sectionExport, exportSec)
+	b = appendSection(b, sectionCode, codeSec)
+	return b
+}
+
+// encodeBody encodes the locals and instructions of f.
+func encodeBody(f *Func) []byte {
+	// Runs of locals with the same type are declared together.
+	var runs []int
+	var runTypes []ValType
+	for i, l := range f.Locals {
+		if i > 0 && l.Type == runTypes[len(runTypes)-1] {
+			runs[len(runs)-1]++
+			continue
+		}
+		runs = append(runs, 1)
+		runTypes = append(runTypes, l.Type)
+	}
+	b := appendUint(nil, uint64(len(runs)))
+	for i, n := range runs {
+		b = appendUint(b, uint64(n))
+		b = append(b, byte(runTypes[i]))
+	}
+
+	for _, in := range f.Body {
+		b = append(b, byte(in.Op))
+		switch in.Op {
+		case OpIf:
+			if in.Result == 0 {
+				b = append(b, blockEmpty)
+			} else {
+				b = append(b, byte(in.Result))
+			}
+		case OpCall, OpLocalGet, OpLocalSet:
+			b = appendUint(b, uint64(in.Imm))
+		case OpI32Const, OpI64Const:
+			b = appendInt(b, in.Imm)
+		}
+	}
+	return append(b, byte(OpEnd))
+}
+
+func appendSection(b []byte, id byte, contents []byte) []byte {
+	b = append(b, id)
+	b = appendUint(b, uint64(len(contents)))
+	return append(b, contents...)
+}
+
+func appendName(b []byte, name string) []byte {
+	b = appendUint(b, uint64(len(name)))
+	return append(b, name...)
+}
+
+// appendUint appends the unsigned LEB128 encoding of v.
+func appendUint(b []byte, v uint64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// appendInt appends the signed LEB128 encoding of v.
+func appendInt(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
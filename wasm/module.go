@@ -0,0 +1,172 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValType is the type of a WebAssembly value.
+type ValType byte
+
+const (
+	I32 ValType = 0x7f
+	I64 ValType = 0x7e
+)
+
+func (t ValType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	}
+	return fmt.Sprintf("ValType(%#x)", byte(t))
+}
+
+// Op is an instruction opcode.
+type Op byte
+
+// The instructions used by compiled programs.
+const (
+	OpIf       Op = 0x04
+	OpElse     Op = 0x05
+	OpEnd      Op = 0x0b
+	OpReturn   Op = 0x0f
+	OpCall     Op = 0x10
+	OpDrop     Op = 0x1a
+	OpLocalGet Op = 0x20
+	OpLocalSet Op = 0x21
+	OpI32Const Op = 0x41
+	OpI64Const Op = 0x42
+	OpI32Eqz   Op = 0x45
+	OpI32Eq    Op = 0x46
+	OpI32Ne    Op = 0x47
+	OpI64Eq    Op = 0x51
+	OpI64Ne    Op = 0x52
+	OpI64LtS   Op = 0x53
+	OpI64GtS   Op = 0x55
+	OpI64Add   Op = 0x7c
+	OpI64Sub   Op = 0x7d
+	OpI64Mul   Op = 0x7e
+	OpI64DivS  Op = 0x7f
+)
+
+var opNames = map[Op]string{
+	OpIf:       "if",
+	OpElse:     "else",
+	OpEnd:      "end",
+	OpReturn:   "return",
+	OpCall:     "call",
+	OpDrop:     "drop",
+	OpLocalGet: "local.get",
+	OpLocalSet: "local.set",
+	OpI32Const: "i32.const",
+	OpI64Const: "i64.const",
+	OpI32Eqz:   "i32.eqz",
+	OpI32Eq:    "i32.eq",
+	OpI32Ne:    "i32.ne",
+	OpI64Eq:    "i64.eq",
+	OpI64Ne:    "i64.ne",
+	OpI64LtS:   "i64.lt_s",
+	OpI64GtS:   "i64.gt_s",
+	OpI64Add:   "i64.add",
+	OpI64Sub:   "i64.sub",
+	OpI64Mul:   "i64.mul",
+	OpI64DivS:  "i64.div_s",
+}
+
+func (op Op) String() string {
+	if s, ok := opNames[op]; ok {
+		return s
+	}
+	return fmt.Sprintf("Op(%#x)", byte(op))
+}
+
+// Instr is a single instruction.
+type Instr struct {
+	Op Op
+	// Imm is the immediate operand of const, local and call instructions.
+	Imm int64
+	// Result is the result type of an if instruction, or 0 for none.
+	Result ValType
+}
+
+// Local is a parameter or local variable of a function.
+type Local struct {
+	Name string
+	Type ValType
+}
+
+// Func is a function in a module.
+type Func struct {
+	Name   string
+	Export bool
+	Params []Local
+	// Results has at most one type.
+	Results []ValType
+	Locals  []Local
+	// Body is the function's code, without the final end.
+	Body []Instr
+}
+
+// Module is a WebAssembly module made of functions.
+type Module struct {
+	Funcs []*Func
+}
+
+// local returns the parameter or local with index i.
+func (f *Func) local(i int64) Local {
+	if i < int64(len(f.Params)) {
+		return f.Params[i]
+	}
+	return f.Locals[i-int64(len(f.Params))]
+}
+
+// WAT returns the module in the WebAssembly text format.
+func (m *Module) WAT() string {
+	var out strings.Builder
+	out.WriteString("(module\n")
+	for _, f := range m.Funcs {
+		fmt.Fprintf(&out, "  (func $%s", f.Name)
+		if f.Export {
+			fmt.Fprintf(&out, " (export %q)", f.Name)
+		}
+		for _, p := range f.Params {
+			fmt.Fprintf(&out, " (param $%s %v)", p.Name, p.Type)
+		}
+		for _, r := range f.Results {
+			fmt.Fprintf(&out, " (result %v)", r)
+		}
+		out.WriteString("\n")
+		for _, l := range f.Locals {
+			fmt.Fprintf(&out, "    (local $%s %v)\n", l.Name, l.Type)
+		}
+		depth := 2
+		for _, in := range f.Body {
+			if in.Op == OpElse || in.Op == OpEnd {
+				depth--
+			}
+			out.WriteString(strings.Repeat("  ", depth))
+			out.WriteString(in.Op.String())
+			switch in.Op {
+			case OpIf:
+				if in.Result != 0 {
+					fmt.Fprintf(&out, " (result %v)", in.Result)
+				}
+			case OpCall:
+				fmt.Fprintf(&out, " $%s", m.Funcs[in.Imm].Name)
+			case OpLocalGet, OpLocalSet:
+				fmt.Fprintf(&out, " $%s", f.local(in.Imm).Name)
+			case OpI32Const, OpI64Const:
+				fmt.Fprintf(&out, " %d", in.Imm)
+			}
+			out.WriteString("\n")
+			if in.Op == OpIf || in.Op == OpElse {
+				depth++
+			}
+		}
+		out.WriteString("  )\n")
+	}
+	out.WriteString(")\n")
+	return out.String()
+}
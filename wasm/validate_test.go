@@ -0,0 +1,365 @@
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// This file has a validator for the binary modules Encode produces. It
+// checks the structure of the module, and type checks function bodies as the
+// WebAssembly specification does, for the instructions this package uses.
+
+// reader decodes the binary format.
+type reader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *reader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("offset %d: %s", r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *reader) done() bool { return r.err != nil || r.pos >= len(r.b) }
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.b) {
+		r.fail("unexpected end")
+		return 0
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *reader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.b)-r.pos) {
+		r.fail("length %d out of bounds", n)
+		return nil
+	}
+	r.pos += int(n)
+	return r.b[r.pos-int(n) : r.pos]
+}
+
+// uint reads an unsigned LEB128 number of at most bits bits.
+func (r *reader) uint(bits uint) uint64 {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		c := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits || shift+7 > bits && c&0x7f>>(bits-shift) != 0 {
+			r.fail("integer too large")
+			return 0
+		}
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+// int reads a signed LEB128 number of at most bits bits.
+func (r *reader) int(bits uint) int64 {
+	var v int64
+	for shift := uint(0); ; shift += 7 {
+		c := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits {
+			r.fail("integer too large")
+			return 0
+		}
+		v |= int64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			if shift+7 < 64 && c&0x40 != 0 {
+				v |= -1 << (shift + 7)
+			}
+			if bits < 64 && (v < -1<<(bits-1) || v >= 1<<(bits-1)) {
+				r.fail("integer too large")
+			}
+			return v
+		}
+	}
+}
+
+func (r *reader) valType() ValType {
+	t := ValType(r.byte())
+	if t != I32 && t != I64 {
+		r.fail("bad value type %#x", byte(t))
+	}
+	return t
+}
+
+func (r *reader) valTypes() []ValType {
+	n := r.uint(32)
+	var ts []ValType
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		ts = append(ts, r.valType())
+	}
+	return ts
+}
+
+type signature struct {
+	params, results []ValType
+}
+
+// validate checks that b is a valid module.
+func validate(b []byte) error {
+	r := &reader{b: b}
+	if !bytes.Equal(r.bytes(8), []byte("\x00asm\x01\x00\x00\x00")) {
+		return errors.New("bad header")
+	}
+	var (
+		types   []signature
+		funcs   []int // type index of each function
+		lastID  byte
+		hasCode bool
+	)
+	for !r.done() {
+		id := r.byte()
+		sec := &reader{b: r.bytes(r.uint(32))}
+		if r.err != nil {
+			return r.err
+		}
+		if id <= lastID {
+			return fmt.Errorf("section %d out of order", id)
+		}
+		lastID = id
+		switch id {
+		case sectionType:
+			for n := sec.uint(32); n > 0 && sec.err == nil; n-- {
+				if sec.byte() != funcType {
+					sec.fail("bad function type")
+				}
+				sig := signature{sec.valTypes(), sec.valTypes()}
+				if len(sig.results) > 1 {
+					sec.fail("more than one result")
+				}
+				types = append(types, sig)
+			}
+		case sectionFunction:
+			for n := sec.uint(32); n > 0 && sec.err == nil; n-- {
+				t := sec.uint(32)
+				if t >= uint64(len(types)) {
+					sec.fail("type index %d out of range", t)
+				}
+				funcs = append(funcs, int(t))
+			}
+		case sectionExport:
+			names := make(map[string]bool)
+			for n := sec.uint(32); n > 0 && sec.err == nil; n-- {
+				name := string(sec.bytes(sec.uint(32)))
+				if !utf8.ValidString(name) || names[name] {
+					sec.fail("bad export name %q", name)
+				}
+				names[name] = true
+				if sec.byte() != exportFunc {
+					sec.fail("export of %s isn't a function", name)
+				}
+				if i := sec.uint(32); i >= uint64(len(funcs)) {
+					sec.fail("function index %d out of range", i)
+				}
+			}
+		case sectionCode:
+			hasCode = true
+			if n := sec.uint(32); n != uint64(len(funcs)) {
+				return fmt.Errorf("%d function bodies for %d functions", n, len(funcs))
+			}
+			for i := range funcs {
+				body := &reader{b: sec.bytes(sec.uint(32))}
+				if sec.err != nil {
+					break
+				}
+				if err := validateBody(body, types, funcs, types[funcs[i]]); err != nil {
+					return fmt.Errorf("function %d: %v", i, err)
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected section %d", id)
+		}
+		if sec.err != nil {
+			return fmt.Errorf("section %d: %v", id, sec.err)
+		}
+		if !sec.done() {
+			return fmt.Errorf("section %d: %d bytes left over", id, len(sec.b)-sec.pos)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(funcs) > 0 && !hasCode {
+		return errors.New("missing code section")
+	}
+	return nil
+}
+
+// unknown is the type of a value popped from the stack in unreachable code.
+const unknown ValType = 0
+
+// control is a block on the control stack.
+type control struct {
+	op          Op
+	results     []ValType
+	height      int // height of the operand stack at the start
+	unreachable bool
+	hasElse     bool
+}
+
+type bodyValidator struct {
+	r        *reader
+	stack    []ValType
+	controls []*control
+}
+
+func (v *bodyValidator) push(t ValType) { v.stack = append(v.stack, t) }
+
+func (v *bodyValidator) pop(want ValType) ValType {
+	c := v.controls[len(v.controls)-1]
+	if len(v.stack) == c.height {
+		if !c.unreachable {
+			v.r.fail("%v: stack underflow", want)
+		}
+		return unknown
+	}
+	t := v.stack[len(v.stack)-1]
+	v.stack = v.stack[:len(v.stack)-1]
+	if want != unknown && t != unknown && t != want {
+		v.r.fail("got %v, want %v", t, want)
+	}
+	return t
+}
+
+// popResults pops the results of the innermost block, which must be all that
+// is left of its operands.
+func (v *bodyValidator) popResults() *control {
+	c := v.controls[len(v.controls)-1]
+	for i := len(c.results) - 1; i >= 0; i-- {
+		v.pop(c.results[i])
+	}
+	if len(v.stack) != c.height {
+		v.r.fail("%d values left on the stack", len(v.stack)-c.height)
+	}
+	return c
+}
+
+func (v *bodyValidator) setUnreachable() {
+	c := v.controls[len(v.controls)-1]
+	v.stack = v.stack[:c.height]
+	c.unreachable = true
+}
+
+func validateBody(r *reader, types []signature, funcs []int, sig signature) error {
+	locals := append([]ValType(nil), sig.params...)
+	for n := r.uint(32); n > 0 && r.err == nil; n-- {
+		count := r.uint(32)
+		t := r.valType()
+		if count > 1000 {
+			r.fail("too many locals")
+		}
+		for ; count > 0 && r.err == nil; count-- {
+			locals = append(locals, t)
+		}
+	}
+
+	v := &bodyValidator{r: r}
+	v.controls = []*control{{results: sig.results}}
+	for len(v.controls) > 0 && r.err == nil {
+		op := Op(r.byte())
+		switch op {
+		case OpIf:
+			c := &control{op: OpIf}
+			if bt := r.byte(); bt != blockEmpty {
+				r.pos--
+				c.results = []ValType{r.valType()}
+			}
+			v.pop(I32)
+			c.height = len(v.stack)
+			v.controls = append(v.controls, c)
+		case OpElse:
+			c := v.popResults()
+			if c.op != OpIf || c.hasElse {
+				r.fail("else outside if")
+			}
+			c.hasElse = true
+			c.unreachable = false
+		case OpEnd:
+			c := v.popResults()
+			if c.op == OpIf && !c.hasElse && len(c.results) > 0 {
+				r.fail("if without else has a result")
+			}
+			v.controls = v.controls[:len(v.controls)-1]
+			v.stack = append(v.stack, c.results...)
+		case OpReturn:
+			for i := len(sig.results) - 1; i >= 0; i-- {
+				v.pop(sig.results[i])
+			}
+			v.setUnreachable()
+		case OpCall:
+			i := r.uint(32)
+			if i >= uint64(len(funcs)) {
+				r.fail("function index %d out of range", i)
+				break
+			}
+			callee := types[funcs[i]]
+			for j := len(callee.params) - 1; j >= 0; j-- {
+				v.pop(callee.params[j])
+			}
+			v.stack = append(v.stack, callee.results...)
+		case OpDrop:
+			v.pop(unknown)
+		case OpLocalGet, OpLocalSet:
+			i := r.uint(32)
+			if i >= uint64(len(locals)) {
+				r.fail("local index %d out of range", i)
+				break
+			}
+			if op == OpLocalGet {
+				v.push(locals[i])
+			} else {
+				v.pop(locals[i])
+			}
+		case OpI32Const:
+			r.int(32)
+			v.push(I32)
+		case OpI64Const:
+			r.int(64)
+			v.push(I64)
+		case OpI32Eqz:
+			v.pop(I32)
+			v.push(I32)
+		case OpI32Eq, OpI32Ne:
+			v.pop(I32)
+			v.pop(I32)
+			v.push(I32)
+		case OpI64Eq, OpI64Ne, OpI64LtS, OpI64GtS:
+			v.pop(I64)
+			v.pop(I64)
+			v.push(I32)
+		case OpI64Add, OpI64Sub, OpI64Mul, OpI64DivS:
+			v.pop(I64)
+			v.pop(I64)
+			v.push(I64)
+		default:
+			r.fail("unknown opcode %#x", byte(op))
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if !r.done() {
+		return fmt.Errorf("%d bytes after the end of the body", len(r.b)-r.pos)
+	}
+	return nil
+}
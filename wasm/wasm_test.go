@@ -0,0 +1,254 @@
+package wasm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/parser"
+)
+
+// programs are monkey programs and the value their main function returns.
+// Booleans are returned as 0 or 1.
+var programs = []struct {
+	input string
+	want  string
+}{
+	{"1 + 2 * 3", "7"},
+	{"-7 / 2", "-3"},
+	{"9223372036854775807 + 1", "-9223372036854775808"},
+	{"!(1 == 2) == true", "1"},
+	{"1 < 2 != 2 > 1", "0"},
+	// Every integer is true, even 0.
+	{"!0", "0"},
+	{"let f = fn(n: int) { if (n) { 1 } else { 2 } }; f(0)", "1"},
+	{"let x = 1; let x = x + 1; x", "2"},
+	{"let x = 1; let y = if (x > 0) { let x = 10; x * 2 } else { 0 }; x + y", "21"},
+	{"if (true) { return 7; } 8", "7"},
+	{"let f = fn(a: int, a: int) { a }; f(1, 2)", "2"},
+	{"let abs = fn(n) { if (n < 0) { return -n; } n }; abs(-5) + abs(5)", "10"},
+	{
+		input: "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(20)",
+		want:  "2432902008176640000",
+	},
+	{
+		input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7)",
+		want:  "1",
+	},
+	{
+		input: "let sign = fn(n) { if (n < 0) { return -1; } if (n == 0) { 0 } else { 1 } }; sign(-5) + sign(0) * 10 + sign(7) * 100",
+		want:  "99",
+	},
+	{
+		input: "let between = fn(lo: int, x: int, hi: int) -> bool { if (x < lo) { false } else { !(x > hi) } }; between(1, 5, 10)",
+		want:  "1",
+	},
+}
+
+func TestWAT(t *testing.T) {
+	input := `let max = fn(a, b) { if (a > b) { a } else { b } };
+let x = max(3, 4);
+x == 4`
+	want := `(module
+  (func $max (export "max") (param $a i64) (param $b i64) (result i64)
+    local.get $a
+    local.get $b
+    i64.gt_s
+    if (result i64)
+      local.get $a
+    else
+      local.get $b
+    end
+  )
+  (func $main (export "main") (result i32)
+    (local $x i64)
+    i64.const 3
+    i64.const 4
+    call $max
+    local.set $x
+    local.get $x
+    i64.const 4
+    i64.eq
+  )
+)
+`
+	m, err := Compile(parser.MustParse(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.WAT(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWATNames(t *testing.T) {
+	input := "let f = fn(x: int, x) { let y = x; if (y > 0) { let y = 1; y } else { y } }; let y = false; !y"
+	m, err := Compile(parser.MustParse(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wat := m.WAT()
+	for _, want := range []string{
+		"(param $x i64) (param $x.1 i64)",
+		"(local $y i64)\n    (local $y.1 i64)\n",
+		"(local $y i32)\n",
+	} {
+		if !strings.Contains(wat, want) {
+			t.Errorf("missing %q in\n%s", want, wat)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for i, tc := range programs {
+		m, err := Compile(parser.MustParse(tc.input))
+		if err != nil {
+			t.Errorf("%d. Compile(%q): %v", i, tc.input, err)
+			continue
+		}
+		if err := validate(m.Encode()); err != nil {
+			t.Errorf("%d. %q: invalid module: %v\n%s", i, tc.input, err, m.WAT())
+		}
+	}
+}
+
+func TestEncodeInts(t *testing.T) {
+	tests := []struct {
+		v    int64
+		want string
+	}{
+		{0, "\x00"},
+		{1, "\x01"},
+		{-1, "\x7f"},
+		{63, "\x3f"},
+		{64, "\xc0\x00"},
+		{-64, "\x40"},
+		{-65, "\xbf\x7f"},
+		{624485, "\xe5\x8e\x26"},
+		{-123456, "\xc0\xbb\x78"},
+	}
+	for i, tt := range tests {
+		if got := string(appendInt(nil, tt.v)); got != tt.want {
+			t.Errorf("%d. appendInt(%d): got %q, want %q", i, tt.v, got, tt.want)
+		}
+		r := &reader{b: []byte(tt.want)}
+		if got := r.int(64); got != tt.v || r.err != nil {
+			t.Errorf("%d. reading %q: got %d (%v), want %d", i, tt.want, got, r.err, tt.v)
+		}
+	}
+	if got, want := string(appendUint(nil, 624485)), "\xe5\x8e\x26"; got != want {
+		t.Errorf("appendUint(624485): got %q, want %q", got, want)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	m, err := Compile(parser.MustParse("let f = fn(x) { x + 1 }; f(1) > 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(m.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	// Make the validator reject modules whose bodies are wrong, so it isn't
+	// accepting everything.
+	bad := []func(m *Module){
+		func(m *Module) { m.Funcs[0].Body = m.Funcs[0].Body[1:] },
+		func(m *Module) { m.Funcs[0].Body[2].Op = OpI32Eq },
+		func(m *Module) { m.Funcs[1].Body = append(m.Funcs[1].Body, Instr{Op: OpI32Const}) },
+		func(m *Module) { m.Funcs[1].Body[1].Imm = 5 },
+		func(m *Module) { m.Funcs[0].Results = nil },
+	}
+	for i, breakIt := range bad {
+		m, _ := Compile(parser.MustParse("let f = fn(x) { x + 1 }; f(1) > 0"))
+		breakIt(m)
+		if err := validate(m.Encode()); err == nil {
+			t.Errorf("%d. invalid module passed validation:\n%s", i, m.WAT())
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x", "1:1: undefined: x"},
+		{"1 + true", "1:5: cannot use true (type bool) as type int in operand of +"},
+		{
+			"let id = fn(x) { x }; id(1)",
+			"1:5: can't compile id of type fn(a) -> a: functions must take and return int or bool",
+		},
+		{
+			"let f = fn() { let g = fn() { 1 }; g() }; f()",
+			"1:16: can't compile function g: functions must be declared at the top level",
+		},
+		{
+			"let x = 1; let f = fn() { x }; f()",
+			"1:27: can't compile x, which is captured from an enclosing scope",
+		},
+		{
+			"let f = fn(x) { x + 1 }; let g = f; g(1)",
+			"1:34: can't compile use of function f as a value",
+		},
+		{"fn(x) { x }(1)", "1:1: can't compile a call of a function value: only functions declared at the top level can be called"},
+		{"if (true) { 1 }", "1:1: can't compile an if expression without an else branch whose value is used"},
+		{"let f = fn() { let x = 1; }; f()", "1:5: can't compile f of type fn() -> a: functions must take and return int or bool"},
+		{"let main = fn() { 1 }; main()", "1:24: function main is reserved for the program's top-level statements"},
+		{"let f = fn() { 1 }; let f = fn() { 2 }; f()", "1:25: function f is declared more than once"},
+		{"let x = 1; return x;", "1:12: can't compile return from main when the program's last statement isn't an expression"},
+	}
+	for i, tt := range tests {
+		_, err := Compile(parser.MustParse(tt.input))
+		if err == nil {
+			t.Errorf("%d. Compile(%q): no error, want %q", i, tt.input, tt.want)
+			continue
+		}
+		if got := err.Error(); got != tt.want {
+			t.Errorf("%d. Compile(%q): got error %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+// runner instantiates a module under node and prints what main returns.
+const runner = `const fs = require("fs");
+const mod = new WebAssembly.Module(fs.readFileSync(process.argv[2]));
+const instance = new WebAssembly.Instance(mod);
+console.log(String(instance.exports.main()));
+`
+
+// TestRun runs the compiled programs, if node is installed.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.cjs")
+	if err := os.WriteFile(script, []byte(runner), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range programs {
+		m, err := Compile(parser.MustParse(tc.input))
+		if err != nil {
+			t.Errorf("%d. Compile(%q): %v", i, tc.input, err)
+			continue
+		}
+		file := filepath.Join(dir, "prog.wasm")
+		if err := os.WriteFile(file, m.Encode(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command(node, script, file).CombinedOutput()
+		if err != nil {
+			t.Errorf("%d. %q: node: %v\n%s\n%s", i, tc.input, err, out, m.WAT())
+			continue
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.want {
+			t.Errorf("%d. %q: got %s, want %s", i, tc.input, got, tc.want)
+		}
+	}
+}